@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"interp"
+)
+
+var cmdCheck = &command{
+	name:  "check",
+	usage: "check [-watch] [-interval d] [-cache dir] file.apl",
+	short: "check an apl program for errors",
+	run:   runCheck,
+}
+
+// runCheck checks the given file along with its imports, resolved as by run.
+// With -watch, it keeps polling the loaded files every -interval and checks
+// them again incrementally whenever they change, printing the changed files
+// and the new result.
+func runCheck(cmd *command, args []string) int {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	watch := fs.Bool("watch", false, "check again whenever loaded files change")
+	interval := fs.Duration("interval", 500*time.Millisecond, "poll files every `d` with -watch")
+	cache := fs.String("cache", os.Getenv("APLCACHE"), "cache checked packages in `dir`")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: apl %s\n", cmd.usage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	prog, err := openProgram(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	e, file := prog.e, prog.path
+	e.SetCacheDir(*cache)
+	if !*watch {
+		if err := prog.check(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+	w := interp.NewWatcher(e, file)
+	err = w.Check()
+	if err == nil {
+		err = prog.writeSum()
+	}
+	report(file, err)
+	for range time.Tick(*interval) {
+		prev := err
+		var changed []string
+		changed, err = w.Poll()
+		if len(changed) > 0 && err == nil {
+			err = prog.writeSum()
+		}
+		if len(changed) == 0 && fmt.Sprint(err) == fmt.Sprint(prev) {
+			continue
+		}
+		if len(changed) > 0 {
+			fmt.Printf("changed: %s\n", strings.Join(changed, ", "))
+		}
+		report(file, err)
+	}
+	return 0
+}
+
+// report prints the result of checking file.
+func report(file string, err error) {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	fmt.Printf("%s: ok\n", file)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"bytecode"
+)
+
+var cmdDisasm = &command{
+	name:  "disasm",
+	usage: "disasm [-O0|-O1] file.apl",
+	short: "compile an apl program and print its bytecode",
+	run:   runDisasm,
+}
+
+// runDisasm compiles the given file along with its imports and prints the
+// disassembled bytecode. Imports are resolved and funcs optimized as by run.
+func runDisasm(cmd *command, args []string) int {
+	fs := flag.NewFlagSet("disasm", flag.ContinueOnError)
+	opt := optFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: apl %s\n", cmd.usage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	prog, err := openProgram(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	e, file := prog.e, prog.path
+	e.SetOptimize(*opt > 0)
+	if err := prog.check(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	p, err := e.Compile(file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := bytecode.Disassemble(os.Stdout, p); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"printer"
+)
+
+var cmdFmt = &command{
+	name:  "fmt",
+	usage: "fmt [-w] [-d] [path ...]",
+	short: "format apl source files",
+	run:   runFmt,
+}
+
+type fmtFlags struct {
+	write bool
+	diff  bool
+}
+
+// runFmt formats the given files, or all .apl files in the given
+// directories. With no paths, it formats standard input.
+func runFmt(cmd *command, args []string) int {
+	var ff fmtFlags
+	fs := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	fs.BoolVar(&ff.write, "w", false, "write result to (source) file instead of stdout")
+	fs.BoolVar(&ff.diff, "d", false, "display diffs instead of rewriting files")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: apl %s\n", cmd.usage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		if ff.write {
+			fmt.Fprintln(os.Stderr, "apl fmt: cannot use -w with standard input")
+			return 2
+		}
+		if err := ff.process("<standard input>", os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		return 0
+	}
+	exit := 0
+	for _, path := range fs.Args() {
+		err := filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() || filepath.Ext(path) != ".apl" {
+				return nil
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			return ff.process(path, f, os.Stdout)
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			exit = 2
+		}
+	}
+	return exit
+}
+
+// process formats the source read from in. Depending on the flags, the
+// result is written back to filename, reported as a diff, or printed to out.
+func (ff *fmtFlags) process(filename string, in io.Reader, out io.Writer) error {
+	src, err := ioutil.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	res, err := printer.Source(filepath.Base(filename), src)
+	if err != nil {
+		return fmt.Errorf("%s: %s", filename, err)
+	}
+	if !ff.write && !ff.diff {
+		_, err = out.Write(res)
+		return err
+	}
+	if bytes.Equal(src, res) {
+		return nil
+	}
+	if ff.diff {
+		d, err := diff(filename, src, res)
+		if err != nil {
+			return fmt.Errorf("computing diff: %s", err)
+		}
+		if _, err := out.Write(d); err != nil {
+			return err
+		}
+	}
+	if ff.write {
+		return ioutil.WriteFile(filename, res, 0644)
+	}
+	return nil
+}
+
+// diff returns the unified diff between two versions of filename, as reported
+// by the system diff tool.
+func diff(filename string, b1, b2 []byte) ([]byte, error) {
+	f1, err := writeTemp("apl-fmt", b1)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f1)
+	f2, err := writeTemp("apl-fmt", b2)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f2)
+	name := filepath.ToSlash(filename)
+	data, err := exec.Command("diff", "-u",
+		"--label", "orig/"+strings.TrimPrefix(name, "/"),
+		"--label", name,
+		f1, f2).CombinedOutput()
+	if len(data) > 0 {
+		// diff exits with a non-zero status when the files don't match.
+		// Ignore that failure as long as we get output.
+		return data, nil
+	}
+	return data, err
+}
+
+func writeTemp(prefix string, data []byte) (string, error) {
+	f, err := ioutil.TempFile("", prefix)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
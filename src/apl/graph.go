@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"graph"
+)
+
+var cmdGraph = &command{
+	name:  "graph",
+	usage: "graph [-calls|-imports] [-json] file.apl",
+	short: "print the call or import graph of an apl program",
+	run:   runGraph,
+}
+
+// runGraph checks the given file along with its imports, resolved as by run,
+// and prints their call graph, or with -imports their import graph, in the
+// Graphviz DOT language or with -json as JSON.
+func runGraph(cmd *command, args []string) int {
+	fs := flag.NewFlagSet("graph", flag.ContinueOnError)
+	calls := fs.Bool("calls", false, "print the call graph of the funcs (default)")
+	imports := fs.Bool("imports", false, "print the import graph of the packages")
+	asJSON := fs.Bool("json", false, "print JSON instead of DOT")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: apl %s\n", cmd.usage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 || *calls && *imports {
+		fs.Usage()
+		return 2
+	}
+	prog, err := openProgram(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	e, file := prog.e, prog.path
+	if err := prog.check(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	build := graph.Calls
+	if *imports {
+		build = graph.Imports
+	}
+	g, err := build(e, file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	write := g.WriteDOT
+	if *asJSON {
+		write = g.WriteJSON
+	}
+	if err := write(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"lsp"
+)
+
+var cmdLSP = &command{
+	name:  "lsp",
+	usage: "lsp",
+	short: "run the language server on stdin and stdout",
+	run:   runLSP,
+}
+
+func runLSP(cmd *command, args []string) int {
+	fs := flag.NewFlagSet("lsp", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: apl %s\n", cmd.usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if err := lsp.NewServer(os.Stdin, os.Stdout).Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "apl lsp: %s\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,64 @@
+// Command apl is the apl toolchain.
+//
+// Usage:
+//
+//	apl <command> [arguments]
+//
+// Run "apl help" for the list of commands.
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// command is a subcommand of apl.
+type command struct {
+	name  string
+	usage string // One-line usage, without the leading "apl ".
+	short string // Short description shown by "apl help".
+	run   func(cmd *command, args []string) int
+}
+
+// commands lists all subcommands in the order "apl help" shows them.
+var commands []*command
+
+func init() {
+	commands = []*command{
+		cmdCheck,
+		cmdDisasm,
+		cmdFmt,
+		cmdGraph,
+		cmdLSP,
+		cmdRun,
+		cmdTest,
+		cmdVet,
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: apl <command> [arguments]\n\ncommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", cmd.name, cmd.short)
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	name := os.Args[1]
+	if name == "help" || name == "-h" || name == "-help" {
+		usage()
+		os.Exit(0)
+	}
+	for _, cmd := range commands {
+		if cmd.name == name {
+			os.Exit(cmd.run(cmd, os.Args[2:]))
+		}
+	}
+	fmt.Fprintf(os.Stderr, "apl: unknown command %q\n", name)
+	usage()
+	os.Exit(2)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"interp"
+	"mod"
+)
+
+// program is an apl program named on the command line.
+type program struct {
+	e    *interp.Executor
+	path string            // Import path of the file.
+	mods *interp.ModLoader // Loader of the main module, if any.
+}
+
+// openProgram returns the program of an apl file. If the directory of the
+// file or one above it holds an apl.mod, the nearest one is the main module:
+// imports are resolved through it, see interp.ModLoader, and the import path
+// of the file is relative to the module root. Otherwise imports are resolved
+// relative to the directory of the file.
+func openProgram(file string) (*program, error) {
+	dir, name := filepath.Split(file)
+	root, err := findModule(dir)
+	if err != nil {
+		return nil, err
+	}
+	if root == "" {
+		e := interp.NewExecutor(&interp.FileLoader{SearchPaths: []string{dir}})
+		return &program{e: e, path: name}, nil
+	}
+	mods, err := interp.NewModLoader(root)
+	if err != nil {
+		return nil, err
+	}
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil {
+		return nil, err
+	}
+	return &program{e: interp.NewExecutor(mods), path: filepath.ToSlash(rel), mods: mods}, nil
+}
+
+// findModule returns the nearest directory, from dir upward, that holds an
+// apl.mod, or "" if there is none.
+func findModule(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, mod.ManifestName)); err == nil {
+			return dir, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// check checks the program. In a module, it then writes the apl.sum lockfile,
+// adding the hashes of the files of dependencies loaded for the first time.
+// Files whose hashes differ from the locked ones fail to load.
+func (p *program) check() error {
+	if err := p.e.Check(p.path); err != nil {
+		return err
+	}
+	return p.writeSum()
+}
+
+// writeSum writes the lockfile of the main module, if any.
+func (p *program) writeSum() error {
+	if p.mods == nil {
+		return nil
+	}
+	return p.mods.WriteSum()
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"interp"
+)
+
+var cmdRun = &command{
+	name:  "run",
+	usage: "run [-O0|-O1] [-cache dir] file.apl",
+	short: "check and run an apl program",
+	run:   runRun,
+}
+
+// runRun runs the main func of the given file. Imports are resolved through
+// the module the file is in, if any, see openProgram, and relative to the
+// directory of the file otherwise. Runtime errors are followed by the apl
+// stack trace. Checked packages are cached in the directory given by -cache,
+// which defaults to $APLCACHE; caching is disabled if it is empty. Funcs are
+// optimized unless -O0 is given.
+func runRun(cmd *command, args []string) int {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	opt := optFlags(fs)
+	cache := fs.String("cache", os.Getenv("APLCACHE"), "cache checked packages in `dir`")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: apl %s\n", cmd.usage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	prog, err := openProgram(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	e, file := prog.e, prog.path
+	e.SetCacheDir(*cache)
+	e.SetOptimize(*opt > 0)
+	if err := prog.check(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := e.Run(context.Background(), file); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		var re *interp.RuntimeError
+		if errors.As(err, &re) {
+			for _, line := range strings.SplitAfter(re.StackTrace(), "\n") {
+				if line != "" {
+					fmt.Fprintf(os.Stderr, "\t%s", line)
+				}
+			}
+		}
+		return 1
+	}
+	return 0
+}
+
+// optLevel is a flag setting the optimization level to a fixed value.
+type optLevel struct {
+	level *int
+	value int
+}
+
+func (o optLevel) String() string { return "" }
+
+func (o optLevel) IsBoolFlag() bool { return true }
+
+func (o optLevel) Set(s string) error {
+	on, err := strconv.ParseBool(s)
+	if on {
+		*o.level = o.value
+	}
+	return err
+}
+
+// optFlags defines the -O0 and -O1 flags in fs and returns the optimization
+// level they set, which is the one given last, or 1 if none is.
+func optFlags(fs *flag.FlagSet) *int {
+	level := 1
+	fs.Var(optLevel{&level, 0}, "O0", "disable optimizations")
+	fs.Var(optLevel{&level, 1}, "O1", "fold constants and remove dead branches (default)")
+	return &level
+}
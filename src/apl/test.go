@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"interp"
+)
+
+var cmdTest = &command{
+	name:  "test",
+	usage: "test [-run regexp] [-O0|-O1] file.apl",
+	short: "run the test funcs of an apl program",
+	run:   runTest,
+}
+
+// runTest runs the test funcs of the given file, see interp.Executor.Tests,
+// in order of declaration, and reports for each whether it passed and how
+// long it took. A failed test is followed by its error and stack trace.
+// Only the tests whose names match -run are run. Imports are resolved and
+// funcs optimized as by run. The exit status is 1 if a test fails or the
+// program does not check.
+func runTest(cmd *command, args []string) int {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	run := fs.String("run", "", "run only the tests matching `regexp`")
+	opt := optFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: apl %s\n", cmd.usage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	match, err := regexp.Compile(*run)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "apl test: invalid -run: %v\n", err)
+		return 2
+	}
+	prog, err := openProgram(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	e, file := prog.e, prog.path
+	e.SetOptimize(*opt > 0)
+	if err := prog.check(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	tests, err := e.Tests(file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	start := time.Now()
+	ran, failed := 0, 0
+	for _, name := range tests {
+		if !match.MatchString(name) {
+			continue
+		}
+		ran++
+		t := time.Now()
+		_, err := e.Call(context.Background(), file, name)
+		elapsed := time.Since(t).Seconds()
+		if err == nil {
+			fmt.Printf("--- PASS: %s (%.3fs)\n", name, elapsed)
+			continue
+		}
+		failed++
+		fmt.Printf("--- FAIL: %s (%.3fs)\n\t%v\n", name, elapsed, err)
+		var re *interp.RuntimeError
+		if errors.As(err, &re) {
+			for _, line := range strings.SplitAfter(re.StackTrace(), "\n") {
+				if line != "" {
+					fmt.Printf("\t\t%s", line)
+				}
+			}
+		}
+	}
+	elapsed := time.Since(start).Seconds()
+	switch {
+	case ran == 0:
+		fmt.Println("no tests to run")
+	case failed > 0:
+		fmt.Printf("FAIL\t%s\t%d of %d failed (%.3fs)\n", file, failed, ran, elapsed)
+		return 1
+	default:
+		fmt.Printf("ok\t%s\t%d passed (%.3fs)\n", file, ran, elapsed)
+	}
+	return 0
+}
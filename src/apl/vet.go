@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"vet"
+)
+
+var cmdVet = &command{
+	name:  "vet",
+	usage: "vet [-rule[=false]...] file.apl",
+	short: "report suspicious constructs in an apl program",
+	run:   runVet,
+}
+
+// runVet vets the given file along with its imports, resolved as by run, and
+// prints the diagnostics as file:line:col: message (rule). Each rule has a
+// flag of its name: if any rule is enabled explicitly, only the enabled rules
+// run, otherwise all rules that are not disabled. The exit status is 1 if
+// there are diagnostics or the program does not check.
+func runVet(cmd *command, args []string) int {
+	fs := flag.NewFlagSet("vet", flag.ContinueOnError)
+	enabled := make(map[string]*bool)
+	for _, r := range vet.Rules {
+		enabled[r.Name] = fs.Bool(r.Name, false, r.Doc)
+	}
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: apl %s\n", cmd.usage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	set := make(map[string]bool)
+	only := false
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+		only = only || *enabled[f.Name]
+	})
+	var rules []*vet.Rule
+	for _, r := range vet.Rules {
+		if *enabled[r.Name] || !only && !set[r.Name] {
+			rules = append(rules, r)
+		}
+	}
+	prog, err := openProgram(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	e, file := prog.e, prog.path
+	if err := prog.check(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	diags, err := vet.Vet(e, file, rules)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	for _, d := range diags {
+		fmt.Println(d)
+	}
+	if len(diags) > 0 {
+		return 1
+	}
+	return 0
+}
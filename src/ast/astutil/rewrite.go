@@ -0,0 +1,280 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Apply and Cursor are adapted from golang.org/x/tools/go/ast/astutil to the
+// apl AST.
+
+// Package astutil contains utilities for working with the AST.
+package astutil
+
+import (
+	"fmt"
+	"reflect"
+
+	"ast"
+	"ast/expr"
+	"ast/statement"
+)
+
+// An ApplyFunc is invoked by Apply for each node n, even if n is nil, before
+// and/or after the node's children, using a Cursor describing the current
+// node and providing operations on it.
+//
+// The return value of ApplyFunc controls the syntax tree traversal. See
+// Apply for details.
+type ApplyFunc func(*Cursor) bool
+
+// Apply traverses a syntax tree recursively, starting with root, and calling
+// pre and post for each node:
+//
+//   - If pre is not nil, it is called for each node before the node's
+//     children are traversed (pre-order). If pre returns false, no children
+//     are traversed, and post is not called for that node.
+//   - If post is not nil, and a prior call of pre didn't return false, post
+//     is called for each node after its children are traversed (post-order).
+//     If post returns false, traversal is terminated and Apply returns
+//     immediately.
+//
+// Only fields that refer to AST nodes are considered children. Children are
+// traversed in the order in which they appear in the respective node's
+// struct definition.
+//
+// Apply returns the possibly modified root node.
+func Apply(root ast.Node, pre, post ApplyFunc) (result ast.Node) {
+	parent := &struct{ ast.Node }{root}
+	defer func() {
+		if r := recover(); r != nil && r != abort {
+			panic(r)
+		}
+		result = parent.Node
+	}()
+	a := &application{pre: pre, post: post}
+	a.apply(parent, "Node", nil, root)
+	return
+}
+
+var abort = new(int) // singleton, to signal termination of Apply
+
+// A Cursor describes a node encountered during Apply. Information about the
+// node and its parent is available from the Node, Parent, Name and Index
+// methods.
+//
+// If p is a variable of type and value of the current parent node c.Parent(),
+// and f is the field identifier with name c.Name(), the following invariants
+// hold:
+//
+//	p.f            == c.Node()  if c.Index() <  0
+//	p.f[c.Index()] == c.Node()  if c.Index() >= 0
+//
+// The methods Replace, Delete, InsertBefore and InsertAfter can be used to
+// change the AST without disrupting Apply.
+type Cursor struct {
+	parent ast.Node
+	name   string
+	iter   *iterator // valid if non-nil
+	node   ast.Node
+}
+
+// Node returns the current Node.
+func (c *Cursor) Node() ast.Node { return c.node }
+
+// Parent returns the parent of the current Node.
+func (c *Cursor) Parent() ast.Node { return c.parent }
+
+// Name returns the name of the parent Node field that contains the current
+// Node. If the parent is a *ast.File and the current Node is one of its
+// declarations, Name returns "Decls".
+func (c *Cursor) Name() string { return c.name }
+
+// Index reports the index >= 0 of the current Node in the slice of Nodes that
+// contains it, or a value < 0 if the current Node is not part of a slice.
+// The index of the current node changes if InsertBefore is called while
+// processing the current node.
+func (c *Cursor) Index() int {
+	if c.iter != nil {
+		return c.iter.index
+	}
+	return -1
+}
+
+// field returns the current node's parent field value.
+func (c *Cursor) field() reflect.Value {
+	return reflect.Indirect(reflect.ValueOf(c.parent)).FieldByName(c.name)
+}
+
+// Replace replaces the current Node with n. The replacement node is not
+// walked by Apply. Replace panics if n does not fit the field that holds the
+// current Node.
+func (c *Cursor) Replace(n ast.Node) {
+	v := c.field()
+	if i := c.Index(); i >= 0 {
+		v = v.Index(i)
+	}
+	v.Set(nodeValue(v.Type(), n))
+}
+
+// Delete deletes the current Node from its containing slice. If the current
+// Node is not part of a slice, Delete panics. As a special case, if the
+// current node is a FnDecl's return type or an If's else branch, it is
+// cleared.
+func (c *Cursor) Delete() {
+	if _, ok := c.parent.(*ast.FnDecl); ok && c.name == "Return" {
+		c.Replace(nil)
+		return
+	}
+	if _, ok := c.parent.(*statement.If); ok && c.name == "Else" {
+		c.Replace(nil)
+		return
+	}
+	i := c.Index()
+	if i < 0 {
+		panic("Delete node not contained in slice")
+	}
+	v := c.field()
+	l := v.Len()
+	reflect.Copy(v.Slice(i, l), v.Slice(i+1, l))
+	v.Index(l - 1).Set(reflect.Zero(v.Type().Elem()))
+	v.SetLen(l - 1)
+	c.iter.step--
+}
+
+// InsertAfter inserts n after the current Node in its containing slice. If
+// the current Node is not part of a slice, InsertAfter panics. Apply does not
+// walk n.
+func (c *Cursor) InsertAfter(n ast.Node) {
+	i := c.Index()
+	if i < 0 {
+		panic("InsertAfter node not contained in slice")
+	}
+	v := c.field()
+	v.Set(reflect.Append(v, reflect.Zero(v.Type().Elem())))
+	l := v.Len()
+	reflect.Copy(v.Slice(i+2, l), v.Slice(i+1, l))
+	v.Index(i + 1).Set(nodeValue(v.Type().Elem(), n))
+	c.iter.step++
+}
+
+// InsertBefore inserts n before the current Node in its containing slice. If
+// the current Node is not part of a slice, InsertBefore panics. Apply will
+// not walk n.
+func (c *Cursor) InsertBefore(n ast.Node) {
+	i := c.Index()
+	if i < 0 {
+		panic("InsertBefore node not contained in slice")
+	}
+	v := c.field()
+	v.Set(reflect.Append(v, reflect.Zero(v.Type().Elem())))
+	l := v.Len()
+	reflect.Copy(v.Slice(i+1, l), v.Slice(i, l))
+	v.Index(i).Set(nodeValue(v.Type().Elem(), n))
+	c.iter.index++
+}
+
+func nodeValue(typ reflect.Type, n ast.Node) reflect.Value {
+	if n == nil {
+		return reflect.Zero(typ)
+	}
+	return reflect.ValueOf(n)
+}
+
+// application carries all the shared data so we can pass it around cheaply.
+type application struct {
+	pre, post ApplyFunc
+	cursor    Cursor
+	iter      iterator
+}
+
+func (a *application) apply(parent ast.Node, name string, iter *iterator, n ast.Node) {
+	// Avoid typed nil pointers in the cursor.
+	if v := reflect.ValueOf(n); v.Kind() == reflect.Ptr && v.IsNil() {
+		n = nil
+	}
+
+	// Avoid heap-allocating a new cursor for each apply call; reuse a.cursor
+	// instead.
+	saved := a.cursor
+	a.cursor.parent = parent
+	a.cursor.name = name
+	a.cursor.iter = iter
+	a.cursor.node = n
+
+	if a.pre != nil && !a.pre(&a.cursor) {
+		a.cursor = saved
+		return
+	}
+
+	// Walk children. Re-read the node from the cursor since pre may have
+	// replaced it.
+	switch n := a.cursor.node.(type) {
+	case nil:
+		// Nothing to do.
+	case *ast.File:
+		a.applyList(n, "Imports")
+		a.applyList(n, "Decls")
+	case *ast.FnDecl:
+		a.applyList(n, "Args")
+		a.apply(n, "Return", nil, n.Return)
+		a.applyList(n, "Statements")
+	case *statement.Import:
+		a.applyList(n, "Names")
+	case *statement.Return:
+		a.apply(n, "Expr", nil, n.Expr)
+	case *statement.FnCall:
+		a.applyList(n, "Params")
+	case *statement.Block:
+		a.applyList(n, "Statements")
+	case *statement.If:
+		a.apply(n, "Cond", nil, n.Cond)
+		a.apply(n, "Then", nil, n.Then)
+		a.apply(n, "Else", nil, n.Else)
+	case *expr.Call:
+		a.applyList(n, "Params")
+	case *expr.Binary:
+		a.apply(n, "X", nil, n.X)
+		a.apply(n, "Y", nil, n.Y)
+	case *expr.Unary:
+		a.apply(n, "X", nil, n.X)
+	case *statement.ImportName, *ast.FnArg, *ast.FnReturn, *expr.Value, *expr.Ident:
+		// Leaves.
+	default:
+		panic(fmt.Sprintf("Apply: unexpected node type %T", n))
+	}
+
+	if a.post != nil && !a.post(&a.cursor) {
+		panic(abort)
+	}
+
+	a.cursor = saved
+}
+
+// An iterator controls iteration over a slice of nodes.
+type iterator struct {
+	index, step int
+}
+
+func (a *application) applyList(parent ast.Node, name string) {
+	// Avoid heap-allocating a new iterator for each applyList call; reuse
+	// a.iter instead.
+	saved := a.iter
+	a.iter.index = 0
+	for {
+		// Must reload parent.name each time, since cursor modifications
+		// might change it.
+		v := reflect.Indirect(reflect.ValueOf(parent)).FieldByName(name)
+		if a.iter.index >= v.Len() {
+			break
+		}
+
+		// Element x may be nil in a bad AST - be cautious.
+		var x ast.Node
+		if e := v.Index(a.iter.index); e.IsValid() && !(e.Kind() == reflect.Interface && e.IsNil()) {
+			x = e.Interface().(ast.Node)
+		}
+
+		a.iter.step = 1
+		a.apply(parent, name, &a.iter, x)
+		a.iter.index += a.iter.step
+	}
+	a.iter = saved
+}
@@ -0,0 +1,159 @@
+package astutil
+
+import (
+	"strings"
+	"testing"
+
+	"ast"
+	"ast/expr"
+	"ast/statement"
+	"parser"
+	"values"
+)
+
+const input = `
+import foo;
+
+func hello(int i) bool {
+  a(1);
+  b(2);
+  c(3);
+  return false;
+}
+`
+
+func parse(t *testing.T) *ast.File {
+	l := parser.NewLexer("test.apl", strings.NewReader(input))
+	file, err := parser.NewParser(l).Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func callNames(f *ast.File) string {
+	var names []string
+	ast.Inspect(f, func(n ast.Node) bool {
+		if call, ok := n.(*statement.FnCall); ok {
+			names = append(names, call.Nam)
+		}
+		return true
+	})
+	return strings.Join(names, ",")
+}
+
+func TestApply(t *testing.T) {
+	testCases := []struct {
+		name  string
+		pre   ApplyFunc
+		calls string
+	}{
+		{
+			name: "noop",
+			pre: func(c *Cursor) bool {
+				return true
+			},
+			calls: "a,b,c",
+		},
+		{
+			name: "delete",
+			pre: func(c *Cursor) bool {
+				if call, ok := c.Node().(*statement.FnCall); ok && call.Nam == "b" {
+					c.Delete()
+				}
+				return true
+			},
+			calls: "a,c",
+		},
+		{
+			name: "replace",
+			pre: func(c *Cursor) bool {
+				if call, ok := c.Node().(*statement.FnCall); ok && call.Nam == "b" {
+					c.Replace(&statement.FnCall{Source: call.Source, Nam: "x"})
+				}
+				return true
+			},
+			calls: "a,x,c",
+		},
+		{
+			name: "insert",
+			pre: func(c *Cursor) bool {
+				if call, ok := c.Node().(*statement.FnCall); ok && call.Nam == "b" {
+					c.InsertBefore(&statement.FnCall{Source: call.Source, Nam: "before"})
+					c.InsertAfter(&statement.FnCall{Source: call.Source, Nam: "after"})
+				}
+				return true
+			},
+			calls: "a,before,b,after,c",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := Apply(parse(t), tc.pre, nil).(*ast.File)
+			if got := callNames(f); got != tc.calls {
+				t.Errorf("expected calls %q, got %q", tc.calls, got)
+			}
+		})
+	}
+}
+
+func TestApplyReplaceExpr(t *testing.T) {
+	f := Apply(parse(t), nil, func(c *Cursor) bool {
+		if v, ok := c.Node().(*expr.Value); ok && c.Name() == "Params" {
+			c.Replace(&expr.Value{Source: v.Source, V: &values.Int{V: c.Index() + 10}})
+		}
+		return true
+	}).(*ast.File)
+	var params []string
+	ast.Inspect(f, func(n ast.Node) bool {
+		if call, ok := n.(*statement.FnCall); ok {
+			for _, p := range call.Params {
+				params = append(params, p.(*expr.Value).V.String())
+			}
+		}
+		return true
+	})
+	if got, want := strings.Join(params, ","), "10,10,10"; got != want {
+		t.Errorf("expected params %q, got %q", want, got)
+	}
+}
+
+func TestApplyDeleteReturnType(t *testing.T) {
+	f := Apply(parse(t), func(c *Cursor) bool {
+		if _, ok := c.Node().(*ast.FnReturn); ok {
+			c.Delete()
+		}
+		return true
+	}, nil).(*ast.File)
+	if ret := f.Decls[0].(*ast.FnDecl).Return; ret != nil {
+		t.Errorf("expected return type to be deleted, got %v", ret)
+	}
+}
+
+func TestApplyStop(t *testing.T) {
+	var seen []string
+	Apply(parse(t), nil, func(c *Cursor) bool {
+		if call, ok := c.Node().(*statement.FnCall); ok {
+			seen = append(seen, call.Nam)
+			return call.Nam != "b"
+		}
+		return true
+	})
+	if got, want := strings.Join(seen, ","), "a,b"; got != want {
+		t.Errorf("expected traversal to stop after %q, got %q", want, got)
+	}
+}
+
+func TestApplyReplaceRoot(t *testing.T) {
+	replacement := &ast.File{}
+	got := Apply(parse(t), func(c *Cursor) bool {
+		if _, ok := c.Node().(*ast.File); ok {
+			c.Replace(replacement)
+			return false
+		}
+		return true
+	}, nil)
+	if got != replacement {
+		t.Errorf("expected root to be replaced, got %v", got)
+	}
+}
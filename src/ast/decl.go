@@ -38,14 +38,18 @@ func (f *FnReturn) String() string {
 	return fmt.Sprintf("%s %s", source.String(f.Source), f.Typ)
 }
 
-// FnDecl is a declaration for a function.
+// FnDecl is a declaration for a function. Functions are visible to other
+// packages if their name is capitalized or they are declared with the export
+// modifier.
 type FnDecl struct {
 	source.Source
+	Export bool // Declared with the export modifier.
 	Nam    string
 	Args   []*FnArg
 	Return *FnReturn // If null, does no return anything.
 
 	Statements []statement.Statement
+	End        source.Source // Position of the closing brace.
 }
 
 // Name returns the name of this function.
@@ -62,12 +66,18 @@ func (f *FnDecl) String() string {
 	for _, stmt := range f.Statements {
 		stmtStr = append(stmtStr, stmt.String())
 	}
-	return fmt.Sprintf("Fn(%s)[%s](%s)->%v{%s}", source.String(f.Source), f.Nam, strings.Join(argsStr, ","), f.Return, strings.Join(stmtStr, ","))
+	var export string
+	if f.Export {
+		export = "Export"
+	}
+	return fmt.Sprintf("%sFn(%s)[%s](%s)->%v{%s}", export, source.String(f.Source), f.Nam, strings.Join(argsStr, ","), f.Return, strings.Join(stmtStr, ","))
 }
 
 // Check validates the arg and return types of the declared function, as well
 // as the statements inside the function. The declared function is registered
-// prior to checking the statements to support recursive calls.
+// prior to checking the statements to support recursive calls. A function
+// with a return type must end in a return statement, see
+// statement.Terminates.
 func (f *FnDecl) Check(c *types.Context) error {
 	var argTypes []types.Type
 	for _, arg := range f.Args {
@@ -93,11 +103,26 @@ func (f *FnDecl) Check(c *types.Context) error {
 	if err != nil {
 		return f.Errf(err.Error())
 	}
-	for _, stmt := range f.Statements {
-		_, err := stmt.Check(c)
-		if err != nil {
-			return err
+	if f.Export {
+		if err := c.Export(f.Nam); err != nil {
+			return f.Errf(err.Error())
+		}
+	}
+	body := c.Func(retType)
+	for i, arg := range f.Args {
+		if err := body.AddVar(arg.Nam, argTypes[i]); err != nil {
+			return arg.Errf(err.Error())
+		}
+	}
+	if err := statement.CheckList(body, f.Statements); err != nil {
+		return err
+	}
+	if retType != nil && !statement.Terminates(f.Statements) {
+		end := f.End
+		if end == nil {
+			end = f.Source
 		}
+		return end.Errf("missing return at end of %s", f.Nam)
 	}
 	return nil
 }
@@ -12,10 +12,27 @@ import (
 type Expr interface {
 	source.Source
 	Check(*types.Context) (types.Type, error)
-	Eval() (values.Value, error)
+	Eval(Env) (values.Value, error)
 	String() string
 }
 
+// Env is the environment expressions are evaluated in. It is implemented by
+// the interpreter.
+type Env interface {
+	// Var returns the value of a variable in scope.
+	Var(name string) (values.Value, error)
+	// Call calls the func with the given name, as seen from the file being
+	// evaluated, and returns its result. The result is nil if the func does
+	// not return anything.
+	Call(src source.Source, name string, args []values.Value) (values.Value, error)
+	// Step is called before the statement at src is executed. It returns
+	// an error if execution must stop, e.g. because a limit is exceeded.
+	Step(src source.Source) error
+	// Alloc is called for each value v computed by the expression at src.
+	// It returns an error if execution must stop.
+	Alloc(src source.Source, v values.Value) error
+}
+
 // Value is an expression that is a constant value.
 type Value struct {
 	source.Source
@@ -28,11 +45,108 @@ func (v *Value) Check(c *types.Context) (types.Type, error) {
 	return v.V.Type(), nil
 }
 
-// Eval returns the constant value. It always returns a non-nil error.
-func (v *Value) Eval() (values.Value, error) {
+// Eval returns the constant value. It always returns a nil error.
+func (v *Value) Eval(env Env) (values.Value, error) {
 	return v.V, nil
 }
 
 func (v *Value) String() string {
 	return fmt.Sprintf("%v(%s)", v.V, source.String(v.Source))
 }
+
+// Ident is an expression referring to a variable.
+type Ident struct {
+	source.Source
+	Nam string
+}
+
+// Check returns the type of the variable.
+func (i *Ident) Check(c *types.Context) (types.Type, error) {
+	typ, err := c.Var(i.Nam)
+	if err != nil {
+		return nil, i.Errf(err.Error())
+	}
+	return typ, nil
+}
+
+// Eval returns the value of the variable.
+func (i *Ident) Eval(env Env) (values.Value, error) {
+	v, err := env.Var(i.Nam)
+	if err != nil {
+		return nil, i.Errf(err.Error())
+	}
+	return v, nil
+}
+
+func (i *Ident) String() string {
+	return fmt.Sprintf("Ident(%s:%s)", source.String(i.Source), i.Nam)
+}
+
+// Call is an expression calling a func that returns a value.
+type Call struct {
+	source.Source
+	Nam    string
+	Params []Expr
+}
+
+// Check validates the call and returns the return type of the func.
+func (c *Call) Check(tc *types.Context) (types.Type, error) {
+	typ, err := CheckCall(tc, c, c.Nam, c.Params)
+	if err != nil {
+		return nil, err
+	}
+	if typ == nil {
+		return nil, c.Errf("%s() used as value", c.Nam)
+	}
+	return typ, nil
+}
+
+// Eval calls the func.
+func (c *Call) Eval(env Env) (values.Value, error) {
+	return EvalCall(env, c, c.Nam, c.Params)
+}
+
+func (c *Call) String() string {
+	return fmt.Sprintf("Call(%s:%s:%v)", source.String(c.Source), c.Nam, c.Params)
+}
+
+// CheckCall validates the params for a call at src to the func with the given
+// name and returns the return type of the func, which is nil if the func does
+// not return anything.
+func CheckCall(c *types.Context, src source.Source, name string, params []Expr) (types.Type, error) {
+	typ, err := c.Get(name)
+	if err != nil {
+		return nil, src.Errf(err.Error())
+	}
+	fnTyp, ok := typ.(*types.Func)
+	if !ok {
+		return nil, src.Errf("%s is %v, not func", name, typ)
+	}
+	if n := len(fnTyp.Args); len(params) != n && !(fnTyp.Variadic && len(params) >= n-1) {
+		return nil, src.Errf("%s expects %d params, not %d", name, n, len(params))
+	}
+	for i, param := range params {
+		paramTyp, err := param.Check(c)
+		if err != nil {
+			return nil, err
+		}
+		if arg := fnTyp.Arg(i); !arg.Equals(paramTyp) {
+			return nil, src.Errf("%s param #%d expects %v, not %v", name, i+1, arg, paramTyp)
+		}
+	}
+	return fnTyp.Return, nil
+}
+
+// EvalCall evaluates the params for a call at src to the func with the given
+// name, left to right, and calls the func.
+func EvalCall(env Env, src source.Source, name string, params []Expr) (values.Value, error) {
+	args := make([]values.Value, len(params))
+	for i, param := range params {
+		v, err := param.Eval(env)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	return env.Call(src, name, args)
+}
@@ -0,0 +1,288 @@
+package expr
+
+import (
+	"errors"
+	"fmt"
+
+	"ast/source"
+	"types"
+	"values"
+)
+
+// Precedence returns the precedence of a binary operator. Operators with a
+// higher precedence bind tighter; all binary operators are left-associative.
+// Returns 0 for unknown operators.
+func Precedence(op string) int {
+	switch op {
+	case "||":
+		return 1
+	case "&&":
+		return 2
+	case "==", "!=", "<", "<=", ">", ">=":
+		return 3
+	case "+", "-":
+		return 4
+	case "*", "/", "%":
+		return 5
+	}
+	return 0
+}
+
+// Binary is an expression applying a binary operator. The source is the
+// position of the operator.
+type Binary struct {
+	source.Source
+	Op   string
+	X, Y Expr
+}
+
+// Check validates the operand types and returns the type of the result.
+// Arithmetic operators apply to ints and floats, except for % which applies
+// to ints only, and + also concatenates strings. Equality applies to operands
+// of the same type, comparing them with values.Value.Equal, ordering to
+// numbers and strings, and the logical operators to bools.
+func (b *Binary) Check(c *types.Context) (types.Type, error) {
+	x, err := b.X.Check(c)
+	if err != nil {
+		return nil, err
+	}
+	y, err := b.Y.Check(c)
+	if err != nil {
+		return nil, err
+	}
+	if !x.Equals(y) {
+		return nil, b.Errf("mismatched types %v and %v for %s", x, y, b.Op)
+	}
+	switch b.Op {
+	case "+":
+		switch x.(type) {
+		case *types.Int, *types.Float, *types.String:
+			return x, nil
+		}
+	case "-", "*", "/":
+		switch x.(type) {
+		case *types.Int, *types.Float:
+			return x, nil
+		}
+	case "%":
+		if _, ok := x.(*types.Int); ok {
+			return x, nil
+		}
+	case "==", "!=":
+		switch x.(type) {
+		case *types.Func, *types.Any:
+		default:
+			return &types.Bool{}, nil
+		}
+	case "<", "<=", ">", ">=":
+		switch x.(type) {
+		case *types.Int, *types.Float, *types.String:
+			return &types.Bool{}, nil
+		}
+	case "&&", "||":
+		if _, ok := x.(*types.Bool); ok {
+			return x, nil
+		}
+	default:
+		return nil, b.Errf("unknown operator %s", b.Op)
+	}
+	return nil, b.Errf("operator %s not defined on %v", b.Op, x)
+}
+
+// Eval evaluates the operands left to right and applies the operator. The
+// right operand of && and || is only evaluated if needed.
+func (b *Binary) Eval(env Env) (values.Value, error) {
+	x, err := b.X.Eval(env)
+	if err != nil {
+		return nil, err
+	}
+	if xb, ok := x.(*values.Bool); ok && (b.Op == "&&" && !xb.V || b.Op == "||" && xb.V) {
+		return x, nil
+	}
+	y, err := b.Y.Eval(env)
+	if err != nil {
+		return nil, err
+	}
+	v, err := b.apply(x, y)
+	if err != nil {
+		return nil, err
+	}
+	if err := env.Alloc(b, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// apply applies the operator to evaluated operands.
+func (b *Binary) apply(x, y values.Value) (values.Value, error) {
+	v, err := ApplyBinary(b.Op, x, y)
+	if err != nil {
+		return nil, b.Errf(err.Error())
+	}
+	return v, nil
+}
+
+// ApplyBinary applies a binary operator to operands whose types have been
+// checked as for Binary. For && and ||, it returns y, as the right operand
+// is only evaluated if the left one does not decide the result.
+func ApplyBinary(op string, x, y values.Value) (values.Value, error) {
+	switch op {
+	case "==":
+		return &values.Bool{V: x.Equal(y)}, nil
+	case "!=":
+		return &values.Bool{V: !x.Equal(y)}, nil
+	}
+	switch x := x.(type) {
+	case *values.Int:
+		y := y.(*values.Int)
+		switch op {
+		case "+":
+			return &values.Int{V: x.V + y.V}, nil
+		case "-":
+			return &values.Int{V: x.V - y.V}, nil
+		case "*":
+			return &values.Int{V: x.V * y.V}, nil
+		case "/", "%":
+			if y.V == 0 {
+				return nil, errors.New("division by zero")
+			}
+			if op == "/" {
+				return &values.Int{V: x.V / y.V}, nil
+			}
+			return &values.Int{V: x.V % y.V}, nil
+		case "<":
+			return &values.Bool{V: x.V < y.V}, nil
+		case "<=":
+			return &values.Bool{V: x.V <= y.V}, nil
+		case ">":
+			return &values.Bool{V: x.V > y.V}, nil
+		case ">=":
+			return &values.Bool{V: x.V >= y.V}, nil
+		}
+	case *values.Float:
+		y := y.(*values.Float)
+		switch op {
+		case "+":
+			return &values.Float{V: x.V + y.V}, nil
+		case "-":
+			return &values.Float{V: x.V - y.V}, nil
+		case "*":
+			return &values.Float{V: x.V * y.V}, nil
+		case "/":
+			return &values.Float{V: x.V / y.V}, nil
+		case "<":
+			return &values.Bool{V: x.V < y.V}, nil
+		case "<=":
+			return &values.Bool{V: x.V <= y.V}, nil
+		case ">":
+			return &values.Bool{V: x.V > y.V}, nil
+		case ">=":
+			return &values.Bool{V: x.V >= y.V}, nil
+		}
+	case *values.String:
+		y := y.(*values.String)
+		switch op {
+		case "+":
+			return &values.String{V: x.V + y.V}, nil
+		case "<":
+			return &values.Bool{V: x.V < y.V}, nil
+		case "<=":
+			return &values.Bool{V: x.V <= y.V}, nil
+		case ">":
+			return &values.Bool{V: x.V > y.V}, nil
+		case ">=":
+			return &values.Bool{V: x.V >= y.V}, nil
+		}
+	case *values.Bool:
+		y := y.(*values.Bool)
+		switch op {
+		case "&&", "||":
+			// Not short-circuited, so the result is y.
+			return y, nil
+		}
+	}
+	return nil, fmt.Errorf("operator %s not defined on %v", op, x.Type())
+}
+
+func (b *Binary) String() string {
+	return fmt.Sprintf("Binary(%s:%v %s %v)", source.String(b.Source), b.X, b.Op, b.Y)
+}
+
+// Unary is an expression applying a unary operator: ! negates a bool and -
+// a number.
+type Unary struct {
+	source.Source
+	Op string
+	X  Expr
+}
+
+// Check validates the operand type and returns it.
+func (u *Unary) Check(c *types.Context) (types.Type, error) {
+	x, err := u.X.Check(c)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Op {
+	case "!":
+		if _, ok := x.(*types.Bool); ok {
+			return x, nil
+		}
+	case "-":
+		switch x.(type) {
+		case *types.Int, *types.Float:
+			return x, nil
+		}
+	default:
+		return nil, u.Errf("unknown operator %s", u.Op)
+	}
+	return nil, u.Errf("operator %s not defined on %v", u.Op, x)
+}
+
+// Eval evaluates the operand and applies the operator.
+func (u *Unary) Eval(env Env) (values.Value, error) {
+	x, err := u.X.Eval(env)
+	if err != nil {
+		return nil, err
+	}
+	v, err := u.apply(x)
+	if err != nil {
+		return nil, err
+	}
+	if err := env.Alloc(u, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// apply applies the operator to an evaluated operand.
+func (u *Unary) apply(x values.Value) (values.Value, error) {
+	v, err := ApplyUnary(u.Op, x)
+	if err != nil {
+		return nil, u.Errf(err.Error())
+	}
+	return v, nil
+}
+
+// ApplyUnary applies a unary operator to an operand whose type has been
+// checked as for Unary.
+func ApplyUnary(op string, x values.Value) (values.Value, error) {
+	switch x := x.(type) {
+	case *values.Bool:
+		if op == "!" {
+			return &values.Bool{V: !x.V}, nil
+		}
+	case *values.Int:
+		if op == "-" {
+			return &values.Int{V: -x.V}, nil
+		}
+	case *values.Float:
+		if op == "-" {
+			return &values.Float{V: -x.V}, nil
+		}
+	}
+	return nil, fmt.Errorf("operator %s not defined on %v", op, x.Type())
+}
+
+func (u *Unary) String() string {
+	return fmt.Sprintf("Unary(%s:%s%v)", source.String(u.Source), u.Op, u.X)
+}
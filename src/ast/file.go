@@ -13,8 +13,20 @@ import (
 // source file.
 type File struct {
 	source.Source
-	Imports []*statement.Import
-	Decls   []Decl
+	Imports  []*statement.Import
+	Decls    []Decl
+	Comments []*Comment // All comments in the file, in source order.
+}
+
+// Comment is a line comment. Comments are not part of the tree; they are
+// kept on the File so tools such as the printer can reproduce them.
+type Comment struct {
+	source.Source
+	Text string // Comment text, including the leading "//".
+}
+
+func (c *Comment) String() string {
+	return fmt.Sprintf("Comment(%s)%s", source.String(c.Source), c.Text)
 }
 
 func (f *File) String() string {
@@ -0,0 +1,225 @@
+// Package optimize simplifies checked ASTs without changing their observable
+// behavior. Constant subexpressions are folded, boolean identities are
+// simplified and ifs with constant conditions are replaced by the branch
+// taken.
+//
+// Rewritten nodes keep the positions of the nodes they replace, so
+// diagnostics and stack traces point at the same source. The input is never
+// modified; subtrees that do not change are shared with the result.
+package optimize
+
+import (
+	"ast"
+	"ast/expr"
+	"ast/statement"
+	"values"
+)
+
+// Func returns the optimized form of a checked func declaration.
+func Func(d *ast.FnDecl) *ast.FnDecl {
+	stmts := stmtList(d.Statements)
+	if sameStmts(stmts, d.Statements) {
+		return d
+	}
+	opt := *d
+	opt.Statements = stmts
+	return &opt
+}
+
+// Stmt returns the optimized form of a checked statement. An if with a
+// constant condition becomes the branch taken, as a block at the position of
+// the if, or an empty block if there is none. Either way it still takes one
+// step, see expr.Env.
+func Stmt(s statement.Statement) statement.Statement {
+	switch s := s.(type) {
+	case *statement.Return:
+		if s.Expr == nil {
+			return s
+		}
+		x := Expr(s.Expr)
+		if x == s.Expr {
+			return s
+		}
+		return &statement.Return{Source: s.Source, Expr: x}
+	case *statement.FnCall:
+		params := exprList(s.Params)
+		if sameExprs(params, s.Params) {
+			return s
+		}
+		return &statement.FnCall{Source: s.Source, Nam: s.Nam, Params: params}
+	case *statement.Block:
+		return block(s)
+	case *statement.If:
+		cond := Expr(s.Cond)
+		if taken, ok := constBool(cond); ok {
+			if taken {
+				then := block(s.Then)
+				return &statement.Block{Source: s.Source, Statements: then.Statements, End: then.End}
+			}
+			switch els := Stmt(s.Else).(type) {
+			case nil:
+				return &statement.Block{Source: s.Source, End: s.Then.End}
+			case *statement.Block:
+				return &statement.Block{Source: s.Source, Statements: els.Statements, End: els.End}
+			case *statement.If:
+				elseIf := *els
+				elseIf.Source = s.Source
+				return &elseIf
+			default:
+				return els
+			}
+		}
+		then := block(s.Then)
+		var els statement.Statement
+		if s.Else != nil {
+			els = Stmt(s.Else)
+		}
+		if cond == s.Cond && then == s.Then && els == s.Else {
+			return s
+		}
+		return &statement.If{Source: s.Source, Cond: cond, Then: then, Else: els}
+	}
+	return s
+}
+
+func block(b *statement.Block) *statement.Block {
+	stmts := stmtList(b.Statements)
+	if sameStmts(stmts, b.Statements) {
+		return b
+	}
+	return &statement.Block{Source: b.Source, Statements: stmts, End: b.End}
+}
+
+func stmtList(list []statement.Statement) []statement.Statement {
+	var opt []statement.Statement
+	for _, s := range list {
+		opt = append(opt, Stmt(s))
+	}
+	return opt
+}
+
+func sameStmts(a, b []statement.Statement) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Expr returns the optimized form of a checked expression. Operators applied
+// to constants are folded into a constant at the position of the operator,
+// unless they fail, such as a division by zero, which is left to happen at
+// run time.
+func Expr(x expr.Expr) expr.Expr {
+	switch x := x.(type) {
+	case *expr.Call:
+		params := exprList(x.Params)
+		if sameExprs(params, x.Params) {
+			return x
+		}
+		return &expr.Call{Source: x.Source, Nam: x.Nam, Params: params}
+	case *expr.Unary:
+		operand := Expr(x.X)
+		if c, ok := operand.(*expr.Value); ok {
+			if v, err := expr.ApplyUnary(x.Op, c.V); err == nil {
+				return &expr.Value{Source: x.Source, V: v}
+			}
+		}
+		if u, ok := operand.(*expr.Unary); ok && x.Op == "!" && u.Op == "!" {
+			return u.X
+		}
+		if operand == x.X {
+			return x
+		}
+		return &expr.Unary{Source: x.Source, Op: x.Op, X: operand}
+	case *expr.Binary:
+		return binary(x)
+	}
+	return x
+}
+
+func binary(b *expr.Binary) expr.Expr {
+	x, y := Expr(b.X), Expr(b.Y)
+	if b.Op == "&&" || b.Op == "||" {
+		if opt := logical(b.Op == "&&", x, y); opt != nil {
+			return opt
+		}
+	} else if cx, ok := x.(*expr.Value); ok {
+		if cy, ok := y.(*expr.Value); ok {
+			if v, err := expr.ApplyBinary(b.Op, cx.V, cy.V); err == nil {
+				return &expr.Value{Source: b.Source, V: v}
+			}
+		}
+	}
+	if x == b.X && y == b.Y {
+		return b
+	}
+	return &expr.Binary{Source: b.Source, Op: b.Op, X: x, Y: y}
+}
+
+// logical simplifies x && y, or x || y if not and, by the boolean
+// identities. Returns nil if neither operand is constant, or if the result
+// only depends on a constant y but x cannot be dropped.
+func logical(and bool, x, y expr.Expr) expr.Expr {
+	if c, ok := constBool(x); ok {
+		if c != and {
+			// false && y, true || y: y is never evaluated.
+			return x
+		}
+		// true && y, false || y.
+		return y
+	}
+	if c, ok := constBool(y); ok {
+		if c == and {
+			// x && true, x || false.
+			return x
+		}
+		if pure(x) {
+			// x && false, x || true.
+			return y
+		}
+	}
+	return nil
+}
+
+// constBool reports the value of x if it is a constant bool.
+func constBool(x expr.Expr) (bool, bool) {
+	if c, ok := x.(*expr.Value); ok {
+		if b, ok := c.V.(*values.Bool); ok {
+			return b.V, true
+		}
+	}
+	return false, false
+}
+
+// pure reports whether evaluating x has no effects and cannot fail, so that
+// it may be dropped. Calls may have effects, and divisions may fail.
+func pure(x expr.Expr) bool {
+	switch x := x.(type) {
+	case *expr.Value, *expr.Ident:
+		return true
+	case *expr.Unary:
+		return pure(x.X)
+	case *expr.Binary:
+		return x.Op != "/" && x.Op != "%" && pure(x.X) && pure(x.Y)
+	}
+	return false
+}
+
+func exprList(list []expr.Expr) []expr.Expr {
+	var opt []expr.Expr
+	for _, x := range list {
+		opt = append(opt, Expr(x))
+	}
+	return opt
+}
+
+func sameExprs(a, b []expr.Expr) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
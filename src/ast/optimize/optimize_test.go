@@ -0,0 +1,189 @@
+package optimize
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"ast"
+	"ast/expr"
+	"ast/source"
+	"ast/statement"
+	"parser"
+)
+
+// format renders a node compactly, with binary expressions in parens.
+func format(n interface{}) string {
+	switch n := n.(type) {
+	case *expr.Value:
+		return n.V.String()
+	case *expr.Ident:
+		return n.Nam
+	case *expr.Call:
+		return n.Nam + "(" + formatList(n.Params) + ")"
+	case *expr.Unary:
+		return n.Op + format(n.X)
+	case *expr.Binary:
+		return "(" + format(n.X) + " " + n.Op + " " + format(n.Y) + ")"
+	case *statement.Return:
+		if n.Expr == nil {
+			return "return;"
+		}
+		return "return " + format(n.Expr) + ";"
+	case *statement.FnCall:
+		return n.Nam + "(" + formatList(n.Params) + ");"
+	case *statement.Block:
+		var stmts []string
+		for _, s := range n.Statements {
+			stmts = append(stmts, format(s))
+		}
+		return "{" + strings.Join(stmts, " ") + "}"
+	case *statement.If:
+		s := "if " + format(n.Cond) + " " + format(n.Then)
+		if n.Else != nil {
+			s += " else " + format(n.Else)
+		}
+		return s
+	}
+	return fmt.Sprintf("%T", n)
+}
+
+func formatList(list []expr.Expr) string {
+	var s []string
+	for _, x := range list {
+		s = append(s, format(x))
+	}
+	return strings.Join(s, ", ")
+}
+
+func parseFunc(t *testing.T, body string) *ast.FnDecl {
+	t.Helper()
+	src := "func f(int x, bool b) {\n" + body + "\n}\n"
+	file, err := parser.NewParser(parser.NewLexer("test.apl", strings.NewReader(src))).Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file.Decls[0].(*ast.FnDecl)
+}
+
+func TestFunc(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "arithmetic",
+			input: `println(2 * 3 + 1, x + 2 * 3, -(1 + 1), 1.5 * 2.0, "a" + "b");`,
+			want:  `println(7, (x + 6), -2, 3, ab);`,
+		},
+		{
+			name:  "comparisons",
+			input: `println(1 < 2, 2 == 3, "a" != "b", !(1 > 2));`,
+			want:  `println(true, false, true, true);`,
+		},
+		{
+			name:  "failing",
+			input: `println(1 / 0, 1 % (2 - 2), 1 / 1);`,
+			want:  `println((1 / 0), (1 % 0), 1);`,
+		},
+		{
+			name:  "boolean_identities",
+			input: `println(b && true, true && b, b || false, false || b, !!b);`,
+			want:  `println(b, b, b, b, b);`,
+		},
+		{
+			name:  "short_circuit",
+			input: `println(false && f(), true || f(), b && false, x > 1 || true);`,
+			want:  `println(false, true, false, true);`,
+		},
+		{
+			name:  "impure_operand",
+			input: `println(f() && false, x / 2 > 1 || true, f() && b);`,
+			want:  `println((f() && false), (((x / 2) > 1) || true), (f() && b));`,
+		},
+		{
+			name: "dead_branches",
+			input: `if 1 > 2 {
+  println(1);
+} else if b {
+  println(2);
+} else {
+  println(3);
+}
+if true && !false {
+  println(4);
+} else {
+  println(5);
+}
+if false {
+  println(6);
+}
+if b || true {
+  return;
+}`,
+			want: `if b {println(2);} else {println(3);} {println(4);} {} {return;}`,
+		},
+		{
+			name: "nested",
+			input: `if b {
+  if false {
+    println(1);
+  } else {
+    println(2 + 2);
+  }
+}`,
+			want: `if b {{println(4);}}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := parseFunc(t, tc.input)
+			before := d.String()
+			opt := Func(d)
+			var stmts []string
+			for _, s := range opt.Statements {
+				stmts = append(stmts, format(s))
+			}
+			if got := strings.Join(stmts, " "); got != tc.want {
+				t.Errorf("expected\n%s\ngot\n%s", tc.want, got)
+			}
+			if d.String() != before {
+				t.Errorf("input modified")
+			}
+		})
+	}
+}
+
+func TestFuncUnchanged(t *testing.T) {
+	d := parseFunc(t, `if b {
+  println(x + 1, f());
+}
+return;`)
+	if opt := Func(d); opt != d {
+		t.Errorf("expected unchanged func to be returned as is")
+	}
+}
+
+func TestPositions(t *testing.T) {
+	d := parseFunc(t, `println(x, 1 + 2 * 3);
+if 2 > 1 {
+  return;
+}`)
+	pos := func(s source.Source) string {
+		return fmt.Sprintf("%d:%d", s.Line()+1, s.LinePos()+1)
+	}
+	opt := Func(d)
+	call := opt.Statements[0].(*statement.FnCall)
+	if got, want := pos(call.Params[0]), "2:9"; got != want {
+		t.Errorf("expected unchanged param at %s, got %s", want, got)
+	}
+	// The folded sum is at the position of the +.
+	if got, want := pos(call.Params[1]), "2:14"; got != want {
+		t.Errorf("expected folded constant at %s, got %s", want, got)
+	}
+	// The block taking the place of the if is at the position of the if.
+	if got, want := pos(opt.Statements[1]), "3:1"; got != want {
+		t.Errorf("expected block at %s, got %s", want, got)
+	}
+}
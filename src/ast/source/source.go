@@ -20,3 +20,29 @@ func String(s Source) string {
 	}
 	return fmt.Sprintf("@<%s:%d:%d:%d>", s.File(), s.Line()+1, s.LinePos()+1, s.Pos())
 }
+
+// Error is an error at a position in the source.
+type Error struct {
+	Src Source
+	Msg string
+	Err error // Underlying error, if any.
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s:%d:%d %s", e.Src.File(), e.Src.Line()+1, e.Src.LinePos()+1, e.Msg)
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Wrap returns an *Error at s for err, which it unwraps to.
+func Wrap(s Source, err error) error {
+	return &Error{Src: s, Msg: err.Error(), Err: err}
+}
+
+// Errorf returns an *Error at s with the formatted message.
+func Errorf(s Source, format string, args ...interface{}) error {
+	return &Error{Src: s, Msg: fmt.Sprintf(format, args...)}
+}
@@ -2,10 +2,12 @@ package statement
 
 import (
 	"fmt"
+	"strings"
 
 	"ast/expr"
 	"ast/source"
 	"types"
+	"values"
 )
 
 // Statement represents a statement in the language.
@@ -13,24 +15,71 @@ type Statement interface {
 	source.Source
 	String() string
 	Check(*types.Context) (types.Type, error)
+	// Exec executes the statement. If a return statement was executed, it
+	// reports true along with the returned value, which is nil if no value
+	// is returned.
+	Exec(expr.Env) (values.Value, bool, error)
 }
 
 // Import is an import statement. Import statements load additional
-// namespaced libs relative to the root of the project.
+// namespaced libs relative to the root of the project. The exported names of
+// the lib are available qualified by the last element of its path, or by an
+// alias given with "as". Names listed in braces are available unqualified;
+// then, the lib is only available qualified if an alias is given.
 type Import struct {
 	source.Source
-	Name string
+	Name  string
+	Alias string        // Empty if no alias is given.
+	Names []*ImportName // Selectively imported names.
+}
+
+// ImportName is a name selectively imported by an import statement.
+type ImportName struct {
+	source.Source
+	Nam string
+}
+
+func (i *ImportName) String() string {
+	return fmt.Sprintf("%s %s", source.String(i.Source), i.Nam)
 }
 
 func (i *Import) String() string {
-	return fmt.Sprintf("import(%s) %s", source.String(i.Source), i.Name)
+	var names []string
+	for _, name := range i.Names {
+		names = append(names, name.String())
+	}
+	return fmt.Sprintf("import(%s) %s as(%s) {%s}", source.String(i.Source), i.Name, i.Alias, strings.Join(names, ","))
+}
+
+// Qualifier returns the name the imported lib is available as.
+func (i *Import) Qualifier() string {
+	if i.Alias != "" {
+		return i.Alias
+	}
+	return i.Name[strings.LastIndex(i.Name, "/")+1:]
 }
 
-// Check always returns nil.
+// Check registers the imported names in the file's context. The imported lib
+// must have been checked already. Always returns a nil type.
 func (i *Import) Check(c *types.Context) (types.Type, error) {
+	if len(i.Names) == 0 || i.Alias != "" {
+		if err := c.Import(i.Name, i.Qualifier()); err != nil {
+			return nil, i.Errf(err.Error())
+		}
+	}
+	for _, name := range i.Names {
+		if err := c.Select(i.Name, name.Nam); err != nil {
+			return nil, name.Errf(err.Error())
+		}
+	}
 	return nil, nil
 }
 
+// Exec does nothing; imports are resolved before execution.
+func (i *Import) Exec(env expr.Env) (values.Value, bool, error) {
+	return nil, false, nil
+}
+
 // Return is a return. Return statements return a value from a function. Return
 // statements must be the last statement in a function.
 type Return struct {
@@ -42,9 +91,39 @@ func (r *Return) String() string {
 	return fmt.Sprintf("return(%s) %v", source.String(r.Source), r.Expr)
 }
 
-// Check checks the expression to be returned.
+// Check checks the expression to be returned against the return type of the
+// enclosing func and returns its type.
 func (r *Return) Check(c *types.Context) (types.Type, error) {
-	return r.Expr.Check(c)
+	want := c.Return()
+	if r.Expr == nil {
+		if want != nil {
+			return nil, r.Errf("missing return value, expected %v", want)
+		}
+		return nil, nil
+	}
+	typ, err := r.Expr.Check(c)
+	if err != nil {
+		return nil, err
+	}
+	if want == nil {
+		return nil, r.Errf("unexpected return value")
+	}
+	if !want.Equals(typ) {
+		return nil, r.Errf("return expects %v, not %v", want, typ)
+	}
+	return typ, nil
+}
+
+// Exec evaluates the expression to be returned.
+func (r *Return) Exec(env expr.Env) (values.Value, bool, error) {
+	if r.Expr == nil {
+		return nil, true, nil
+	}
+	v, err := r.Expr.Eval(env)
+	if err != nil {
+		return nil, false, err
+	}
+	return v, true, nil
 }
 
 // FnCall is a statement to call a function.
@@ -61,25 +140,129 @@ func (f *FnCall) String() string {
 // Check validates the params for the function call and returns the return type
 // of the function.
 func (f *FnCall) Check(c *types.Context) (types.Type, error) {
-	typ, err := c.Get(f.Nam)
+	return expr.CheckCall(c, f, f.Nam, f.Params)
+}
+
+// Exec calls the function and discards its result.
+func (f *FnCall) Exec(env expr.Env) (values.Value, bool, error) {
+	_, err := expr.EvalCall(env, f, f.Nam, f.Params)
+	return nil, false, err
+}
+
+// Block is a braced list of statements. Its source is the position of the
+// opening brace.
+type Block struct {
+	source.Source
+	Statements []Statement
+	End        source.Source // Position of the closing brace.
+}
+
+func (b *Block) String() string {
+	var stmts []string
+	for _, stmt := range b.Statements {
+		stmts = append(stmts, stmt.String())
+	}
+	return fmt.Sprintf("Block(%s){%s}", source.String(b.Source), strings.Join(stmts, ","))
+}
+
+// Check checks the statements in a nested scope. Always returns a nil type.
+func (b *Block) Check(c *types.Context) (types.Type, error) {
+	return nil, CheckList(c.Block(), b.Statements)
+}
+
+// Exec executes the statements until one returns.
+func (b *Block) Exec(env expr.Env) (values.Value, bool, error) {
+	return ExecList(env, b.Statements)
+}
+
+// If is a conditional statement. The else branch is either nil, a *Block, or
+// another *If for an else if.
+type If struct {
+	source.Source
+	Cond expr.Expr
+	Then *Block
+	Else Statement
+}
+
+func (i *If) String() string {
+	return fmt.Sprintf("If(%s:%v){%v}else{%v}", source.String(i.Source), i.Cond, i.Then, i.Else)
+}
+
+// Check checks that the condition is a bool and checks both branches.
+// Always returns a nil type.
+func (i *If) Check(c *types.Context) (types.Type, error) {
+	typ, err := i.Cond.Check(c)
 	if err != nil {
-		return nil, f.Errf(err.Error())
+		return nil, err
 	}
-	fnTyp, ok := typ.(*types.Func)
-	if !ok {
-		return nil, f.Errf("%s is %v, not func", f.Nam, typ)
+	if _, ok := typ.(*types.Bool); !ok {
+		return nil, i.Cond.Errf("condition is %v, not bool", typ)
 	}
-	if len(f.Params) != len(fnTyp.Args) {
-		return nil, f.Errf("%s expects %d params, not %d", f.Nam, len(fnTyp.Args), len(f.Params))
+	if _, err := i.Then.Check(c); err != nil {
+		return nil, err
 	}
-	for i, arg := range fnTyp.Args {
-		paramTyp, err := f.Params[i].Check(c)
-		if err != nil {
+	if i.Else != nil {
+		if _, err := i.Else.Check(c); err != nil {
 			return nil, err
 		}
-		if !paramTyp.Equals(arg) {
-			return nil, f.Errf("%s param #%d expects %v, not %v", f.Nam, i+1, arg, paramTyp)
+	}
+	return nil, nil
+}
+
+// Exec evaluates the condition and executes the matching branch.
+func (i *If) Exec(env expr.Env) (values.Value, bool, error) {
+	v, err := i.Cond.Eval(env)
+	if err != nil {
+		return nil, false, err
+	}
+	if v.(*values.Bool).V {
+		return i.Then.Exec(env)
+	}
+	if i.Else != nil {
+		return i.Else.Exec(env)
+	}
+	return nil, false, nil
+}
+
+// CheckList checks a list of statements in order.
+func CheckList(c *types.Context, stmts []Statement) error {
+	for _, stmt := range stmts {
+		if _, err := stmt.Check(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Terminates reports whether a list of statements always ends in a return
+// statement: its last statement is a return, a block that terminates, or an
+// if with an else whose branches both terminate.
+func Terminates(stmts []Statement) bool {
+	if len(stmts) == 0 {
+		return false
+	}
+	switch s := stmts[len(stmts)-1].(type) {
+	case *Return:
+		return true
+	case *Block:
+		return Terminates(s.Statements)
+	case *If:
+		return s.Else != nil && Terminates(s.Then.Statements) && Terminates([]Statement{s.Else})
+	}
+	return false
+}
+
+// ExecList executes a list of statements in order, until one returns. Each
+// statement is announced to env.Step first.
+func ExecList(env expr.Env, stmts []Statement) (values.Value, bool, error) {
+	for _, stmt := range stmts {
+		if err := env.Step(stmt); err != nil {
+			return nil, false, err
+		}
+		v, ret, err := stmt.Exec(env)
+		if err != nil || ret {
+			return v, ret, err
 		}
 	}
-	return fnTyp.Return, nil
+	return nil, false, nil
 }
@@ -0,0 +1,109 @@
+package ast
+
+import (
+	"fmt"
+
+	"ast/expr"
+	"ast/source"
+	"ast/statement"
+)
+
+// Node is any node of the AST: a File, an import, a declaration, a statement
+// or an expression.
+type Node interface {
+	source.Source
+	String() string
+}
+
+// A Visitor's Visit method is invoked for each node encountered by Walk. If
+// the result visitor w is not nil, Walk visits each of the children of node
+// with the visitor w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses an AST in depth-first order. It starts by calling
+// v.Visit(node); node must not be nil. If the visitor w returned by
+// v.Visit(node) is not nil, Walk is invoked recursively with visitor w for
+// each of the non-nil children of node, followed by a call of w.Visit(nil).
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+	switch n := node.(type) {
+	case *File:
+		for _, imp := range n.Imports {
+			Walk(v, imp)
+		}
+		for _, decl := range n.Decls {
+			Walk(v, decl)
+		}
+	case *FnDecl:
+		for _, arg := range n.Args {
+			Walk(v, arg)
+		}
+		if n.Return != nil {
+			Walk(v, n.Return)
+		}
+		walkStatements(v, n.Statements)
+	case *statement.Import:
+		for _, name := range n.Names {
+			Walk(v, name)
+		}
+	case *statement.Return:
+		if n.Expr != nil {
+			Walk(v, n.Expr)
+		}
+	case *statement.FnCall:
+		walkExprs(v, n.Params)
+	case *statement.Block:
+		walkStatements(v, n.Statements)
+	case *statement.If:
+		Walk(v, n.Cond)
+		Walk(v, n.Then)
+		if n.Else != nil {
+			Walk(v, n.Else)
+		}
+	case *expr.Call:
+		walkExprs(v, n.Params)
+	case *expr.Binary:
+		Walk(v, n.X)
+		Walk(v, n.Y)
+	case *expr.Unary:
+		Walk(v, n.X)
+	case *statement.ImportName, *FnArg, *FnReturn, *expr.Value, *expr.Ident:
+		// Leaves.
+	default:
+		panic(fmt.Sprintf("ast.Walk: unexpected node type %T", n))
+	}
+	v.Visit(nil)
+}
+
+func walkStatements(v Visitor, stmts []statement.Statement) {
+	for _, stmt := range stmts {
+		Walk(v, stmt)
+	}
+}
+
+func walkExprs(v Visitor, exprs []expr.Expr) {
+	for _, e := range exprs {
+		Walk(v, e)
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses an AST in depth-first order. It starts by calling
+// f(node); node must not be nil. If f returns true, Inspect invokes f
+// recursively for each of the non-nil children of node, followed by a call
+// of f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
@@ -0,0 +1,90 @@
+package ast
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"ast/expr"
+	"ast/statement"
+	"values"
+)
+
+func testFile() *File {
+	return &File{
+		Imports: []*statement.Import{{Name: "foo"}},
+		Decls: []Decl{
+			&FnDecl{
+				Nam:    "hello",
+				Args:   []*FnArg{{Typ: "int", Nam: "i"}},
+				Return: &FnReturn{Typ: "bool"},
+				Statements: []statement.Statement{
+					&statement.FnCall{
+						Nam:    "do",
+						Params: []expr.Expr{&expr.Value{V: &values.Int{V: 1}}},
+					},
+					&statement.Return{
+						Expr: &expr.Value{V: &values.Bool{V: false}},
+					},
+				},
+			},
+		},
+	}
+}
+
+type recorder struct {
+	events *[]string
+}
+
+func (r recorder) Visit(n Node) Visitor {
+	if n == nil {
+		*r.events = append(*r.events, "end")
+		return nil
+	}
+	*r.events = append(*r.events, fmt.Sprintf("%T", n))
+	return r
+}
+
+func TestWalk(t *testing.T) {
+	var events []string
+	Walk(recorder{&events}, testFile())
+	expected := []string{
+		"*ast.File",
+		"*statement.Import", "end",
+		"*ast.FnDecl",
+		"*ast.FnArg", "end",
+		"*ast.FnReturn", "end",
+		"*statement.FnCall",
+		"*expr.Value", "end",
+		"end",
+		"*statement.Return",
+		"*expr.Value", "end",
+		"end",
+		"end",
+		"end",
+	}
+	if got, want := strings.Join(events, " "), strings.Join(expected, " "); got != want {
+		t.Errorf("expected\n%s\n\ngot\n%s", want, got)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	var names []string
+	Inspect(testFile(), func(n Node) bool {
+		switch n := n.(type) {
+		case *FnDecl:
+			names = append(names, n.Nam)
+		case *statement.FnCall:
+			names = append(names, n.Nam)
+		case *statement.Return:
+			// Do not descend into the returned expression.
+			return false
+		case *expr.Value:
+			names = append(names, n.V.String())
+		}
+		return true
+	})
+	if got, want := strings.Join(names, ","), "hello,do,1"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,309 @@
+// Package bytecode defines the compiled form of apl programs run by the
+// interpreter's virtual machine.
+//
+// A Program is a list of funcs. Each func has a code of fixed-size
+// instructions operating on a value stack, a pool of constants the code
+// refers to by index, and the call sites of its calls. The args of a func
+// occupy the first local slots of its frame.
+//
+// Calls of small funcs may be inlined into their callers, in which case the
+// args of the inlined func occupy further local slots of the caller. Each
+// func records the calls inlined into it, so that stack traces can show the
+// frames the inlined funcs would have had.
+package bytecode
+
+import (
+	"fmt"
+
+	"ast/source"
+	"values"
+)
+
+// Op is an opcode.
+type Op uint8
+
+// Opcodes. Unless noted otherwise, an op pops its operands off the stack
+// and pushes its result.
+const (
+	// Step counts the statement at the position of the instruction against
+	// the limits of the execution.
+	Step Op = iota
+	// Const pushes the constant with the index given by the operand.
+	Const
+	// Load pushes the local in the slot given by the operand.
+	Load
+	// Store pops a value into the local slot given by the operand.
+	Store
+	// Pop discards the top of the stack.
+	Pop
+	// Alloc counts the value on top of the stack against the limits of the
+	// execution, leaving it in place.
+	Alloc
+
+	// Binary operators.
+	Add
+	Sub
+	Mul
+	Div
+	Mod
+	Eq
+	NotEq
+	Less
+	LessEq
+	Greater
+	GreaterEq
+
+	// Unary operators.
+	Neg
+	Not
+
+	// Jump continues at the instruction given by the operand.
+	Jump
+	// JumpFalse pops a bool and jumps if it is false.
+	JumpFalse
+	// JumpFalseOrPop jumps, leaving the bool on top of the stack, if it is
+	// false, and pops it otherwise.
+	JumpFalseOrPop
+	// JumpTrueOrPop jumps, leaving the bool on top of the stack, if it is
+	// true, and pops it otherwise.
+	JumpTrueOrPop
+
+	// Call calls the declared func of the call site given by the operand.
+	// It pops the args and pushes the result, or nil if the func does not
+	// return anything.
+	Call
+	// CallBuiltin calls the builtin of the call site given by the operand,
+	// like Call.
+	CallBuiltin
+	// TailCall calls the declared func of the call site given by the
+	// operand in place of the current func, reusing its frame, and returns
+	// the result to the caller of the current func.
+	TailCall
+	// Enter enters the inlined call given by the operand, an index in
+	// Func.Inlined, whose args are on the stack. It fails if the call would
+	// exceed the call depth limit of the execution.
+	Enter
+	// Return pops the result and returns it to the caller.
+	Return
+	// ReturnNil returns nil to the caller.
+	ReturnNil
+	// MissingReturn fails because the end of a func with a return type was
+	// reached.
+	MissingReturn
+
+	numOps
+)
+
+var opNames = [...]string{
+	Step:           "step",
+	Const:          "const",
+	Load:           "load",
+	Store:          "store",
+	Pop:            "pop",
+	Alloc:          "alloc",
+	Add:            "add",
+	Sub:            "sub",
+	Mul:            "mul",
+	Div:            "div",
+	Mod:            "mod",
+	Eq:             "eq",
+	NotEq:          "noteq",
+	Less:           "less",
+	LessEq:         "lesseq",
+	Greater:        "greater",
+	GreaterEq:      "greatereq",
+	Neg:            "neg",
+	Not:            "not",
+	Jump:           "jump",
+	JumpFalse:      "jumpfalse",
+	JumpFalseOrPop: "jumpfalseorpop",
+	JumpTrueOrPop:  "jumptrueorpop",
+	Call:           "call",
+	CallBuiltin:    "callbuiltin",
+	TailCall:       "tailcall",
+	Enter:          "enter",
+	Return:         "return",
+	ReturnNil:      "returnnil",
+	MissingReturn:  "missingreturn",
+}
+
+func (op Op) String() string {
+	if op < numOps {
+		return opNames[op]
+	}
+	return fmt.Sprintf("op(%d)", op)
+}
+
+// HasArg reports whether instructions with the op use their operand.
+func (op Op) HasArg() bool {
+	switch op {
+	case Const, Load, Store, Jump, JumpFalse, JumpFalseOrPop, JumpTrueOrPop, Call, CallBuiltin, TailCall, Enter:
+		return true
+	}
+	return false
+}
+
+// operators are the apl operators of the operator ops.
+var operators = map[Op]string{
+	Add:       "+",
+	Sub:       "-",
+	Mul:       "*",
+	Div:       "/",
+	Mod:       "%",
+	Eq:        "==",
+	NotEq:     "!=",
+	Less:      "<",
+	LessEq:    "<=",
+	Greater:   ">",
+	GreaterEq: ">=",
+	Neg:       "-",
+	Not:       "!",
+}
+
+// Operator returns the apl operator of a binary or unary operator op, or ""
+// for other ops.
+func (op Op) Operator() string {
+	return operators[op]
+}
+
+// BinaryOp returns the op of a binary operator, as in expr.Binary. Reports
+// false for && and ||, which are compiled to jumps, and unknown operators.
+func BinaryOp(op string) (Op, bool) {
+	for o := Add; o <= GreaterEq; o++ {
+		if operators[o] == op {
+			return o, true
+		}
+	}
+	return 0, false
+}
+
+// UnaryOp returns the op of a unary operator, as in expr.Unary. Reports false
+// for unknown operators.
+func UnaryOp(op string) (Op, bool) {
+	switch op {
+	case "-":
+		return Neg, true
+	case "!":
+		return Not, true
+	}
+	return 0, false
+}
+
+// MaxArg is the largest operand of an instruction.
+const MaxArg = 1<<24 - 1
+
+// Instr is an instruction: an op in the low 8 bits and an unsigned operand in
+// the high 24 bits.
+type Instr uint32
+
+// MakeInstr returns the instruction with the op and operand. It panics if
+// the operand is out of range.
+func MakeInstr(op Op, arg int) Instr {
+	if arg < 0 || arg > MaxArg {
+		panic(fmt.Sprintf("bytecode: operand %d of %v out of range", arg, op))
+	}
+	return Instr(uint32(arg)<<8 | uint32(op))
+}
+
+// Op returns the op of the instruction.
+func (i Instr) Op() Op {
+	return Op(i & 0xff)
+}
+
+// Arg returns the operand of the instruction.
+func (i Instr) Arg() int {
+	return int(i >> 8)
+}
+
+func (i Instr) String() string {
+	if i.Op().HasArg() {
+		return fmt.Sprintf("%v %d", i.Op(), i.Arg())
+	}
+	return i.Op().String()
+}
+
+// Pos is a source position. Unlike the positions of AST nodes, it is a plain
+// value, so programs can be stored and compared. It implements
+// source.Source.
+type Pos struct {
+	Path   string
+	Offset int
+	Ln     int // Zero-based line.
+	Col    int // Zero-based column.
+}
+
+// PosOf returns the position of s.
+func PosOf(s source.Source) Pos {
+	return Pos{Path: s.File(), Offset: s.Pos(), Ln: s.Line(), Col: s.LinePos()}
+}
+
+// File returns the path of the file.
+func (p Pos) File() string {
+	return p.Path
+}
+
+// Pos returns the byte offset in the file.
+func (p Pos) Pos() int {
+	return p.Offset
+}
+
+// Line returns the zero-based line.
+func (p Pos) Line() int {
+	return p.Ln
+}
+
+// LinePos returns the zero-based column.
+func (p Pos) LinePos() int {
+	return p.Col
+}
+
+// Errf returns a *source.Error at the position.
+func (p Pos) Errf(format string, args ...interface{}) error {
+	return source.Errorf(p, format, args...)
+}
+
+func (p Pos) String() string {
+	return fmt.Sprintf("%s:%d:%d", p.Path, p.Ln+1, p.Col+1)
+}
+
+// Program is a compiled program.
+type Program struct {
+	Funcs []*Func
+	// Builtins are the names of the builtins called by the program. The
+	// interpreter binds them when it loads the program.
+	Builtins []string
+}
+
+// Func is a compiled func.
+type Func struct {
+	Name    string
+	Module  string   // Import path of the declaring package.
+	Locals  []string // Names of the local slots; the args come first.
+	Args    int      // Number of args.
+	Returns bool     // Whether the func returns a value.
+	Src     Pos      // Position of the declaration.
+
+	Code    []Instr
+	Lines   []Pos // Position of each instruction of Code.
+	Inl     []int // Index in Inlined of the inlined call each instruction of Code belongs to, or -1.
+	Consts  []values.Value
+	Calls   []CallSite
+	Inlined []Inline
+}
+
+// Inline is a call inlined into a func.
+type Inline struct {
+	Func   string // Name of the inlined func.
+	Module string // Import path of the package declaring the inlined func.
+	Src    Pos    // Position of the declaration of the inlined func.
+	Site   Pos    // Position of the call.
+	Parent int    // Index of the inlined call the call is made from, or -1.
+	Depth  int    // Number of nested inlined calls, including this one.
+}
+
+// CallSite is a call made by a func.
+type CallSite struct {
+	Name   string // Name of the callee at the call site.
+	Target int    // Index in Program.Funcs or, for builtins, Program.Builtins.
+	Args   int    // Number of args.
+}
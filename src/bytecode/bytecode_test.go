@@ -0,0 +1,86 @@
+package bytecode
+
+import (
+	"strings"
+	"testing"
+
+	"values"
+)
+
+func TestInstr(t *testing.T) {
+	testCases := []struct {
+		op  Op
+		arg int
+		str string
+	}{
+		{op: Step, str: "step"},
+		{op: Const, arg: 3, str: "const 3"},
+		{op: Jump, arg: MaxArg, str: "jump 16777215"},
+		{op: Add, str: "add"},
+	}
+	for _, tc := range testCases {
+		in := MakeInstr(tc.op, tc.arg)
+		if in.Op() != tc.op || in.Arg() != tc.arg {
+			t.Errorf("MakeInstr(%v, %d) decodes to %v, %d", tc.op, tc.arg, in.Op(), in.Arg())
+		}
+		if in.String() != tc.str {
+			t.Errorf("expected %q but got %q", tc.str, in.String())
+		}
+	}
+}
+
+func TestOperators(t *testing.T) {
+	for _, op := range []string{"+", "-", "*", "/", "%", "==", "!=", "<", "<=", ">", ">="} {
+		o, ok := BinaryOp(op)
+		if !ok || o.Operator() != op {
+			t.Errorf("BinaryOp(%q) = %v, %t", op, o, ok)
+		}
+	}
+	for _, op := range []string{"&&", "||", "!"} {
+		if o, ok := BinaryOp(op); ok {
+			t.Errorf("BinaryOp(%q) = %v, expected none", op, o)
+		}
+	}
+}
+
+func TestDisassemble(t *testing.T) {
+	pos := Pos{Path: "test", Ln: 1, Col: 2}
+	p := &Program{
+		Funcs: []*Func{
+			{
+				Name:   "greet",
+				Module: "test",
+				Locals: []string{"who"},
+				Args:   1,
+				Src:    Pos{Path: "test"},
+				Code: []Instr{
+					MakeInstr(Const, 0),
+					MakeInstr(Load, 0),
+					MakeInstr(CallBuiltin, 0),
+					MakeInstr(Pop, 0),
+					MakeInstr(ReturnNil, 0),
+				},
+				Lines:  []Pos{pos, pos, pos, pos, pos},
+				Consts: []values.Value{&values.String{V: "hi"}},
+				Calls:  []CallSite{{Name: "println", Target: 0, Args: 2}},
+			},
+		},
+		Builtins: []string{"println"},
+	}
+	var b strings.Builder
+	if err := Disassemble(&b, p); err != nil {
+		t.Fatal(err)
+	}
+	want := `func test.greet (1 args) test:1:1
+  locals: who
+  consts: 0="hi"
+  0000  2:3     const 0               ; "hi"
+  0001  2:3     load 0                ; who
+  0002  2:3     callbuiltin 0         ; println/2
+  0003  2:3     pop
+  0004  2:3     returnnil
+`
+	if got := b.String(); got != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, got)
+	}
+}
@@ -0,0 +1,95 @@
+package bytecode
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"values"
+)
+
+// Disassemble writes a listing of the program to w. Each func is listed with
+// its locals, constants and inlined calls, followed by one line per
+// instruction giving its index, its line:col in the file declaring the func,
+// the instruction and, where helpful, a comment on its operand. The line:col
+// of an instruction of an inlined func is in the file declaring that func,
+// and followed by @ and the index of the inlined call.
+func Disassemble(w io.Writer, p *Program) error {
+	var b strings.Builder
+	for i, f := range p.Funcs {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		disasmFunc(&b, p, f)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func disasmFunc(w io.Writer, p *Program, f *Func) {
+	ret := ""
+	if f.Returns {
+		ret = ", returns"
+	}
+	fmt.Fprintf(w, "func %s.%s (%d args%s) %v\n", f.Module, f.Name, f.Args, ret, f.Src)
+	if len(f.Locals) > 0 {
+		fmt.Fprintf(w, "  locals: %s\n", strings.Join(f.Locals, ", "))
+	}
+	if len(f.Consts) > 0 {
+		consts := make([]string, len(f.Consts))
+		for i, c := range f.Consts {
+			consts[i] = fmt.Sprintf("%d=%s", i, constant(c))
+		}
+		fmt.Fprintf(w, "  consts: %s\n", strings.Join(consts, ", "))
+	}
+	if len(f.Inlined) > 0 {
+		inlined := make([]string, len(f.Inlined))
+		for i, inl := range f.Inlined {
+			inlined[i] = fmt.Sprintf("%d=%s.%s at %d:%d", i, inl.Module, inl.Func, inl.Site.Ln+1, inl.Site.Col+1)
+		}
+		fmt.Fprintf(w, "  inlined: %s\n", strings.Join(inlined, ", "))
+	}
+	for pc, in := range f.Code {
+		pos := f.Lines[pc]
+		at := fmt.Sprintf("%d:%d", pos.Ln+1, pos.Col+1)
+		if pc < len(f.Inl) && f.Inl[pc] >= 0 {
+			at += fmt.Sprintf("@%d", f.Inl[pc])
+		}
+		line := fmt.Sprintf("  %04d  %-6s  %v", pc, at, in)
+		if c := comment(p, f, in); c != "" {
+			line = fmt.Sprintf("%-36s  ; %s", line, c)
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+// comment returns a description of the operand of an instruction, or "".
+func comment(p *Program, f *Func, in Instr) string {
+	arg := in.Arg()
+	switch in.Op() {
+	case Const:
+		return constant(f.Consts[arg])
+	case Load, Store:
+		return f.Locals[arg]
+	case Call, TailCall:
+		c := f.Calls[arg]
+		callee := p.Funcs[c.Target]
+		return fmt.Sprintf("%s.%s/%d", callee.Module, callee.Name, c.Args)
+	case Enter:
+		inl := f.Inlined[arg]
+		return fmt.Sprintf("%s.%s", inl.Module, inl.Func)
+	case CallBuiltin:
+		c := f.Calls[arg]
+		return fmt.Sprintf("%s/%d", p.Builtins[c.Target], c.Args)
+	}
+	return ""
+}
+
+// constant returns a constant as written in apl source.
+func constant(v values.Value) string {
+	if s, ok := v.(*values.String); ok {
+		return strconv.Quote(s.V)
+	}
+	return v.String()
+}
@@ -0,0 +1,253 @@
+// Package graph builds the call graph and the import graph of checked apl
+// programs, and writes them as Graphviz DOT or JSON.
+//
+// The nodes of a call graph are the declared funcs of a program, and its
+// edges the calls between them, one per call site. Calls of builtins are left
+// out. Nodes and edges that are part of a cycle, such as recursive funcs, are
+// marked, as are funcs that cannot be reached from main, test or exported
+// funcs. The nodes of an import graph are the packages of a program, and its
+// edges the import statements. Checked programs have no import cycles.
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"ast"
+	"ast/expr"
+	"ast/source"
+	"ast/statement"
+	"interp"
+	"types"
+)
+
+// Graph is a call or import graph.
+type Graph struct {
+	Kind  string  `json:"kind"` // "calls" or "imports".
+	Nodes []*Node `json:"nodes"`
+	Edges []*Edge `json:"edges"`
+}
+
+// Node is a func of a call graph or a package of an import graph.
+type Node struct {
+	ID          string `json:"id"`
+	Module      string `json:"module"`         // Import path of the package.
+	Func        string `json:"func,omitempty"` // Name of the func, for call graphs.
+	Pos         Pos    `json:"pos"`            // Declaration, or first file of the package.
+	Cycle       bool   `json:"cycle,omitempty"`
+	Unreachable bool   `json:"unreachable,omitempty"`
+
+	Decl *ast.FnDecl `json:"-"` // Declaration of the func, for call graphs.
+}
+
+// Edge is a call of a call graph or an import of an import graph.
+type Edge struct {
+	From  string `json:"from"` // ID of the calling func or importing package.
+	To    string `json:"to"`   // ID of the callee or imported package.
+	Pos   Pos    `json:"pos"`  // Call or import statement.
+	Cycle bool   `json:"cycle,omitempty"`
+}
+
+// Pos is a position in a source file. Lines and columns are one-based.
+type Pos struct {
+	File string `json:"file"`
+	Line int    `json:"line"`
+	Col  int    `json:"col"`
+}
+
+func posOf(s source.Source) Pos {
+	return Pos{File: s.File(), Line: s.Line() + 1, Col: s.LinePos() + 1}
+}
+
+func (p Pos) String() string {
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Col)
+}
+
+// Calls checks the package at an import path and the packages it imports
+// with e, and returns their call graph. The IDs of funcs are their names
+// qualified by the import paths of their packages. Main and test funcs, see
+// Executor.Tests, are only assumed to be called in the package at path.
+// Returns the error of Check if the program does not check.
+func Calls(e *interp.Executor, path string) (*Graph, error) {
+	pkgs, err := e.Packages(path)
+	if err != nil {
+		return nil, err
+	}
+	g := &Graph{Kind: "calls"}
+	var roots []string
+	for _, p := range pkgs {
+		for _, f := range p.Files {
+			for _, decl := range f.Decls {
+				d, ok := decl.(*ast.FnDecl)
+				if !ok {
+					continue
+				}
+				n := &Node{ID: p.Path + "." + d.Nam, Module: p.Path, Func: d.Nam, Pos: posOf(d), Decl: d}
+				g.Nodes = append(g.Nodes, n)
+				if d.Export || types.IsExported(d.Nam) || p == pkgs[0] && (d.Nam == "main" || strings.HasPrefix(d.Nam, interp.TestPrefix)) {
+					roots = append(roots, n.ID)
+				}
+				ast.Inspect(d, func(node ast.Node) bool {
+					var name string
+					switch node := node.(type) {
+					case *expr.Call:
+						name = node.Nam
+					case *statement.FnCall:
+						name = node.Nam
+					default:
+						return true
+					}
+					if path, local, err := p.Scope[f].Resolve(name); err == nil && path != "" {
+						g.Edges = append(g.Edges, &Edge{From: n.ID, To: path + "." + local, Pos: posOf(node)})
+					}
+					return true
+				})
+			}
+		}
+	}
+	g.markCycles()
+	reached := g.reach(roots)
+	for _, n := range g.Nodes {
+		n.Unreachable = !reached[n.ID]
+	}
+	return g, nil
+}
+
+// Imports checks the package at an import path and the packages it imports
+// with e, and returns their import graph. The IDs of packages are their
+// import paths. Returns the error of Check if the program does not check.
+func Imports(e *interp.Executor, path string) (*Graph, error) {
+	pkgs, err := e.Packages(path)
+	if err != nil {
+		return nil, err
+	}
+	g := &Graph{Kind: "imports"}
+	for _, p := range pkgs {
+		n := &Node{ID: p.Path, Module: p.Path}
+		if len(p.Files) > 0 {
+			n.Pos = posOf(p.Files[0])
+		}
+		g.Nodes = append(g.Nodes, n)
+		for _, f := range p.Files {
+			for _, imp := range f.Imports {
+				g.Edges = append(g.Edges, &Edge{From: p.Path, To: imp.Name, Pos: posOf(imp)})
+			}
+		}
+	}
+	return g, nil
+}
+
+// markCycles marks the nodes and edges that are part of a cycle, using
+// Tarjan's algorithm for strongly connected components.
+func (g *Graph) markCycles() {
+	out := make(map[string][]*Edge)
+	for _, e := range g.Edges {
+		out[e.From] = append(out[e.From], e)
+	}
+	index := make(map[string]int)
+	low := make(map[string]int)
+	onStack := make(map[string]bool)
+	comp := make(map[string]int) // Component of each node, numbered from 1.
+	var stack []string
+	var strong func(id string)
+	strong = func(id string) {
+		index[id] = len(index) + 1
+		low[id] = index[id]
+		stack = append(stack, id)
+		onStack[id] = true
+		for _, e := range out[id] {
+			if index[e.To] == 0 {
+				strong(e.To)
+				if low[e.To] < low[id] {
+					low[id] = low[e.To]
+				}
+			} else if onStack[e.To] && index[e.To] < low[id] {
+				low[id] = index[e.To]
+			}
+		}
+		if low[id] != index[id] {
+			return
+		}
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			comp[top] = index[id]
+			if top == id {
+				break
+			}
+		}
+	}
+	for _, n := range g.Nodes {
+		if index[n.ID] == 0 {
+			strong(n.ID)
+		}
+	}
+	cyclic := make(map[string]bool)
+	for _, e := range g.Edges {
+		if comp[e.From] == comp[e.To] {
+			e.Cycle = true
+			cyclic[e.From] = true
+		}
+	}
+	for _, n := range g.Nodes {
+		n.Cycle = cyclic[n.ID]
+	}
+}
+
+// reach returns the IDs of the nodes reachable from the roots.
+func (g *Graph) reach(roots []string) map[string]bool {
+	out := make(map[string][]string)
+	for _, e := range g.Edges {
+		out[e.From] = append(out[e.From], e.To)
+	}
+	reached := make(map[string]bool)
+	for len(roots) > 0 {
+		id := roots[len(roots)-1]
+		roots = roots[:len(roots)-1]
+		if !reached[id] {
+			reached[id] = true
+			roots = append(roots, out[id]...)
+		}
+	}
+	return reached
+}
+
+// WriteJSON writes the graph to w as indented JSON.
+func (g *Graph) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(g)
+}
+
+// WriteDOT writes the graph to w in the Graphviz DOT language. Nodes and
+// edges are labeled with their positions. Cycles are drawn in red, and
+// unreachable funcs dashed and grey.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %s {\n", g.Kind)
+	b.WriteString("\tnode [shape=box];\n")
+	for _, n := range g.Nodes {
+		attrs := []string{"label=" + strconv.Quote(n.ID+"\n"+n.Pos.String())}
+		if n.Cycle {
+			attrs = append(attrs, "color=red")
+		}
+		if n.Unreachable {
+			attrs = append(attrs, "style=dashed", "fontcolor=grey")
+		}
+		fmt.Fprintf(&b, "\t%s [%s];\n", strconv.Quote(n.ID), strings.Join(attrs, ", "))
+	}
+	for _, e := range g.Edges {
+		attrs := []string{"label=" + strconv.Quote(e.Pos.String())}
+		if e.Cycle {
+			attrs = append(attrs, "color=red")
+		}
+		fmt.Fprintf(&b, "\t%s -> %s [%s];\n", strconv.Quote(e.From), strconv.Quote(e.To), strings.Join(attrs, ", "))
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,184 @@
+package graph
+
+import (
+	"strings"
+	"testing"
+
+	"interp"
+)
+
+func TestCalls(t *testing.T) {
+	e := interp.NewExecutor(interp.NewStringLoader(map[string]string{
+		"main.apl": `import lib;
+
+func odd(int n) bool {
+  return n != 0 && !odd(n - 1);
+}
+
+func fact(int n) int {
+  if n < 2 {
+    return 1;
+  }
+  return n * fact(lib.Dec(n));
+}
+
+func unused() {
+  println(fact(3));
+}
+
+func main() {
+  println(odd(3), fact(5));
+}
+`,
+		"lib.apl": `export func Dec(int n) int {
+  return n - 1;
+}
+
+func main() {
+}
+`,
+	}))
+	g, err := Calls(e, "main.apl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	if err := g.WriteDOT(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `digraph calls {
+	node [shape=box];
+	"main.apl.odd" [label="main.apl.odd\nmain.apl:3:1", color=red];
+	"main.apl.fact" [label="main.apl.fact\nmain.apl:7:1", color=red];
+	"main.apl.unused" [label="main.apl.unused\nmain.apl:14:1", style=dashed, fontcolor=grey];
+	"main.apl.main" [label="main.apl.main\nmain.apl:18:1"];
+	"lib.Dec" [label="lib.Dec\nlib.apl:1:8"];
+	"lib.main" [label="lib.main\nlib.apl:5:1", style=dashed, fontcolor=grey];
+	"main.apl.odd" -> "main.apl.odd" [label="main.apl:4:21", color=red];
+	"main.apl.fact" -> "main.apl.fact" [label="main.apl:11:14", color=red];
+	"main.apl.fact" -> "lib.Dec" [label="main.apl:11:19"];
+	"main.apl.unused" -> "main.apl.fact" [label="main.apl:15:11"];
+	"main.apl.main" -> "main.apl.odd" [label="main.apl:19:11"];
+	"main.apl.main" -> "main.apl.fact" [label="main.apl:19:19"];
+}
+`
+	if got := b.String(); got != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestImports(t *testing.T) {
+	e := interp.NewExecutor(interp.NewStringLoader(map[string]string{
+		"main": `import a;
+import c;
+
+func main() {
+  a.A();
+}
+`,
+		"a": `import b;
+
+func A() {
+}
+`,
+		"b": `import c;
+
+func B() {
+}
+`,
+		"c": `func C() {
+}
+`,
+	}))
+	g, err := Imports(e, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	if err := g.WriteJSON(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+  "kind": "imports",
+  "nodes": [
+    {
+      "id": "main",
+      "module": "main",
+      "pos": {
+        "file": "main",
+        "line": 1,
+        "col": 1
+      }
+    },
+    {
+      "id": "a",
+      "module": "a",
+      "pos": {
+        "file": "a",
+        "line": 1,
+        "col": 1
+      }
+    },
+    {
+      "id": "b",
+      "module": "b",
+      "pos": {
+        "file": "b",
+        "line": 1,
+        "col": 1
+      }
+    },
+    {
+      "id": "c",
+      "module": "c",
+      "pos": {
+        "file": "c",
+        "line": 1,
+        "col": 1
+      }
+    }
+  ],
+  "edges": [
+    {
+      "from": "main",
+      "to": "a",
+      "pos": {
+        "file": "main",
+        "line": 1,
+        "col": 1
+      }
+    },
+    {
+      "from": "main",
+      "to": "c",
+      "pos": {
+        "file": "main",
+        "line": 2,
+        "col": 1
+      }
+    },
+    {
+      "from": "a",
+      "to": "b",
+      "pos": {
+        "file": "a",
+        "line": 1,
+        "col": 1
+      }
+    },
+    {
+      "from": "b",
+      "to": "c",
+      "pos": {
+        "file": "b",
+        "line": 1,
+        "col": 1
+      }
+    }
+  ]
+}
+`
+	if got := b.String(); got != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, got)
+	}
+}
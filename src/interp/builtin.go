@@ -0,0 +1,74 @@
+package interp
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"types"
+	"values"
+)
+
+// Builtin is a func implemented in Go. Builtins are declared in the root
+// context, so they are visible unqualified from every package.
+type Builtin struct {
+	Name string
+	Type *types.Func
+	// Fn is called with the evaluated args, whose types match Type. It must
+	// return a nil value if Type has no return type.
+	Fn func(e *Executor, args []values.Value) (values.Value, error)
+}
+
+// builtins are the builtins registered with every Executor.
+var builtins = []*Builtin{
+	{
+		// assert fails with msg, positioned at the call, if cond is false.
+		Name: "assert",
+		Type: &types.Func{Args: []types.Type{&types.Bool{}, &types.String{}}},
+		Fn: func(e *Executor, args []values.Value) (values.Value, error) {
+			if !args[0].(*values.Bool).V {
+				return nil, errors.New(args[1].(*values.String).V)
+			}
+			return nil, nil
+		},
+	},
+	{
+		Name: "print",
+		Type: &types.Func{Args: []types.Type{&types.Any{}}, Variadic: true},
+		Fn: func(e *Executor, args []values.Value) (values.Value, error) {
+			return nil, e.print(args, "", "")
+		},
+	},
+	{
+		Name: "println",
+		Type: &types.Func{Args: []types.Type{&types.Any{}}, Variadic: true},
+		Fn: func(e *Executor, args []values.Value) (values.Value, error) {
+			return nil, e.print(args, " ", "\n")
+		},
+	},
+}
+
+// print writes args to the output, separated by sep and followed by end.
+// Strings are written without quotes.
+func (e *Executor) print(args []values.Value, sep, end string) error {
+	strs := make([]string, len(args))
+	for i, arg := range args {
+		strs[i] = arg.String()
+	}
+	_, err := fmt.Fprint(e.out, strings.Join(strs, sep)+end)
+	return err
+}
+
+// Register registers a builtin with the Executor. Returns an error if its
+// name is already taken by a builtin or a builtin type, or if it is variadic
+// without args.
+func (e *Executor) Register(b *Builtin) error {
+	if b.Type.Variadic && len(b.Type.Args) == 0 {
+		return fmt.Errorf("variadic builtin %s takes no args", b.Name)
+	}
+	if err := e.tc.Add(b.Name, b.Type); err != nil {
+		return err
+	}
+	e.builtins[b.Name] = b
+	return nil
+}
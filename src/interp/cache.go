@@ -0,0 +1,459 @@
+package interp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"ast"
+	"ast/expr"
+	"ast/statement"
+	"parser"
+	"types"
+	"values"
+)
+
+// cacheVersion is part of every cache key. It must be bumped whenever the
+// format of cache entries or the meaning of checked ASTs changes.
+const cacheVersion = 1
+
+// cacheMagic starts every cache file, followed by the SHA-256 of the rest.
+const cacheMagic = "apl cache\n"
+
+func init() {
+	gob.Register(parser.TokenSource{})
+	gob.Register(&ast.FnDecl{})
+	gob.Register(&statement.Import{})
+	gob.Register(&statement.Return{})
+	gob.Register(&statement.FnCall{})
+	gob.Register(&statement.Block{})
+	gob.Register(&statement.If{})
+	gob.Register(&expr.Value{})
+	gob.Register(&expr.Ident{})
+	gob.Register(&expr.Call{})
+	gob.Register(&expr.Binary{})
+	gob.Register(&expr.Unary{})
+	gob.Register(&values.Int{})
+	gob.Register(&values.Float{})
+	gob.Register(&values.Bool{})
+	gob.Register(&values.String{})
+}
+
+// SetCacheDir enables caching of checked packages in dir, which is created if
+// needed. A package is stored with the parsed ASTs of its files and the
+// signatures of its declarations, keyed by the content of its files and the
+// keys of its imports. Later checks of the package, also by other Executors,
+// skip parsing and checking its files as long as neither they nor any of its
+// transitive imports change. Stale and corrupt entries are rebuilt. An empty
+// dir disables caching.
+func (e *Executor) SetCacheDir(dir string) {
+	if dir == "" {
+		e.cache = nil
+		return
+	}
+	e.cache = &diskCache{dir: dir}
+}
+
+// diskCache stores cache entries as files in a directory, one per import
+// path.
+type diskCache struct {
+	dir string
+}
+
+// cacheEntry is a checked package.
+type cacheEntry struct {
+	Path    string // Import path.
+	Key     string
+	Files   []cachedFile
+	Imports []cachedImport
+	Decls   []cachedDecl
+}
+
+// cachedFile is a file of a cached package.
+type cachedFile struct {
+	Path string
+	Hash string // Hash of the source.
+	AST  *ast.File
+}
+
+// cachedImport is a package imported by a cached package.
+type cachedImport struct {
+	Path string
+	Key  string
+}
+
+// cachedDecl is a declaration of a cached package.
+type cachedDecl struct {
+	Name   string
+	Type   *sig
+	Export bool // Declared with the export modifier.
+}
+
+// sig is the serializable form of a types.Type.
+type sig struct {
+	Kind     string // Spelling of basic types, or list, map, struct or func.
+	Key      *sig
+	Elem     *sig
+	Fields   []sigField
+	Args     []*sig
+	Return   *sig
+	Variadic bool
+}
+
+// sigField is a field of a struct sig.
+type sigField struct {
+	Name string
+	Type *sig
+}
+
+// sigOf returns the sig of t, which may be nil.
+func sigOf(t types.Type) *sig {
+	switch t := t.(type) {
+	case nil:
+		return nil
+	case *types.Int:
+		return &sig{Kind: "int"}
+	case *types.Float:
+		return &sig{Kind: "float"}
+	case *types.Bool:
+		return &sig{Kind: "bool"}
+	case *types.String:
+		return &sig{Kind: "string"}
+	case *types.Any:
+		return &sig{Kind: "any"}
+	case *types.List:
+		return &sig{Kind: "list", Elem: sigOf(t.Elem)}
+	case *types.Map:
+		return &sig{Kind: "map", Key: sigOf(t.Key), Elem: sigOf(t.Elem)}
+	case *types.Struct:
+		s := &sig{Kind: "struct"}
+		for _, f := range t.Fields {
+			s.Fields = append(s.Fields, sigField{Name: f.Name, Type: sigOf(f.Type)})
+		}
+		return s
+	case *types.Func:
+		s := &sig{Kind: "func", Return: sigOf(t.Return), Variadic: t.Variadic}
+		for _, arg := range t.Args {
+			s.Args = append(s.Args, sigOf(arg))
+		}
+		return s
+	}
+	panic(fmt.Sprintf("interp: unexpected type %T", t))
+}
+
+// typ returns the type of s, which may be nil.
+func (s *sig) typ() (types.Type, error) {
+	if s == nil {
+		return nil, nil
+	}
+	switch s.Kind {
+	case "int":
+		return &types.Int{}, nil
+	case "float":
+		return &types.Float{}, nil
+	case "bool":
+		return &types.Bool{}, nil
+	case "string":
+		return &types.String{}, nil
+	case "any":
+		return &types.Any{}, nil
+	case "list":
+		elem, err := s.Elem.typ()
+		if err != nil {
+			return nil, err
+		}
+		return &types.List{Elem: elem}, nil
+	case "map":
+		key, err := s.Key.typ()
+		if err != nil {
+			return nil, err
+		}
+		elem, err := s.Elem.typ()
+		if err != nil {
+			return nil, err
+		}
+		return &types.Map{Key: key, Elem: elem}, nil
+	case "struct":
+		t := &types.Struct{}
+		for _, f := range s.Fields {
+			typ, err := f.Type.typ()
+			if err != nil {
+				return nil, err
+			}
+			t.Fields = append(t.Fields, &types.Field{Name: f.Name, Type: typ})
+		}
+		return t, nil
+	case "func":
+		t := &types.Func{Variadic: s.Variadic}
+		for _, arg := range s.Args {
+			typ, err := arg.typ()
+			if err != nil {
+				return nil, err
+			}
+			t.Args = append(t.Args, typ)
+		}
+		ret, err := s.Return.typ()
+		if err != nil {
+			return nil, err
+		}
+		t.Return = ret
+		return t, nil
+	}
+	return nil, fmt.Errorf("unknown type %q", s.Kind)
+}
+
+// file returns the path of the entry for an import path.
+func (c *diskCache) file(path string) string {
+	h := sha256.Sum256([]byte(path))
+	return filepath.Join(c.dir, hex.EncodeToString(h[:16]))
+}
+
+// get returns the entry for an import path, or nil if there is none. Corrupt
+// entries are removed.
+func (c *diskCache) get(path string) *cacheEntry {
+	data, err := ioutil.ReadFile(c.file(path))
+	if err != nil {
+		return nil
+	}
+	entry, err := decodeEntry(data)
+	if err != nil || entry.Path != path {
+		os.Remove(c.file(path))
+		return nil
+	}
+	return entry
+}
+
+// put stores an entry, replacing any previous entry for its import path.
+func (c *diskCache) put(entry *cacheEntry) error {
+	data, err := encodeEntry(entry)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(c.dir, "tmp-")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		// Rename atomically, so concurrent readers never see partial entries.
+		err = os.Rename(f.Name(), c.file(entry.Path))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+func encodeEntry(entry *cacheEntry) ([]byte, error) {
+	var payload bytes.Buffer
+	if err := gob.NewEncoder(&payload).Encode(entry); err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(payload.Bytes())
+	var b bytes.Buffer
+	b.WriteString(cacheMagic)
+	b.Write(sum[:])
+	b.Write(payload.Bytes())
+	return b.Bytes(), nil
+}
+
+func decodeEntry(data []byte) (*cacheEntry, error) {
+	if !bytes.HasPrefix(data, []byte(cacheMagic)) || len(data) < len(cacheMagic)+sha256.Size {
+		return nil, errors.New("not a cache entry")
+	}
+	data = data[len(cacheMagic):]
+	payload := data[sha256.Size:]
+	if sum := sha256.Sum256(payload); !bytes.Equal(sum[:], data[:sha256.Size]) {
+		return nil, errors.New("checksum mismatch")
+	}
+	var entry cacheEntry
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// hash returns the hash of the source of a file.
+func (e *Executor) hash(path string) (string, error) {
+	src, err := e.load(path)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.Sum256(src)
+	return hex.EncodeToString(h[:]), nil
+}
+
+// cacheKey returns the key of the package at an import path, made of the
+// builtins registered with the Executor, the given files with their hashes
+// and the given imports with their keys. Cached packages are not checked
+// again, so the key must change whenever a builtin they may call does.
+func (e *Executor) cacheKey(path string, files []cachedFile, imports []cachedImport) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "v%d %q\n", cacheVersion, path)
+	var names []string
+	for name := range e.builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		b := e.builtins[name]
+		fmt.Fprintf(h, "builtin %q\n", b.Type.Signature(name))
+	}
+	for _, f := range files {
+		fmt.Fprintf(h, "file %q %s\n", f.Path, f.Hash)
+	}
+	for _, imp := range imports {
+		fmt.Fprintf(h, "import %q %s\n", imp.Path, imp.Key)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// parseCached returns the parsed file for path as parse does, but takes the
+// AST from the cache entry of its package if the entry has one for the
+// current source of the file.
+func (e *Executor) parseCached(path string, entry *cacheEntry) (*ast.File, error) {
+	if entry == nil {
+		return e.parse(path)
+	}
+	if file := e.File(path); file != nil {
+		return file, nil
+	}
+	hash, err := e.hash(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range entry.Files {
+		if f.Path == path && f.Hash == hash && f.AST != nil {
+			e.mu.Lock()
+			e.files[path] = f.AST
+			e.mu.Unlock()
+			return f.AST, nil
+		}
+	}
+	return e.parse(path)
+}
+
+// key returns the cache key of a checked import path.
+func (e *Executor) key(path string) (string, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	key, ok := e.keys[path]
+	return key, ok
+}
+
+// setKey sets the cache key of a checked import path.
+func (e *Executor) setKey(path, key string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.keys[path] = key
+}
+
+// checkCached checks a unit from its cache entry, once its imports are
+// checked. Reports false, leaving the package unchecked, if the entry does
+// not match the files of the unit or the keys of its imports.
+func (e *Executor) checkCached(u *unit) (bool, error) {
+	entry := u.entry
+	if len(entry.Files) != len(u.paths) {
+		return false, nil
+	}
+	for i, f := range entry.Files {
+		hash, err := e.hash(u.paths[i])
+		if err != nil {
+			return false, err
+		}
+		if f.Path != u.paths[i] || f.Hash != hash || f.AST == nil {
+			return false, nil
+		}
+	}
+	for _, imp := range entry.Imports {
+		if key, ok := e.key(imp.Path); !ok || key != imp.Key {
+			return false, nil
+		}
+	}
+	if e.cacheKey(u.path, entry.Files, entry.Imports) != entry.Key {
+		return false, nil
+	}
+	pkg := e.tc.Package(u.path)
+	for _, d := range entry.Decls {
+		typ, err := d.Type.typ()
+		if err != nil {
+			return false, err
+		}
+		if err := pkg.Add(d.Name, typ); err != nil {
+			return false, err
+		}
+		if d.Export {
+			if err := pkg.Export(d.Name); err != nil {
+				return false, err
+			}
+		}
+	}
+	for i, f := range entry.Files {
+		scope := pkg.File()
+		u.files[i] = f.AST
+		u.scopes = append(u.scopes, scope)
+		for _, imp := range f.AST.Imports {
+			if _, err := imp.Check(scope); err != nil {
+				return false, err
+			}
+		}
+	}
+	e.setKey(u.path, entry.Key)
+	return true, nil
+}
+
+// storeCached stores a checked unit in the cache. Failing to store it is not
+// an error, as the cache only saves work.
+func (e *Executor) storeCached(u *unit) {
+	entry := &cacheEntry{Path: u.path}
+	imports := make(map[string]bool)
+	for i, file := range u.files {
+		hash, err := e.hash(u.paths[i])
+		if err != nil {
+			return
+		}
+		entry.Files = append(entry.Files, cachedFile{Path: u.paths[i], Hash: hash, AST: file})
+		for _, imp := range file.Imports {
+			imports[imp.Name] = true
+		}
+		for _, decl := range file.Decls {
+			d, ok := decl.(*ast.FnDecl)
+			if !ok {
+				continue
+			}
+			typ, err := u.scopes[i].Get(d.Nam)
+			if err != nil {
+				return
+			}
+			entry.Decls = append(entry.Decls, cachedDecl{Name: d.Nam, Type: sigOf(typ), Export: d.Export})
+		}
+	}
+	var names []string
+	for name := range imports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		key, ok := e.key(name)
+		if !ok {
+			return
+		}
+		entry.Imports = append(entry.Imports, cachedImport{Path: name, Key: key})
+	}
+	entry.Key = e.cacheKey(u.path, entry.Files, entry.Imports)
+	e.setKey(u.path, entry.Key)
+	e.cache.put(entry)
+}
@@ -0,0 +1,184 @@
+package interp
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apl-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src := map[string]string{
+		"main.apl": `import lib;
+import util { Greet };
+
+func main() {
+  println(lib.Sum(1, 2), Greet("apl"));
+}
+`,
+		"lib.apl": `import util;
+
+export func Sum(int x, int y) int {
+  return util.Twice(x) + y;
+}
+`,
+		"util/a.apl": `func Twice(int x) int {
+  return 2 * x;
+}
+`,
+		"util/b.apl": `func Greet(string who) string {
+  return "hello " + who;
+}
+`,
+	}
+	corrupt := func(b []byte) []byte {
+		b[len(b)-1] ^= 0xff
+		return b
+	}
+	testCases := []struct {
+		name   string
+		change func()
+		output string
+		parsed int
+	}{
+		{
+			name:   "cold",
+			output: "4 hello apl\n",
+			parsed: 4,
+		},
+		{
+			name:   "warm",
+			output: "4 hello apl\n",
+			parsed: 0,
+		},
+		{
+			name: "changed_main",
+			change: func() {
+				src["main.apl"] = strings.Replace(src["main.apl"], "1, 2", "2, 1", 1)
+			},
+			output: "5 hello apl\n",
+			parsed: 1,
+		},
+		{
+			name: "changed_import",
+			change: func() {
+				src["util/a.apl"] = strings.Replace(src["util/a.apl"], "2 * x", "3 * x", 1)
+			},
+			output: "7 hello apl\n",
+			parsed: 1,
+		},
+		{
+			name: "corrupt",
+			change: func() {
+				files, err := filepath.Glob(filepath.Join(dir, "*"))
+				if err != nil || len(files) != 3 {
+					t.Fatalf("expected 3 cache files, got %v: %v", files, err)
+				}
+				for i, file := range files {
+					b, err := ioutil.ReadFile(file)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if i == 0 {
+						b = corrupt(b)
+					} else {
+						b = b[:len(b)/2]
+					}
+					if err := ioutil.WriteFile(file, b, 0644); err != nil {
+						t.Fatal(err)
+					}
+				}
+			},
+			output: "7 hello apl\n",
+			parsed: 4,
+		},
+		{
+			name:   "rebuilt",
+			output: "7 hello apl\n",
+			parsed: 0,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.change != nil {
+				tc.change()
+			}
+			e := NewExecutor(NewStringLoader(src))
+			e.SetCacheDir(dir)
+			var out strings.Builder
+			e.SetOutput(&out)
+			if err := e.Run(context.Background(), "main.apl"); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tc.output {
+				t.Errorf("expected output %q but got %q", tc.output, out.String())
+			}
+			if e.parsed != tc.parsed {
+				t.Errorf("expected %d files parsed but got %d", tc.parsed, e.parsed)
+			}
+			if len(e.Paths()) != 4 {
+				t.Errorf("expected 4 files loaded but got %v", e.Paths())
+			}
+		})
+	}
+}
+
+func TestCacheBuiltins(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apl-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src := map[string]string{
+		"main": `func main() {
+  println(hello("apl"));
+}
+`,
+	}
+	testCases := []struct {
+		name  string
+		hello interface{} // Registered as the builtin hello, unless nil.
+		err   string
+	}{
+		{
+			name:  "cold",
+			hello: func(s string) string { return "hello " + s },
+		},
+		{
+			name:  "warm",
+			hello: func(s string) string { return "hi " + s },
+		},
+		{
+			name:  "changed",
+			hello: func(n int) string { return "hello" },
+			err:   "main:2:11 hello param #1 expects type<int>, not type<string>",
+		},
+		{
+			name: "removed",
+			err:  "main:2:11 unknown type or func: hello",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := NewExecutor(NewStringLoader(src))
+			e.SetCacheDir(dir)
+			e.SetOutput(ioutil.Discard)
+			if tc.hello != nil {
+				register(t, e, "hello", tc.hello)
+			}
+			err := e.Check("main")
+			if tc.err == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			} else if tc.err != "" && (err == nil || err.Error() != tc.err) {
+				t.Fatalf("expected %q but got %v", tc.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package interp
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// countingLoader counts the successful loads of each path.
+type countingLoader struct {
+	Loader
+	loads map[string]int
+}
+
+func (l *countingLoader) Load(path string) (Loadable, error) {
+	r, err := l.Loader.Load(path)
+	if err == nil {
+		l.loads[path]++
+	}
+	return r, err
+}
+
+func TestCall(t *testing.T) {
+	loader := &countingLoader{
+		Loader: NewStringLoader(map[string]string{
+			"lib": `
+import util;
+func Add(int x, int y) int {
+  return util.Twice(x) + y;
+}
+func greet(string name, float score) string {
+  if score > 0.5 {
+    return "well done " + name;
+  }
+  return "try again " + name;
+}
+func noop() {}
+`,
+			"util": `func Twice(int x) int { return 2 * x; }`,
+		}),
+		loads: make(map[string]int),
+	}
+	e := NewExecutor(loader)
+	ctx := context.Background()
+	testCases := []struct {
+		fn     string
+		args   []interface{}
+		result interface{}
+		err    string
+	}{
+		{fn: "Add", args: []interface{}{1, 2}, result: 4},
+		{fn: "Add", args: []interface{}{20, 2}, result: 42},
+		{fn: "greet", args: []interface{}{"apl", 0.75}, result: "well done apl"},
+		{fn: "greet", args: []interface{}{"apl", 0.25}, result: "try again apl"},
+		{fn: "noop", result: nil},
+		{fn: "Add", args: []interface{}{1}, err: "Add expects 2 args, not 1"},
+		{fn: "Add", args: []interface{}{1, "2"}, err: "Add arg #2 expects type<int>, not Go string"},
+		{fn: "Add", args: []interface{}{1, nil}, err: "Add arg #2 expects type<int>, not Go <nil>"},
+		{fn: "greet", args: []interface{}{"apl", 1}, err: "greet arg #2 expects type<float>, not Go int"},
+		{fn: "missing", err: "missing not declared in module lib"},
+	}
+	for _, tc := range testCases {
+		result, err := e.Call(ctx, "lib", tc.fn, tc.args...)
+		if tc.err == "" && err != nil {
+			t.Errorf("%s%v: unexpected error: %s", tc.fn, tc.args, err)
+		} else if tc.err != "" && (err == nil || err.Error() != tc.err) {
+			t.Errorf("%s%v: expected %q but got %v", tc.fn, tc.args, tc.err, err)
+		}
+		if !reflect.DeepEqual(result, tc.result) {
+			t.Errorf("%s%v: expected %#v but got %#v", tc.fn, tc.args, tc.result, result)
+		}
+	}
+	expected := map[string]int{"lib": 1, "util": 1}
+	if !reflect.DeepEqual(loader.loads, expected) {
+		t.Errorf("expected loads %v but got %v", expected, loader.loads)
+	}
+}
+
+func TestCallCanceled(t *testing.T) {
+	e := NewExecutor(NewStringLoader(map[string]string{
+		"lib": `
+func Loop(int x) int {
+  return Loop(x + 1);
+}
+`,
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := e.Call(ctx, "lib", "Loop", 0)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected %v but got %v", context.Canceled, err)
+	}
+	if err.Error() != "lib:3:3 context canceled" {
+		t.Errorf("expected positioned error but got %q", err)
+	}
+}
+
+func TestCallConcurrent(t *testing.T) {
+	for _, eng := range []Engine{Tree, VM} {
+		e := NewExecutor(NewStringLoader(map[string]string{
+			"m.apl": `func add(int x, int y) int { return x + y; }`,
+		}))
+		e.SetEngine(eng)
+		if err := e.Check("m.apl"); err != nil {
+			t.Fatal(err)
+		}
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				v, err := e.Call(context.Background(), "m.apl", "add", 1, 2)
+				if err != nil || v != 3 {
+					t.Errorf("engine %v: expected 3 but got %v, %v", eng, v, err)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+}
@@ -0,0 +1,289 @@
+package interp
+
+import (
+	"strings"
+	"sync"
+
+	"ast"
+	"ast/statement"
+	"types"
+)
+
+// unit is a package taken through Check.
+type unit struct {
+	path    string
+	paths   []string    // File paths, see Resolve.
+	files   []*ast.File // Parsed files, parallel to paths.
+	imports []string    // Imported import paths, in order of first import.
+	entry   *cacheEntry // Cache entry of the package, if caching.
+	loadErr error       // Error resolving, loading or parsing the files, or of an import cycle.
+
+	// Set by schedule.
+	deps []*unit // Units to check first.
+
+	// Set by checkUnit.
+	done    chan struct{}    // Closed once checked or skipped.
+	checked bool             // Whether the files were checked, successfully or not.
+	scopes  []*types.Context // Contexts of the checked files, up to a failing one.
+	err     error            // Error checking the files.
+}
+
+// Check statically checks an import path and the packages it imports. See
+// Resolve for how import paths map to files. If a cache directory is set, see
+// SetCacheDir, packages whose files and imports are unchanged are loaded from
+// the cache instead.
+//
+// The files of all packages are loaded and parsed in parallel, and each
+// package is checked as soon as the packages it imports are. Regardless of
+// scheduling, the error returned is the first one met by a depth-first check
+// of the imports in order of import, and funcs are declared in that order.
+//
+// A package that fails to check keeps failing: later calls return the same
+// error for it and for the packages importing it, and none of its funcs are
+// declared, so they cannot be called. An import closing a cycle fails the
+// importing package.
+func (e *Executor) Check(path string) error {
+	if e.checked[path] {
+		return e.failed[path]
+	}
+	units := e.loadUnits(path)
+	order := schedule(units, path)
+	e.checkUnits(order)
+	for _, u := range order {
+		e.commit(u)
+	}
+	return e.firstError(units, path, make(map[string]bool))
+}
+
+// loadUnits loads the package at path and, transitively, all packages it
+// imports that are not checked yet, in parallel. Returns the units by import
+// path.
+func (e *Executor) loadUnits(path string) map[string]*unit {
+	var (
+		mu    sync.Mutex // Guards units.
+		wg    sync.WaitGroup
+		units = make(map[string]*unit)
+		visit func(path string)
+	)
+	visit = func(path string) {
+		mu.Lock()
+		defer mu.Unlock()
+		if _, ok := units[path]; ok || e.checked[path] {
+			return
+		}
+		u := &unit{path: path, done: make(chan struct{})}
+		units[path] = u
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			e.loadUnit(u)
+			for _, imp := range u.imports {
+				visit(imp)
+			}
+		}()
+	}
+	visit(path)
+	wg.Wait()
+	return units
+}
+
+// loadUnit resolves the files of a unit and parses them in parallel.
+func (e *Executor) loadUnit(u *unit) {
+	u.paths, u.loadErr = e.Resolve(u.path)
+	if u.loadErr != nil {
+		return
+	}
+	if e.cache != nil {
+		u.entry = e.cache.get(u.path)
+	}
+	u.files = make([]*ast.File, len(u.paths))
+	errs := make([]error, len(u.paths))
+	var wg sync.WaitGroup
+	for i := range u.paths {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			u.files[i], errs[i] = e.parseCached(u.paths[i], u.entry)
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			u.loadErr = err
+			return
+		}
+	}
+	seen := make(map[string]bool)
+	for _, file := range u.files {
+		for _, imp := range file.Imports {
+			if !seen[imp.Name] {
+				seen[imp.Name] = true
+				u.imports = append(u.imports, imp.Name)
+			}
+		}
+	}
+}
+
+// schedule returns the units in the order a depth-first check from path
+// finishes them, and sets their deps. An import closing a cycle is not a dep,
+// but fails the importing unit with an error at the import.
+func schedule(units map[string]*unit, path string) []*unit {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	var order, stack []*unit
+	state := make(map[*unit]int)
+	var visit func(u *unit)
+	visit = func(u *unit) {
+		state[u] = visiting
+		stack = append(stack, u)
+		for _, imp := range u.imports {
+			dep, ok := units[imp]
+			if !ok {
+				// Checked by an earlier Check.
+				continue
+			}
+			switch state[dep] {
+			case 0:
+				visit(dep)
+			case visiting:
+				if u.loadErr == nil {
+					u.loadErr = cycleError(u, stack, dep)
+				}
+				continue
+			}
+			u.deps = append(u.deps, dep)
+		}
+		stack = stack[:len(stack)-1]
+		state[u] = visited
+		order = append(order, u)
+	}
+	visit(units[path])
+	return order
+}
+
+// cycleError returns the error of the import of dep by u, which closes a
+// cycle of the units on the stack of a depth-first visit.
+func cycleError(u *unit, stack []*unit, dep *unit) error {
+	var paths []string
+	for i := len(stack) - 1; i >= 0; i-- {
+		paths = append(paths, stack[i].path)
+		if stack[i] == dep {
+			break
+		}
+	}
+	for i, j := 0, len(paths)-1; i < j; i, j = i+1, j-1 {
+		paths[i], paths[j] = paths[j], paths[i]
+	}
+	msg := "import cycle: " + strings.Join(append(paths, dep.path), " -> ")
+	return u.importOf(dep.path).Errf("%s", msg)
+}
+
+// importOf returns the first import of path by the files of the unit.
+func (u *unit) importOf(path string) *statement.Import {
+	for _, file := range u.files {
+		for _, imp := range file.Imports {
+			if imp.Name == path {
+				return imp
+			}
+		}
+	}
+	return nil
+}
+
+// checkUnits checks each unit, in parallel, once its deps are checked. Units
+// that failed to load, whose deps failed, or that import a package that
+// failed in an earlier Check are skipped.
+func (e *Executor) checkUnits(units []*unit) {
+	var wg sync.WaitGroup
+	for _, u := range units {
+		wg.Add(1)
+		go func(u *unit) {
+			defer wg.Done()
+			defer close(u.done)
+			if u.loadErr != nil {
+				return
+			}
+			for _, imp := range u.imports {
+				if e.failed[imp] != nil {
+					return
+				}
+			}
+			for _, dep := range u.deps {
+				<-dep.done
+				if !dep.checked || dep.err != nil {
+					return
+				}
+			}
+			e.checkUnit(u)
+		}(u)
+	}
+	wg.Wait()
+}
+
+// checkUnit checks the files of a unit into tc.
+func (e *Executor) checkUnit(u *unit) {
+	u.checked = true
+	if u.entry != nil {
+		if ok, err := e.checkCached(u); ok || err != nil {
+			u.err = err
+			return
+		}
+	}
+	pkg := e.tc.Package(u.path)
+	for _, file := range u.files {
+		scope := pkg.File()
+		u.scopes = append(u.scopes, scope)
+		if err := file.Check(scope); err != nil {
+			u.err = err
+			return
+		}
+	}
+	if e.cache != nil {
+		e.storeCached(u)
+	}
+}
+
+// commit records the results of checking a unit in the Executor. Failed
+// packages are recorded as checked as well, along with their error, since
+// their declarations are already in tc, but their funcs are not declared.
+func (e *Executor) commit(u *unit) {
+	if !u.checked {
+		return
+	}
+	e.checked[u.path] = true
+	e.imports[u.path] = u.imports
+	if u.err != nil {
+		e.failed[u.path] = u.err
+	}
+	for i, scope := range u.scopes {
+		e.scopes[u.paths[i]] = scope
+		if u.err == nil {
+			e.declare(u.path, u.files[i], scope)
+		}
+	}
+}
+
+// firstError returns the first error met by a depth-first check of the unit
+// at path, skipping units seen before. Packages checked by an earlier Check
+// give the error they failed with, if any.
+func (e *Executor) firstError(units map[string]*unit, path string, seen map[string]bool) error {
+	if seen[path] {
+		return nil
+	}
+	seen[path] = true
+	u, ok := units[path]
+	if !ok {
+		return e.failed[path]
+	}
+	if u.loadErr != nil {
+		return u.loadErr
+	}
+	for _, imp := range u.imports {
+		if err := e.firstError(units, imp, seen); err != nil {
+			return err
+		}
+	}
+	return u.err
+}
@@ -0,0 +1,437 @@
+package interp
+
+import (
+	"fmt"
+
+	"ast/expr"
+	"ast/source"
+	"ast/statement"
+	"bytecode"
+	"values"
+)
+
+// program is the compiled form of all checked funcs, with its builtins
+// bound.
+type program struct {
+	*bytecode.Program
+	index    map[*function]int // Index in Funcs by checked func.
+	builtins []*Builtin        // Bound builtins, parallel to Builtins.
+}
+
+// Compile checks the package at an import path and compiles all checked
+// funcs, including those of the package and its imports, to bytecode. The
+// program is cached until the next Check of a new package or Invalidate.
+func (e *Executor) Compile(path string) (*bytecode.Program, error) {
+	if err := e.Check(path); err != nil {
+		return nil, err
+	}
+	p, err := e.program()
+	if err != nil {
+		return nil, err
+	}
+	return p.Program, nil
+}
+
+// program returns the compiled form of all checked funcs, compiling them if
+// needed.
+func (e *Executor) program() (*program, error) {
+	e.progMu.Lock()
+	defer e.progMu.Unlock()
+	if e.prog != nil {
+		return e.prog, nil
+	}
+	p := &program{
+		Program: &bytecode.Program{},
+		index:   make(map[*function]int),
+	}
+	for i, fn := range e.decls {
+		p.index[fn] = i
+	}
+	c := &compiler{
+		e:         e,
+		p:         p,
+		builtins:  make(map[string]int),
+		inlinable: make(map[*function]bool),
+		visiting:  make(map[*function]bool),
+	}
+	for _, fn := range e.decls {
+		f, err := c.compile(fn)
+		if err != nil {
+			return nil, err
+		}
+		p.Funcs = append(p.Funcs, f)
+	}
+	e.prog = p
+	return p, nil
+}
+
+// compiler compiles checked funcs to a program.
+type compiler struct {
+	e         *Executor
+	p         *program
+	builtins  map[string]int     // Index in p.Builtins by name.
+	inlinable map[*function]bool // Whether calls are inlined, by callee.
+	visiting  map[*function]bool // Callees whose inlinability is being decided.
+}
+
+// funcCompiler compiles a single func, or a call inlined into it.
+type funcCompiler struct {
+	*compiler
+	fn     *function // The func compiled or inlined.
+	f      *bytecode.Func
+	slots  map[string]int // Local slots by name.
+	consts map[string]int // Index in f.Consts by type and value.
+	inl    int            // Index in f.Inlined of the inlined call compiled, or -1.
+}
+
+// compile compiles a checked func.
+func (c *compiler) compile(fn *function) (*bytecode.Func, error) {
+	fc := &funcCompiler{
+		compiler: c,
+		fn:       fn,
+		f: &bytecode.Func{
+			Name:    fn.decl.Nam,
+			Module:  fn.module,
+			Args:    len(fn.decl.Args),
+			Returns: fn.decl.Return != nil,
+			Src:     bytecode.PosOf(fn.decl),
+		},
+		slots:  make(map[string]int),
+		consts: make(map[string]int),
+		inl:    -1,
+	}
+	for _, arg := range fn.decl.Args {
+		fc.slots[arg.Nam] = len(fc.f.Locals)
+		fc.f.Locals = append(fc.f.Locals, arg.Nam)
+	}
+	if err := fc.stmts(fn.decl.Statements); err != nil {
+		return nil, err
+	}
+	var end source.Source = fn.decl
+	if fn.decl.End != nil {
+		end = fn.decl.End
+	}
+	if fc.f.Returns {
+		fc.emit(end, bytecode.MissingReturn, 0)
+	} else {
+		fc.emit(end, bytecode.ReturnNil, 0)
+	}
+	return fc.f, nil
+}
+
+// emit appends an instruction at src and returns its index.
+func (fc *funcCompiler) emit(src source.Source, op bytecode.Op, arg int) int {
+	fc.f.Code = append(fc.f.Code, bytecode.MakeInstr(op, arg))
+	fc.f.Lines = append(fc.f.Lines, bytecode.PosOf(src))
+	fc.f.Inl = append(fc.f.Inl, fc.inl)
+	return len(fc.f.Code) - 1
+}
+
+// patch sets the target of the jump at index pc to the next instruction.
+func (fc *funcCompiler) patch(pc int) {
+	fc.f.Code[pc] = bytecode.MakeInstr(fc.f.Code[pc].Op(), len(fc.f.Code))
+}
+
+// stmts compiles a list of statements, each preceded by a step as in
+// statement.ExecList.
+func (fc *funcCompiler) stmts(stmts []statement.Statement) error {
+	for _, stmt := range stmts {
+		fc.emit(stmt, bytecode.Step, 0)
+		if err := fc.stmt(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (fc *funcCompiler) stmt(stmt statement.Statement) error {
+	switch s := stmt.(type) {
+	case *statement.Import:
+		// Imports are resolved before execution.
+	case *statement.Return:
+		if s.Expr == nil {
+			fc.emit(s, bytecode.ReturnNil, 0)
+			return nil
+		}
+		if call, ok := s.Expr.(*expr.Call); ok && fc.fn.tails[call] {
+			callee, err := fc.callee(call, call.Nam)
+			if err != nil {
+				return err
+			}
+			return fc.tailCall(call, callee, call.Params)
+		}
+		if err := fc.expr(s.Expr); err != nil {
+			return err
+		}
+		fc.emit(s, bytecode.Return, 0)
+	case *statement.FnCall:
+		if err := fc.call(s, s.Nam, s.Params); err != nil {
+			return err
+		}
+		fc.emit(s, bytecode.Pop, 0)
+	case *statement.Block:
+		return fc.stmts(s.Statements)
+	case *statement.If:
+		if err := fc.expr(s.Cond); err != nil {
+			return err
+		}
+		jumpElse := fc.emit(s, bytecode.JumpFalse, 0)
+		if err := fc.stmt(s.Then); err != nil {
+			return err
+		}
+		if s.Else == nil {
+			fc.patch(jumpElse)
+			return nil
+		}
+		jumpEnd := fc.emit(s, bytecode.Jump, 0)
+		fc.patch(jumpElse)
+		if err := fc.stmt(s.Else); err != nil {
+			return err
+		}
+		fc.patch(jumpEnd)
+	default:
+		return stmt.Errf("cannot compile %T", stmt)
+	}
+	return nil
+}
+
+func (fc *funcCompiler) expr(x expr.Expr) error {
+	switch x := x.(type) {
+	case *expr.Value:
+		fc.emit(x, bytecode.Const, fc.constant(x.V))
+	case *expr.Ident:
+		slot, ok := fc.slots[x.Nam]
+		if !ok {
+			return x.Errf("undefined: %s", x.Nam)
+		}
+		fc.emit(x, bytecode.Load, slot)
+	case *expr.Call:
+		return fc.call(x, x.Nam, x.Params)
+	case *expr.Binary:
+		if err := fc.expr(x.X); err != nil {
+			return err
+		}
+		if x.Op == "&&" || x.Op == "||" {
+			jump := bytecode.JumpFalseOrPop
+			if x.Op == "||" {
+				jump = bytecode.JumpTrueOrPop
+			}
+			end := fc.emit(x, jump, 0)
+			if err := fc.expr(x.Y); err != nil {
+				return err
+			}
+			fc.emit(x, bytecode.Alloc, 0)
+			fc.patch(end)
+			return nil
+		}
+		op, ok := bytecode.BinaryOp(x.Op)
+		if !ok {
+			return x.Errf("unknown operator %s", x.Op)
+		}
+		if err := fc.expr(x.Y); err != nil {
+			return err
+		}
+		fc.emit(x, op, 0)
+	case *expr.Unary:
+		op, ok := bytecode.UnaryOp(x.Op)
+		if !ok {
+			return x.Errf("unknown operator %s", x.Op)
+		}
+		if err := fc.expr(x.X); err != nil {
+			return err
+		}
+		fc.emit(x, op, 0)
+	default:
+		return x.Errf("cannot compile %T", x)
+	}
+	return nil
+}
+
+// call compiles a call at src of the func with the given name, resolved as
+// by frame.Call. With optimization, calls of small funcs are inlined, see
+// canInline.
+func (fc *funcCompiler) call(src source.Source, name string, params []expr.Expr) error {
+	for _, param := range params {
+		if err := fc.expr(param); err != nil {
+			return err
+		}
+	}
+	pkg, local, err := fc.fn.scope.Resolve(name)
+	if err != nil {
+		return src.Errf(err.Error())
+	}
+	site := bytecode.CallSite{Name: name, Args: len(params)}
+	op := bytecode.Call
+	if pkg != "" {
+		callee := fc.e.funcs[pkg][local]
+		if fc.fn.optimized && fc.canInline(callee) {
+			return fc.inline(src, callee)
+		}
+		site.Target = fc.p.index[callee]
+	} else {
+		b, ok := fc.e.builtins[local]
+		if !ok {
+			return src.Errf("%s is not a func", name)
+		}
+		i, ok := fc.builtins[local]
+		if !ok {
+			i = len(fc.p.Builtins)
+			fc.builtins[local] = i
+			fc.p.Builtins = append(fc.p.Builtins, local)
+			fc.p.builtins = append(fc.p.builtins, b)
+		}
+		site.Target = i
+		op = bytecode.CallBuiltin
+	}
+	fc.f.Calls = append(fc.f.Calls, site)
+	fc.emit(src, op, len(fc.f.Calls)-1)
+	return nil
+}
+
+// callee returns the declared func called at src by the given name, or nil
+// if the name refers to a builtin.
+func (fc *funcCompiler) callee(src source.Source, name string) (*function, error) {
+	pkg, local, err := fc.fn.scope.Resolve(name)
+	if err != nil {
+		return nil, src.Errf(err.Error())
+	}
+	if pkg == "" {
+		return nil, nil
+	}
+	return fc.e.funcs[pkg][local], nil
+}
+
+// tailCall compiles a call at src of a declared func in tail position, which
+// reuses the frame of the caller.
+func (fc *funcCompiler) tailCall(src source.Source, callee *function, params []expr.Expr) error {
+	for _, param := range params {
+		if err := fc.expr(param); err != nil {
+			return err
+		}
+	}
+	fc.f.Calls = append(fc.f.Calls, bytecode.CallSite{
+		Name:   callee.decl.Nam,
+		Target: fc.p.index[callee],
+		Args:   len(params),
+	})
+	fc.emit(src, bytecode.TailCall, len(fc.f.Calls)-1)
+	return nil
+}
+
+// maxInlineNodes is the largest number of expression nodes of an inlined
+// func.
+const maxInlineNodes = 16
+
+// canInline reports whether calls of fn are inlined. Its body must be a
+// single return of an expression of at most maxInlineNodes nodes, which does
+// not call fn again, directly or through other inlined funcs. The expression
+// must not be a call of a declared func either, as that is a tail call, whose
+// caller has no frame in stack traces.
+func (c *compiler) canInline(fn *function) bool {
+	if ok, done := c.inlinable[fn]; done {
+		return ok
+	}
+	if c.visiting[fn] {
+		return false
+	}
+	c.visiting[fn] = true
+	ok := c.decideInline(fn)
+	delete(c.visiting, fn)
+	c.inlinable[fn] = ok
+	return ok
+}
+
+func (c *compiler) decideInline(fn *function) bool {
+	if len(fn.decl.Statements) != 1 {
+		return false
+	}
+	ret, ok := fn.decl.Statements[0].(*statement.Return)
+	if !ok || ret.Expr == nil {
+		return false
+	}
+	nodes := 0
+	var visit func(x expr.Expr) bool
+	visit = func(x expr.Expr) bool {
+		nodes++
+		switch x := x.(type) {
+		case *expr.Call:
+			pkg, local, err := fn.scope.Resolve(x.Nam)
+			if err != nil {
+				return false
+			}
+			if pkg != "" {
+				// Decide whether the callee is inlined while fn is still
+				// visited, so that no chain of inlined calls leads back to
+				// fn.
+				callee := c.e.funcs[pkg][local]
+				if x == ret.Expr || c.visiting[callee] {
+					return false
+				}
+				c.canInline(callee)
+			}
+			for _, param := range x.Params {
+				if !visit(param) {
+					return false
+				}
+			}
+		case *expr.Binary:
+			return visit(x.X) && visit(x.Y)
+		case *expr.Unary:
+			return visit(x.X)
+		}
+		return true
+	}
+	return visit(ret.Expr) && nodes <= maxInlineNodes
+}
+
+// inline compiles a call at src of a declared func that can be inlined, see
+// canInline, whose args are on the stack. The call is entered, counting
+// against the call depth limit as a call would, and the args are stored in
+// new local slots, followed by the step of the return statement and its
+// expression.
+func (fc *funcCompiler) inline(src source.Source, callee *function) error {
+	ret := callee.decl.Statements[0].(*statement.Return)
+	depth := 1
+	if fc.inl >= 0 {
+		depth += fc.f.Inlined[fc.inl].Depth
+	}
+	fc.f.Inlined = append(fc.f.Inlined, bytecode.Inline{
+		Func:   callee.decl.Nam,
+		Module: callee.module,
+		Src:    bytecode.PosOf(callee.decl),
+		Site:   bytecode.PosOf(src),
+		Parent: fc.inl,
+		Depth:  depth,
+	})
+	inner := &funcCompiler{
+		compiler: fc.compiler,
+		fn:       callee,
+		f:        fc.f,
+		slots:    make(map[string]int),
+		consts:   fc.consts,
+		inl:      len(fc.f.Inlined) - 1,
+	}
+	base := len(fc.f.Locals)
+	for i, arg := range callee.decl.Args {
+		inner.slots[arg.Nam] = base + i
+		fc.f.Locals = append(fc.f.Locals, callee.decl.Nam+"."+arg.Nam)
+	}
+	fc.emit(src, bytecode.Enter, inner.inl)
+	for i := len(callee.decl.Args) - 1; i >= 0; i-- {
+		fc.emit(src, bytecode.Store, base+i)
+	}
+	inner.emit(ret, bytecode.Step, 0)
+	return inner.expr(ret.Expr)
+}
+
+// constant returns the index of v in the constants of the func, adding it if
+// needed.
+func (fc *funcCompiler) constant(v values.Value) int {
+	key := fmt.Sprintf("%v:%v", v.Type(), v)
+	if i, ok := fc.consts[key]; ok {
+		return i
+	}
+	fc.f.Consts = append(fc.f.Consts, v)
+	fc.consts[key] = len(fc.f.Consts) - 1
+	return len(fc.f.Consts) - 1
+}
@@ -0,0 +1,92 @@
+package interp
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"ast/source"
+)
+
+// RuntimeError is an error that occurred while executing apl code. It
+// carries the apl call stack at the time of the error. Errors returned by
+// Run and Call during execution are *RuntimeError; use errors.As to inspect
+// them. A RuntimeError unwraps to the positioned error it was raised with,
+// so errors.Is finds the underlying cause, e.g. ErrCallDepth or an error
+// returned by a func registered with RegisterFunc.
+type RuntimeError struct {
+	Err   error        // Positioned error, usually a *source.Error.
+	Stack []StackFrame // Outermost call first.
+
+	unwound []StackFrame // Innermost call first, while unwinding.
+}
+
+// StackFrame is a frame of the apl call stack: the position in a func that
+// was being executed.
+type StackFrame struct {
+	Func string
+	Src  source.Source
+}
+
+func (f StackFrame) String() string {
+	return fmt.Sprintf("%s:%d:%d in %s", f.Src.File(), f.Src.Line()+1, f.Src.LinePos()+1, f.Func)
+}
+
+func (e *RuntimeError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *RuntimeError) Unwrap() error {
+	return e.Err
+}
+
+// maxTraceFrames is the number of frames StackTrace shows at each end of
+// deep stacks.
+const maxTraceFrames = 50
+
+// StackTrace returns the call stack, one frame per line, outermost call
+// first. The middle of very deep stacks is elided.
+func (e *RuntimeError) StackTrace() string {
+	var b strings.Builder
+	for i, f := range e.Stack {
+		if n := len(e.Stack); n > 2*maxTraceFrames && i >= maxTraceFrames && i < n-maxTraceFrames {
+			if i == maxTraceFrames {
+				fmt.Fprintf(&b, "...%d frames elided...\n", n-2*maxTraceFrames)
+			}
+			continue
+		}
+		b.WriteString(f.String())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// unwind records that err occurred in, or in a call made by, the func named
+// fn at src. The first error raised in a func becomes a *RuntimeError.
+func unwind(err error, fn string, src source.Source) error {
+	re, ok := err.(*RuntimeError)
+	if !ok {
+		var se *source.Error
+		if errors.As(err, &se) {
+			src = se.Src
+		}
+		re = &RuntimeError{Err: err}
+	}
+	re.unwound = append(re.unwound, StackFrame{Func: fn, Src: src})
+	return re
+}
+
+// finish completes the stack of a *RuntimeError after it unwound all calls.
+func finish(err error) error {
+	re, ok := err.(*RuntimeError)
+	if !ok {
+		return err
+	}
+	re.Stack = make([]StackFrame, len(re.unwound))
+	for i, f := range re.unwound {
+		re.Stack[len(re.unwound)-1-i] = f
+	}
+	re.unwound = nil
+	return re
+}
@@ -0,0 +1,126 @@
+package interp
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRuntimeError(t *testing.T) {
+	errHost := errors.New("host failure")
+	testCases := []struct {
+		name  string
+		input map[string]string
+		msg   string
+		trace string
+		cause error
+	}{
+		{
+			name: "division_by_zero",
+			input: map[string]string{
+				"main.apl": `import lib;
+
+func run(int x) int {
+    return lib.Div(10, x) + 0;
+}
+
+func main() {
+    println(run(0));
+}
+`,
+				"lib.apl": `func Div(int x, int y) int {
+    return x / y;
+}
+`,
+			},
+			msg: "lib.apl:2:14 division by zero",
+			trace: `main.apl:8:13 in main
+main.apl:4:12 in run
+lib.apl:2:14 in Div
+`,
+		},
+		{
+			name: "host_error",
+			input: map[string]string{
+				"main.apl": `func main() {
+    fail();
+}
+`,
+			},
+			msg: "main.apl:2:5 fail: host failure",
+			trace: `main.apl:2:5 in main
+`,
+			cause: errHost,
+		},
+		{
+			name: "builtin_panic",
+			input: map[string]string{
+				"main.apl": `func f() {
+    boom("x");
+}
+
+func main() {
+    f();
+}
+`,
+			},
+			msg: "main.apl:2:5 boom: panic: boom x",
+			trace: `main.apl:6:5 in main
+main.apl:2:5 in f
+`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := NewExecutor(NewStringLoader(tc.input))
+			e.SetOutput(&strings.Builder{})
+			if err := e.RegisterFunc("fail", func() error { return errHost }); err != nil {
+				t.Fatal(err)
+			}
+			if err := e.RegisterFunc("boom", func(s string) { panic("boom " + s) }); err != nil {
+				t.Fatal(err)
+			}
+			err := e.Run(context.Background(), "main.apl")
+			var re *RuntimeError
+			if !errors.As(err, &re) {
+				t.Fatalf("expected *RuntimeError but got %v", err)
+			}
+			if re.Error() != tc.msg {
+				t.Errorf("expected %q but got %q", tc.msg, re.Error())
+			}
+			if trace := re.StackTrace(); trace != tc.trace {
+				t.Errorf("expected trace\n%s\ngot\n%s", tc.trace, trace)
+			}
+			if tc.cause != nil && !errors.Is(err, tc.cause) {
+				t.Errorf("expected %v to wrap %v", err, tc.cause)
+			}
+		})
+	}
+}
+
+func TestRuntimeErrorDeepStack(t *testing.T) {
+	e := NewExecutor(NewStringLoader(map[string]string{
+		"test": `
+func loop(int x) int {
+  return loop(x + 1) + 0;
+}
+`,
+	}))
+	e.SetLimits(Limits{CallDepth: 1000})
+	_, err := e.Call(context.Background(), "test", "loop", 0)
+	var re *RuntimeError
+	if !errors.As(err, &re) || !errors.Is(err, ErrCallDepth) {
+		t.Fatalf("expected *RuntimeError for %v but got %v", ErrCallDepth, err)
+	}
+	if len(re.Stack) != 1000 {
+		t.Errorf("expected 1000 frames but got %d", len(re.Stack))
+	}
+	lines := strings.Split(strings.TrimSuffix(re.StackTrace(), "\n"), "\n")
+	if len(lines) != 2*maxTraceFrames+1 || lines[maxTraceFrames] != "...900 frames elided..." {
+		t.Errorf("unexpected trace of %d lines: %q", len(lines), lines[maxTraceFrames])
+	}
+	if lines[0] != "test:3:10 in loop" {
+		t.Errorf("unexpected first frame %q", lines[0])
+	}
+}
@@ -0,0 +1,268 @@
+// Package interp loads, checks and runs apl programs, see Executor. Programs
+// run on a bytecode VM or by walking their trees, see Engine.
+package interp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"ast"
+	"parser"
+	"types"
+)
+
+// Ext is the file extension of source files.
+const Ext = ".apl"
+
+// Executor loads, checks, and runs the language. Once the packages they use
+// are checked, Call and Run may be called concurrently. Other methods must not
+// be called concurrently with any method.
+type Executor struct {
+	loader   Loader
+	out      io.Writer
+	limits   Limits
+	engine   Engine
+	optimize bool
+	builtins map[string]*Builtin
+	tc       *types.Context
+	cache    *diskCache
+	scopes   map[string]*types.Context       // Contexts of checked files by file path.
+	checked  map[string]bool                 // Import paths checked into tc.
+	failed   map[string]error                // Errors of checked import paths that failed to check.
+	imports  map[string][]string             // Imports of checked import paths.
+	funcs    map[string]map[string]*function // Checked funcs by import path and name.
+	decls    []*function                     // Checked funcs in order of declaration.
+	prog     *program                        // Compiled decls, or nil if not compiled yet.
+	progMu   sync.Mutex                      // Guards prog while concurrent runs compile it.
+
+	// Check loads, parses and checks packages in parallel. mu guards the
+	// state shared by its goroutines.
+	mu      sync.Mutex
+	sources map[string][]byte    // Loaded sources by file path.
+	files   map[string]*ast.File // Parsed files by file path.
+	pkgs    map[string][]string  // File paths by import path.
+	keys    map[string]string    // Cache keys of checked import paths, if caching.
+	parsed  int                  // Number of files parsed, for tests.
+}
+
+// NewExecutor returns a new Executor with the standard builtins registered.
+// Output of builtins such as print goes to os.Stdout, see SetOutput.
+func NewExecutor(l Loader) *Executor {
+	e := &Executor{
+		loader:   l,
+		out:      os.Stdout,
+		limits:   Limits{CallDepth: DefaultCallDepth},
+		builtins: make(map[string]*Builtin),
+		sources:  make(map[string][]byte),
+		files:    make(map[string]*ast.File),
+	}
+	e.reset()
+	for _, b := range builtins {
+		if err := e.Register(b); err != nil {
+			panic(err)
+		}
+	}
+	return e
+}
+
+// reset discards all check results, keeping the registered builtins.
+func (e *Executor) reset() {
+	e.tc = types.NewContext()
+	for _, b := range e.builtins {
+		if err := e.tc.Add(b.Name, b.Type); err != nil {
+			panic(err)
+		}
+	}
+	e.scopes = make(map[string]*types.Context)
+	e.pkgs = make(map[string][]string)
+	e.checked = make(map[string]bool)
+	e.failed = make(map[string]error)
+	e.imports = make(map[string][]string)
+	e.funcs = make(map[string]map[string]*function)
+	e.decls = nil
+	e.prog = nil
+	e.keys = make(map[string]string)
+}
+
+// SetOutput sets the writer that builtins such as print write to.
+func (e *Executor) SetOutput(w io.Writer) {
+	e.out = w
+}
+
+// SetOptimize sets whether funcs of packages checked after the call are
+// optimized before they run, see package optimize, and the VM inlines calls
+// of small funcs. Optimized funcs give the same results and errors, but
+// allocate fewer values, see Limits, as folded operations are computed ahead
+// of time. Calls of a func in a return statement reuse the frame of the
+// caller either way.
+func (e *Executor) SetOptimize(on bool) {
+	e.optimize = on
+}
+
+// Output returns the writer that builtins such as print write to.
+func (e *Executor) Output() io.Writer {
+	return e.out
+}
+
+// Resolve returns the paths of the files making up the package at an import
+// path. An import path ending in .apl names a single file. Otherwise, the
+// slash-separated import path "net/http" resolves to the file
+// "net/http.apl", or failing that to all .apl files in the directory
+// "net/http", or failing that to the file "net/http" itself.
+func (e *Executor) Resolve(path string) ([]string, error) {
+	e.mu.Lock()
+	paths, ok := e.pkgs[path]
+	e.mu.Unlock()
+	if ok {
+		return paths, nil
+	}
+	paths, err := e.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	e.pkgs[path] = paths
+	e.mu.Unlock()
+	return paths, nil
+}
+
+func (e *Executor) resolve(path string) ([]string, error) {
+	if strings.HasSuffix(path, Ext) {
+		return []string{path}, nil
+	}
+	if _, err := e.load(path + Ext); err == nil {
+		return []string{path + Ext}, nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if dl, ok := e.loader.(DirLoader); ok {
+		names, err := dl.ReadDir(path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		var paths []string
+		for _, name := range names {
+			if strings.HasSuffix(name, Ext) {
+				paths = append(paths, path+"/"+name)
+			}
+		}
+		if len(paths) > 0 {
+			return paths, nil
+		}
+	}
+	if _, err := e.load(path); err != nil {
+		return nil, err
+	}
+	return []string{path}, nil
+}
+
+// load returns the source of the file at path, loading it if it is not
+// loaded yet.
+func (e *Executor) load(path string) ([]byte, error) {
+	e.mu.Lock()
+	src, ok := e.sources[path]
+	e.mu.Unlock()
+	if ok {
+		return src, nil
+	}
+	src, err := e.read(path)
+	if err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	e.sources[path] = src
+	e.mu.Unlock()
+	return src, nil
+}
+
+// read returns the source of the file at path from the Loader.
+func (e *Executor) read(path string) ([]byte, error) {
+	r, err := e.loader.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	var b bytes.Buffer
+	for {
+		c, _, err := r.ReadRune()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		b.WriteRune(c)
+	}
+	return b.Bytes(), nil
+}
+
+// parse returns the parsed file for path, loading it if it is not cached.
+func (e *Executor) parse(path string) (*ast.File, error) {
+	if file := e.File(path); file != nil {
+		return file, nil
+	}
+	src, err := e.load(path)
+	if err != nil {
+		return nil, err
+	}
+	p := parser.NewParser(parser.NewLexer(path, bytes.NewReader(src)))
+	file, err := p.Do()
+	if err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	e.parsed++
+	e.files[path] = file
+	e.mu.Unlock()
+	return file, nil
+}
+
+// Invalidate drops the cached file for path, so the next Check reloads it
+// from the Loader. Since declarations of all files share one type registry,
+// all check results are discarded as well; files other than path are
+// re-checked from their cached ASTs. Import paths are resolved again, so
+// files added to or removed from directory packages are picked up.
+func (e *Executor) Invalidate(path string) {
+	e.mu.Lock()
+	delete(e.sources, path)
+	delete(e.files, path)
+	e.mu.Unlock()
+	e.reset()
+}
+
+// File returns the parsed file for a file path, or nil if the path has not
+// been loaded.
+func (e *Executor) File(path string) *ast.File {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.files[path]
+}
+
+// Paths returns the sorted file paths of all loaded files.
+func (e *Executor) Paths() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var paths []string
+	for path := range e.files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Scope returns the context a file was checked in, or nil if the file has not
+// been checked. Names are resolved in it as seen from within the file.
+func (e *Executor) Scope(path string) *types.Context {
+	return e.scopes[path]
+}
+
+// Types returns the type registry populated by Check.
+func (e *Executor) Types() *types.Context {
+	return e.tc
+}
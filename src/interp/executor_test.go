@@ -0,0 +1,603 @@
+package interp
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{
+			name: "normal",
+			input: `
+func main(int x) {
+  main(12345);
+}
+`,
+			err: "",
+		},
+		{
+			name: "function_declare_param_unknown_type",
+			input: `
+func main(foo x) {
+  main("hello world");
+}
+`,
+			err: "test:2:11 unknown type or func: foo",
+		},
+		{
+			name: "function_declare_return_unknown_type",
+			input: `
+func main(int x) foo {
+  main("hello world");
+}
+`,
+			err: "test:2:18 unknown type or func: foo",
+		},
+		{
+			name: "function_declare_conflict",
+			input: `
+func main(int x) {
+}
+func main(int x) {
+}
+`,
+			err: "test:4:1 type main already declared as type<func>",
+		},
+		{
+			name: "function_call_param_type_mismatch",
+			input: `
+func main(int x) {
+  main("hello world");
+}
+`,
+			err: "test:3:3 main param #1 expects type<int>, not type<string>",
+		},
+		{
+			name: "function_call_param_count_mismatch",
+			input: `
+func main(int x, int y) {
+  main(1);
+}
+`,
+			err: "test:3:3 main expects 2 params, not 1",
+		},
+		{
+			name: "function_call_unknown_name",
+			input: `
+func main() {
+  foo();
+}
+`,
+			err: "test:3:3 unknown type or func: foo",
+		},
+		{
+			name: "function_call_wrong_type",
+			input: `
+func main() {
+  int();
+}
+`,
+			err: "test:3:3 int is type<int>, not func",
+		},
+		{
+			name: "return_type_mismatch",
+			input: `
+func main(int x) bool {
+  return x + 1;
+}
+`,
+			err: "test:3:3 return expects type<bool>, not type<int>",
+		},
+		{
+			name: "missing_return",
+			input: `
+func f(bool b) int {
+  if b {
+    return 1;
+  }
+}
+`,
+			err: "test:6:1 missing return at end of f",
+		},
+		{
+			name: "return_in_both_branches",
+			input: `
+func f(bool b) int {
+  if b {
+    return 1;
+  } else if !b {
+    return 2;
+  } else {
+    return 3;
+  }
+}
+`,
+		},
+		{
+			name: "operand_type_mismatch",
+			input: `
+func main(int x) {
+  main(x + "1");
+}
+`,
+			err: "test:3:10 mismatched types type<int> and type<string> for +",
+		},
+		{
+			name: "condition_not_bool",
+			input: `
+func main(int x) {
+  if x { main(x); }
+}
+`,
+			err: "test:3:6 condition is type<int>, not bool",
+		},
+		{
+			name: "undefined_variable",
+			input: `
+func main(int x) {
+  main(y);
+}
+`,
+			err: "test:3:8 undefined: y",
+		},
+		{
+			name: "call_used_as_value",
+			input: `
+func main(int x) {
+  main(main(x));
+}
+`,
+			err: "test:3:8 main() used as value",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			loader := NewStringLoader(map[string]string{"test": tc.input})
+			e := NewExecutor(loader)
+			err := e.Check("test")
+			if tc.err == "" && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			} else if tc.err != "" && err.Error() != tc.err {
+				t.Errorf("expected %q but got %q", tc.err, err.Error())
+			}
+		})
+	}
+}
+
+func TestImport(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input map[string]string
+		err   string
+	}{
+		{
+			name: "normal",
+			input: map[string]string{
+				"test": `
+import foo;      
+func main(int x) {
+  foo.Lib(true);
+}
+`, "foo": `func Lib(bool b) {}`,
+			},
+			err: "",
+		},
+		{
+			name: "export_modifier",
+			input: map[string]string{
+				"test": `
+import foo;
+func main(int x) {
+  foo.lib(true);
+}
+`, "foo": `export func lib(bool b) {}`,
+			},
+			err: "",
+		},
+		{
+			name: "unexported",
+			input: map[string]string{
+				"test": `
+import foo;
+func main(int x) {
+  foo.lib(true);
+}
+`, "foo": `func lib(bool b) {}`,
+			},
+			err: "test:4:3 cannot refer to unexported lib declared in module foo",
+		},
+		{
+			name: "unexported_same_package",
+			input: map[string]string{
+				"test": `
+import foo;
+func main(int x) {
+  foo.Lib(true);
+}
+`,
+				"foo/a.apl": `func lib(bool b) {}`,
+				"foo/b.apl": `func Lib(bool b) {
+  lib(true);
+}`,
+			},
+			err: "",
+		},
+		{
+			name: "unqualified",
+			input: map[string]string{
+				"test": `
+import foo;
+func main(int x) {
+  Lib(true);
+}
+`, "foo": `func Lib(bool b) {}`,
+			},
+			err: "test:4:3 unknown type or func: Lib",
+		},
+		{
+			name: "alias",
+			input: map[string]string{
+				"test": `
+import a/util;
+import b/util as butil;
+func main(int x) {
+  util.Lib(true);
+  butil.Lib(1);
+}
+`,
+				"a/util": `func Lib(bool b) {}`,
+				"b/util": `func Lib(int i) {}`,
+			},
+			err: "",
+		},
+		{
+			name: "last_element_clash",
+			input: map[string]string{
+				"test": `
+import a/util;
+import b/util;
+func main(int x) {
+}
+`,
+				"a/util": `func Lib(bool b) {}`,
+				"b/util": `func Lib(int i) {}`,
+			},
+			err: "test:3:1 import b/util as util collides with import of a/util",
+		},
+		{
+			name: "alias_collides_with_decl",
+			input: map[string]string{
+				"test": `
+import foo as main;
+func main(int x) {
+}
+`,
+				"foo": `func Lib(bool b) {}`,
+			},
+			err: "test:3:1 main already declared as import of foo",
+		},
+		{
+			name: "selected",
+			input: map[string]string{
+				"test": `
+import math { Sqrt, pow };
+func main(int x) {
+  Sqrt(1);
+  pow(2);
+}
+`,
+				"math": `func Sqrt(int i) {}
+export func pow(int i) {}
+`,
+			},
+			err: "",
+		},
+		{
+			name: "selected_not_qualified",
+			input: map[string]string{
+				"test": `
+import math { Sqrt };
+func main(int x) {
+  math.Sqrt(1);
+}
+`,
+				"math": `func Sqrt(int i) {}`,
+			},
+			err: "test:4:3 unknown import: math",
+		},
+		{
+			name: "selected_unknown",
+			input: map[string]string{
+				"test": `
+import math { Sqrt, Cbrt };
+func main(int x) {
+}
+`,
+				"math": `func Sqrt(int i) {}`,
+			},
+			err: "test:2:21 Cbrt not declared in module math",
+		},
+		{
+			name: "selected_unexported",
+			input: map[string]string{
+				"test": `
+import math { sqrt };
+func main(int x) {
+}
+`,
+				"math": `func sqrt(int i) {}`,
+			},
+			err: "test:2:15 cannot refer to unexported sqrt declared in module math",
+		},
+		{
+			name: "selected_collides_with_decl",
+			input: map[string]string{
+				"test": `
+import math { Sqrt };
+func Sqrt(int x) {
+}
+`,
+				"math": `func Sqrt(int i) {}`,
+			},
+			err: "test:3:1 Sqrt already imported from math",
+		},
+		{
+			name: "unknown_import",
+			input: map[string]string{
+				"test": `
+import foo;      
+func main(int x) {
+  lib(true);
+}`},
+			err: "unknown import: foo",
+		},
+		{
+			name: "import_cycle",
+			input: map[string]string{
+				"test": `
+import a;
+func main() {
+  a.A();
+}
+`,
+				"a": `import b;
+func A() {
+  b.B();
+}
+`,
+				"b": `
+import a;
+func B() {}
+`,
+			},
+			err: "b:2:1 import cycle: a -> b -> a",
+		},
+		{
+			name: "import_self",
+			input: map[string]string{
+				"test": `
+import test;
+func main() {}
+`,
+			},
+			err: "test:2:1 import cycle: test -> test",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			loader := NewStringLoader(tc.input)
+			e := NewExecutor(loader)
+			err := e.Check("test")
+			if tc.err == "" && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			} else if tc.err != "" && err.Error() != tc.err {
+				t.Errorf("expected %q but got %q", tc.err, err.Error())
+			}
+		})
+	}
+}
+
+func TestInvalidate(t *testing.T) {
+	loader := NewOverlayLoader(NewStringLoader(map[string]string{
+		"test": `
+import foo;
+func main(int x) {
+  foo.lib(true);
+}
+`,
+		"foo": `export func lib(bool b) {}`,
+	}))
+	e := NewExecutor(loader)
+	if err := e.Check("test"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// The overlay is not seen until the path is invalidated.
+	loader.Set("foo", `export func lib(int i) {}`)
+	if err := e.Check("test"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	e.Invalidate("foo")
+	expected := "test:4:3 foo.lib param #1 expects type<int>, not type<bool>"
+	if err := e.Check("test"); err == nil || err.Error() != expected {
+		t.Fatalf("expected %q but got %v", expected, err)
+	}
+
+	// Deleting the overlay falls through to the underlying loader.
+	loader.Delete("foo")
+	e.Invalidate("foo")
+	if err := e.Check("test"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	loader := NewStringLoader(map[string]string{
+		"main.apl": `
+import lib;
+import net/http;
+func main() {
+  lib.lib(true);
+  http.get("x");
+  http.post("y");
+}
+`,
+		"lib.apl":           `export func lib(bool b) {}`,
+		"net/http/get.apl":  `export func get(string url) {}`,
+		"net/http/post.apl": `export func post(string url) {}`,
+		"net/http/README":   `not apl`,
+	})
+	e := NewExecutor(loader)
+	if err := e.Check("main.apl"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	testCases := []struct {
+		path  string
+		files string
+	}{
+		{path: "main.apl", files: "main.apl"},
+		{path: "lib", files: "lib.apl"},
+		{path: "net/http", files: "net/http/get.apl,net/http/post.apl"},
+	}
+	for _, tc := range testCases {
+		paths, err := e.Resolve(tc.path)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got := strings.Join(paths, ","); got != tc.files {
+			t.Errorf("%s: expected %q but got %q", tc.path, tc.files, got)
+		}
+	}
+	if _, err := e.Resolve("net"); err == nil || err.Error() != "unknown import: net" {
+		t.Errorf("expected unknown import error, got %v", err)
+	}
+}
+
+func TestCheckParallel(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input map[string]string
+		err   string
+		funcs string // Declared funcs in order, if no error.
+	}{
+		{
+			name: "diamond",
+			input: map[string]string{
+				"main": `import a;
+import b;
+func main() {
+  a.A();
+  b.B();
+}
+`,
+				"a":       `import c; func A() { c.C(); }`,
+				"b":       `import c; func B() { c.C(); }`,
+				"c/x.apl": `func D() {}`,
+				"c/y.apl": `func C() { D(); }`,
+			},
+			funcs: "c.D c.C a.A b.B main.main",
+		},
+		{
+			name: "first_error_in_import_order",
+			input: map[string]string{
+				"main": `import a;
+import b;
+import c;
+func main() {}
+`,
+				"a": `func A() {}`,
+				"b": `func B() { A(); }`,
+				"c": `func C( {}`,
+			},
+			err: "b:1:12 unknown type or func: A",
+		},
+		{
+			name: "error_in_transitive_import",
+			input: map[string]string{
+				"main": `import a;
+import b;
+func main() {}
+`,
+				"a": `import d; func A() {}`,
+				"b": `func B( {}`,
+				"d": `func D() { D(1); }`,
+			},
+			err: "d:1:12 D expects 0 params, not 1",
+		},
+		{
+			name: "cycle",
+			input: map[string]string{
+				"main": `import a; func main() { a.A(); }`,
+				"a":    `import b; func A() { b.B(); }`,
+				"b":    `import a; func B() { a.A(); }`,
+			},
+			err: "b:1:1 import cycle: a -> b -> a",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for i := 0; i < 20; i++ {
+				e := NewExecutor(NewStringLoader(tc.input))
+				err := e.Check("main")
+				if tc.err == "" && err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				} else if tc.err != "" && (err == nil || err.Error() != tc.err) {
+					t.Fatalf("expected %q but got %v", tc.err, err)
+				}
+				if tc.funcs == "" {
+					continue
+				}
+				var funcs []string
+				for _, fn := range e.decls {
+					funcs = append(funcs, fn.module+"."+fn.decl.Nam)
+				}
+				if got := strings.Join(funcs, " "); got != tc.funcs {
+					t.Fatalf("expected funcs %q but got %q", tc.funcs, got)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckFailed(t *testing.T) {
+	e := NewExecutor(NewStringLoader(map[string]string{
+		"lib": `func Good() int {
+  return 1;
+}
+
+func Bad() {
+  missing();
+}
+`,
+		"main": `import lib;
+
+func main() {
+  println(lib.Good());
+}
+`,
+	}))
+	const want = "lib:6:3 unknown type or func: missing"
+	for i := 0; i < 2; i++ {
+		if err := e.Check("lib"); err == nil || err.Error() != want {
+			t.Fatalf("check #%d: expected %q but got %v", i+1, want, err)
+		}
+		if v, err := e.Call(context.Background(), "lib", "Good"); err == nil || err.Error() != want {
+			t.Fatalf("call #%d: expected %q but got %v, %v", i+1, want, v, err)
+		}
+		if err := e.Check("main"); err == nil || err.Error() != want {
+			t.Fatalf("check of importer #%d: expected %q but got %v", i+1, want, err)
+		}
+		if err := e.Run(context.Background(), "main"); err == nil || err.Error() != want {
+			t.Fatalf("run of importer #%d: expected %q but got %v", i+1, want, err)
+		}
+	}
+	if fns := e.funcs["lib"]; len(fns) > 0 {
+		t.Errorf("expected no funcs declared for lib, got %d", len(fns))
+	}
+}
@@ -0,0 +1,80 @@
+package interp
+
+import (
+	"fmt"
+	"reflect"
+
+	"types"
+	"values"
+)
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// RegisterFunc registers the Go func fn as a builtin with the given name.
+// The params and result of fn may be of any Go type with an apl type, see
+// values.TypeOf. fn may return at most one value, optionally followed by an
+// error. A variadic fn is variadic in apl as well.
+//
+// When fn is called from apl, args are converted to Go values with
+// values.ToGo and the result is converted back with values.FromGo. A non-nil
+// error returned by fn fails the call with a runtime error at the call site,
+// which unwraps to the returned error.
+func (e *Executor) RegisterFunc(name string, fn interface{}) error {
+	fv := reflect.ValueOf(fn)
+	if fv.Kind() != reflect.Func {
+		return fmt.Errorf("register %s: %T is not a func", name, fn)
+	}
+	ft := fv.Type()
+	typ := &types.Func{Variadic: ft.IsVariadic()}
+	params := make([]reflect.Type, ft.NumIn())
+	for i := range params {
+		params[i] = ft.In(i)
+		if typ.Variadic && i == len(params)-1 {
+			params[i] = params[i].Elem()
+		}
+		t, err := values.TypeOf(params[i])
+		if err != nil {
+			return fmt.Errorf("register %s: param #%d: %v", name, i+1, err)
+		}
+		typ.Args = append(typ.Args, t)
+	}
+	results := ft.NumOut()
+	hasErr := results > 0 && ft.Out(results-1) == errorType
+	if hasErr {
+		results--
+	}
+	if results > 1 {
+		return fmt.Errorf("register %s: returns %d values, expected at most one besides error", name, results)
+	}
+	if results == 1 {
+		t, err := values.TypeOf(ft.Out(0))
+		if err != nil {
+			return fmt.Errorf("register %s: result: %v", name, err)
+		}
+		typ.Return = t
+	}
+	return e.Register(&Builtin{
+		Name: name,
+		Type: typ,
+		Fn: func(e *Executor, args []values.Value) (values.Value, error) {
+			in := make([]reflect.Value, len(args))
+			for i, arg := range args {
+				x, err := values.ToGo(arg, params[min(i, len(params)-1)])
+				if err != nil {
+					return nil, err
+				}
+				in[i] = reflect.ValueOf(x)
+			}
+			out := fv.Call(in)
+			if hasErr {
+				if err := out[len(out)-1].Interface(); err != nil {
+					return nil, err.(error)
+				}
+			}
+			if typ.Return == nil {
+				return nil, nil
+			}
+			return values.FromGo(out[0].Interface())
+		},
+	})
+}
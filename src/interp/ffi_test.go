@@ -0,0 +1,184 @@
+package interp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type person struct {
+	Name    string `apl:"name"`
+	Age     int
+	private bool
+}
+
+func TestRegisterFunc(t *testing.T) {
+	errNegative := errors.New("negative")
+	funcs := map[string]interface{}{
+		"person": func(name string) *person { return &person{Name: name, Age: 36} },
+		"describe": func(p person) string {
+			return fmt.Sprintf("%s is %d", p.Name, p.Age)
+		},
+		"upper": strings.ToUpper,
+		"split": strings.Split,
+		"join":  strings.Join,
+		"half":  func(x float64) float64 { return x / 2 },
+		"sum": func(xs ...int) int {
+			n := 0
+			for _, x := range xs {
+				n += x
+			}
+			return n
+		},
+		"count": func(words []string) map[string]int {
+			m := make(map[string]int)
+			for _, w := range words {
+				m[w]++
+			}
+			return m
+		},
+		"keys": func(m map[string]int) []string {
+			var keys []string
+			for k := range m {
+				keys = append(keys, k)
+			}
+			return keys
+		},
+		"sqrt": func(x int) (int, error) {
+			if x < 0 {
+				return 0, errNegative
+			}
+			r := 0
+			for (r+1)*(r+1) <= x {
+				r++
+			}
+			return r, nil
+		},
+		"check": func(ok bool) error {
+			if !ok {
+				return errNegative
+			}
+			return nil
+		},
+	}
+	testCases := []struct {
+		name   string
+		input  string
+		output string
+		err    string
+	}{
+		{
+			name: "scalars",
+			input: `
+func main() {
+  println(upper("apl"), half(3.0), sum(), sum(1, 2, 3), sqrt(17));
+  check(true);
+}
+`,
+			output: "APL 1.5 0 6 4\n",
+		},
+		{
+			name: "collections",
+			input: `
+func main() {
+  println(split("a,b,a", ","), count(split("a,b,a", ",")));
+  println(join(split("a,b", ","), "-"), len(keys(count(split("b,a", ",")))));
+  println(split("a,b", ",") == split("a,b", ","), count(split("a", ",")) != count(split("b", ",")));
+}
+`,
+			output: "[a b a] map[a:2 b:1]\na-b 2\ntrue true\n",
+		},
+		{
+			name: "structs",
+			input: `
+func main() {
+  println(person("ada"), describe(person("ada")));
+}
+`,
+			output: "{name:ada Age:36} ada is 36\n",
+		},
+		{
+			name: "param_type_mismatch",
+			input: `
+func main() {
+  println(upper(1));
+}
+`,
+			err: "test:3:11 upper param #1 expects type<string>, not type<int>",
+		},
+		{
+			name: "error_result",
+			input: `
+func main() {
+  println(sqrt(-1));
+}
+`,
+			err: "test:3:11 sqrt: negative",
+		},
+		{
+			name: "error_only_result",
+			input: `
+func main() {
+  check(false);
+}
+`,
+			err: "test:3:3 check: negative",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := NewExecutor(NewStringLoader(map[string]string{"test": tc.input}))
+			var out strings.Builder
+			e.SetOutput(&out)
+			for name, fn := range funcs {
+				if err := e.RegisterFunc(name, fn); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := e.RegisterFunc("len", func(xs []string) int { return len(xs) }); err != nil {
+				t.Fatal(err)
+			}
+			err := e.Run(context.Background(), "test")
+			if tc.err == "" && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			} else if tc.err != "" && (err == nil || err.Error() != tc.err) {
+				t.Errorf("expected %q but got %v", tc.err, err)
+			}
+			if tc.err != "" && err != nil && strings.Contains(tc.err, "negative") && !errors.Is(err, errNegative) {
+				t.Errorf("expected %v to wrap %v", err, errNegative)
+			}
+			if out.String() != tc.output {
+				t.Errorf("expected output %q but got %q", tc.output, out.String())
+			}
+		})
+	}
+}
+
+func TestRegisterFuncErrors(t *testing.T) {
+	testCases := []struct {
+		name string
+		fn   interface{}
+		err  string
+	}{
+		{"print", func() {}, "type print already declared as type<func>"},
+		{"f", 1, "register f: int is not a func"},
+		{"f", func(chan int) {}, "register f: param #1: unsupported type chan int"},
+		{"f", func() (int, int) { return 0, 0 }, "register f: returns 2 values, expected at most one besides error"},
+		{"f", func() func() { return nil }, "register f: result: unsupported type func()"},
+		{"f", func(map[string][]int) {}, ""},
+		{"f", func(map[[2]int]int) {}, "register f: param #1: unsupported type [2]int"},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%T", tc.fn), func(t *testing.T) {
+			e := NewExecutor(NewStringLoader(nil))
+			err := e.RegisterFunc(tc.name, tc.fn)
+			if tc.err == "" && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			} else if tc.err != "" && (err == nil || err.Error() != tc.err) {
+				t.Errorf("expected %q but got %v", tc.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package interp
+
+import (
+	"context"
+	"errors"
+
+	"ast/source"
+	"values"
+)
+
+// DefaultCallDepth is the maximum call depth if Limits.CallDepth is zero.
+// Deeper recursion would overflow the Go stack long before.
+const DefaultCallDepth = 10000
+
+// Errors returned, positioned at the offending statement or expression, when
+// an execution exceeds one of its Limits. Use errors.Is to test for them.
+var (
+	ErrStepLimit  = errors.New("step limit exceeded")
+	ErrCallDepth  = errors.New("call depth limit exceeded")
+	ErrValueLimit = errors.New("value limit exceeded")
+)
+
+// Limits bounds the resources a single execution started by Run or Call may
+// use. Zero fields mean no limit, except for CallDepth.
+type Limits struct {
+	// Steps is the number of statements that may be executed.
+	Steps int
+	// CallDepth is the number of nested calls of declared funcs. If zero,
+	// DefaultCallDepth applies.
+	CallDepth int
+	// Values is the number of values that may be computed. Lists, maps and
+	// structs count one for themselves plus their elements.
+	Values int
+}
+
+// SetLimits sets the limits of executions started after the call.
+func (e *Executor) SetLimits(l Limits) {
+	if l.CallDepth == 0 {
+		l.CallDepth = DefaultCallDepth
+	}
+	e.limits = l
+}
+
+// execution is the state of a single execution, shared by all its frames.
+type execution struct {
+	ctx    context.Context
+	limits Limits
+	steps  int
+	depth  int
+	values int
+}
+
+// step counts a statement at src and checks ctx and the step budget.
+func (x *execution) step(src source.Source) error {
+	if err := x.tick(); err != nil {
+		return source.Wrap(src, err)
+	}
+	return nil
+}
+
+// tick counts a statement and checks ctx and the step budget, like step but
+// without positioning the error.
+func (x *execution) tick() error {
+	select {
+	case <-x.ctx.Done():
+		return x.ctx.Err()
+	default:
+	}
+	x.steps++
+	if x.limits.Steps > 0 && x.steps > x.limits.Steps {
+		return ErrStepLimit
+	}
+	return nil
+}
+
+// alloc counts the value v computed at src and checks the value cap.
+func (x *execution) alloc(src source.Source, v values.Value) error {
+	if err := x.count(v); err != nil {
+		return source.Wrap(src, err)
+	}
+	return nil
+}
+
+// count counts the value v and checks the value cap, like alloc but without
+// positioning the error.
+func (x *execution) count(v values.Value) error {
+	x.values += size(v)
+	if x.limits.Values > 0 && x.values > x.limits.Values {
+		return ErrValueLimit
+	}
+	return nil
+}
+
+// size returns the number of values v is made of.
+func size(v values.Value) int {
+	switch v := v.(type) {
+	case nil:
+		return 0
+	case *values.List:
+		n := 1
+		for _, elem := range v.V {
+			n += size(elem)
+		}
+		return n
+	case *values.Map:
+		n := 1
+		for _, e := range v.Entries {
+			n += size(e.Key) + size(e.Elem)
+		}
+		return n
+	case *values.Struct:
+		n := 1
+		for _, f := range v.Fields {
+			n += size(f)
+		}
+		return n
+	}
+	return 1
+}
@@ -0,0 +1,135 @@
+package interp
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLimits(t *testing.T) {
+	const src = `
+func loop(int x) int {
+  return loop(x + 1) + 0;
+}
+func count(int n) int {
+  if n == 0 {
+    return 0;
+  }
+  return 1 + count(n - 1);
+}
+func words(int n) int {
+  return len(split(repeat("a ", n), " "));
+}
+`
+	testCases := []struct {
+		name   string
+		limits Limits
+		fn     string
+		arg    int
+		err    error
+		msg    string
+	}{
+		{
+			name: "default_call_depth",
+			fn:   "loop",
+			err:  ErrCallDepth,
+			msg:  "test:3:10 call depth limit exceeded",
+		},
+		{
+			name:   "call_depth",
+			limits: Limits{CallDepth: 10},
+			fn:     "count",
+			arg:    10,
+			err:    ErrCallDepth,
+			msg:    "test:9:14 call depth limit exceeded",
+		},
+		{
+			name:   "call_depth_ok",
+			limits: Limits{CallDepth: 11},
+			fn:     "count",
+			arg:    10,
+		},
+		{
+			name:   "steps",
+			limits: Limits{Steps: 100},
+			fn:     "count",
+			arg:    1000,
+			err:    ErrStepLimit,
+			msg:    "test:6:3 step limit exceeded",
+		},
+		{
+			name:   "steps_ok",
+			limits: Limits{Steps: 22},
+			fn:     "count",
+			arg:    10,
+		},
+		{
+			name:   "values",
+			limits: Limits{Values: 50},
+			fn:     "count",
+			arg:    100,
+			err:    ErrValueLimit,
+			msg:    "test:6:8 value limit exceeded",
+		},
+		{
+			name:   "values_builtin",
+			limits: Limits{Values: 50},
+			fn:     "words",
+			arg:    100,
+			err:    ErrValueLimit,
+			msg:    "test:12:14 value limit exceeded",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := NewExecutor(NewStringLoader(map[string]string{"test": src}))
+			if err := e.RegisterFunc("split", strings.Split); err != nil {
+				t.Fatal(err)
+			}
+			if err := e.RegisterFunc("repeat", strings.Repeat); err != nil {
+				t.Fatal(err)
+			}
+			if err := e.RegisterFunc("len", func(xs []string) int { return len(xs) }); err != nil {
+				t.Fatal(err)
+			}
+			e.SetLimits(tc.limits)
+			_, err := e.Call(context.Background(), "test", tc.fn, tc.arg)
+			if tc.err == nil {
+				if err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+				return
+			}
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("expected %v but got %v", tc.err, err)
+			}
+			if err.Error() != tc.msg {
+				t.Errorf("expected %q but got %q", tc.msg, err.Error())
+			}
+		})
+	}
+}
+
+func TestLimitsDeadline(t *testing.T) {
+	e := NewExecutor(NewStringLoader(map[string]string{
+		"test": `
+func fib(int n) int {
+  if n < 2 {
+    return n;
+  }
+  return fib(n - 1) + fib(n - 2);
+}
+func main() {
+  println(fib(100));
+}
+`,
+	}))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := e.Run(ctx, "test")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected %v but got %v", context.DeadlineExceeded, err)
+	}
+}
@@ -0,0 +1,304 @@
+package interp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Loadable represents a reader for the source code.
+type Loadable interface {
+	io.RuneScanner
+	io.Closer
+}
+
+type nopCloser struct {
+	io.RuneScanner
+}
+
+func (n *nopCloser) Close() error {
+	return nil
+}
+
+type fileCloser struct {
+	*bufio.Reader
+	f io.Closer
+}
+
+func (f *fileCloser) Close() error {
+	return f.f.Close()
+}
+
+// Loader represents something that can load source code. Loaders must be
+// safe for concurrent use, as Check loads files in parallel.
+type Loader interface {
+	Load(path string) (Loadable, error)
+}
+
+// DirLoader is a Loader that can also list directories. It is needed to load
+// packages whose files are spread over a directory.
+type DirLoader interface {
+	Loader
+	// ReadDir returns the sorted names of the files, not subdirectories, in
+	// the directory at path.
+	ReadDir(path string) ([]string, error)
+}
+
+// NotFoundError is returned by loaders if nothing exists at a path. It
+// matches fs.ErrNotExist with errors.Is.
+type NotFoundError struct {
+	Path     string
+	Searched []string // The roots searched, if the loader has any.
+}
+
+func (e *NotFoundError) Error() string {
+	if len(e.Searched) == 0 {
+		return fmt.Sprintf("unknown import: %s", e.Path)
+	}
+	return fmt.Sprintf("unknown import: %s (searched %s)", e.Path, strings.Join(e.Searched, ", "))
+}
+
+// Is reports whether target is fs.ErrNotExist.
+func (e *NotFoundError) Is(target error) bool {
+	return target == fs.ErrNotExist
+}
+
+// StringLoader is a Loader backed by an in-memory map. It is primarily useful
+// for testing.
+type StringLoader struct {
+	m map[string]string
+}
+
+// NewStringLoader returns a StringLoader serving the contents of m, keyed by
+// import path.
+func NewStringLoader(m map[string]string) *StringLoader {
+	return &StringLoader{m: m}
+}
+
+// Load returns the data at path or error if not found.
+func (s *StringLoader) Load(path string) (Loadable, error) {
+	data, ok := s.m[path]
+	if !ok {
+		return nil, &NotFoundError{Path: path}
+	}
+	return &nopCloser{strings.NewReader(data)}, nil
+}
+
+// ReadDir returns the names of the paths directly under path.
+func (s *StringLoader) ReadDir(path string) ([]string, error) {
+	var names []string
+	prefix := path + "/"
+	for p := range s.m {
+		if name := strings.TrimPrefix(p, prefix); name != p && !strings.Contains(name, "/") {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, &NotFoundError{Path: path}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// FileLoader is a Loader backed by the file system.
+type FileLoader struct {
+	SearchPaths []string
+}
+
+// Load searches for the path along each SearchPath and returns the first
+// reader found. If none found, returns an error.
+func (f *FileLoader) Load(path string) (Loadable, error) {
+	for _, searchPath := range f.SearchPaths {
+		absPath := filepath.Join(searchPath, filepath.FromSlash(path))
+		if info, err := os.Stat(absPath); err == nil && info.IsDir() {
+			continue
+		}
+		f, err := os.Open(absPath)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &fileCloser{bufio.NewReader(f), f}, nil
+	}
+	return nil, &NotFoundError{Path: path}
+}
+
+// ReadDir lists the first directory found for path along the SearchPaths.
+func (f *FileLoader) ReadDir(path string) ([]string, error) {
+	for _, searchPath := range f.SearchPaths {
+		entries, err := ioutil.ReadDir(filepath.Join(searchPath, filepath.FromSlash(path)))
+		if err != nil {
+			// Keep searching if path does not exist or is not a directory.
+			continue
+		}
+		var names []string
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				names = append(names, entry.Name())
+			}
+		}
+		return names, nil
+	}
+	return nil, &NotFoundError{Path: path}
+}
+
+// FSRoot is a file system searched by an FSLoader.
+type FSRoot struct {
+	Name string // Identifies the root in errors, e.g. "embed:stdlib".
+	FS   fs.FS
+}
+
+// FSLoader is a Loader backed by io/fs file systems, such as an embed.FS, a
+// *zip.Reader or an fstest.MapFS. Import paths are slash-separated paths
+// within each file system.
+type FSLoader struct {
+	Roots []FSRoot
+}
+
+// Load searches for the path in each root in order and returns the first
+// reader found. If none found, returns an error listing the searched roots.
+func (f *FSLoader) Load(path string) (Loadable, error) {
+	if !fs.ValidPath(path) {
+		return nil, fmt.Errorf("invalid import path: %s", path)
+	}
+	var searched []string
+	for _, root := range f.Roots {
+		searched = append(searched, root.Name)
+		file, err := root.FS.Open(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", root.Name, err)
+		}
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("%s: %s", root.Name, err)
+		}
+		if info.IsDir() {
+			file.Close()
+			continue
+		}
+		return &fileCloser{bufio.NewReader(file), file}, nil
+	}
+	return nil, &NotFoundError{Path: path, Searched: searched}
+}
+
+// ReadDir lists the first directory found for path in the roots.
+func (f *FSLoader) ReadDir(path string) ([]string, error) {
+	if !fs.ValidPath(path) {
+		return nil, fmt.Errorf("invalid import path: %s", path)
+	}
+	var searched []string
+	for _, root := range f.Roots {
+		searched = append(searched, root.Name)
+		info, err := fs.Stat(root.FS, path)
+		if errors.Is(err, fs.ErrNotExist) || (err == nil && !info.IsDir()) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", root.Name, err)
+		}
+		entries, err := fs.ReadDir(root.FS, path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", root.Name, err)
+		}
+		var names []string
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				names = append(names, entry.Name())
+			}
+		}
+		return names, nil
+	}
+	return nil, &NotFoundError{Path: path, Searched: searched}
+}
+
+// OverlayLoader is a Loader that serves in-memory contents for some paths and
+// falls through to another Loader for the rest. It is useful for editors,
+// which need to check unsaved buffers. It is safe for concurrent use.
+type OverlayLoader struct {
+	next Loader
+
+	mu      sync.RWMutex
+	overlay map[string]string
+}
+
+// NewOverlayLoader returns an OverlayLoader falling through to next.
+func NewOverlayLoader(next Loader) *OverlayLoader {
+	return &OverlayLoader{
+		next:    next,
+		overlay: make(map[string]string),
+	}
+}
+
+// Set overlays the contents of path.
+func (o *OverlayLoader) Set(path, data string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.overlay[path] = data
+}
+
+// Delete removes the overlay for path, so it is served by the underlying
+// Loader again.
+func (o *OverlayLoader) Delete(path string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	delete(o.overlay, path)
+}
+
+// Load returns the overlaid contents of path if set, or loads it from the
+// underlying Loader.
+func (o *OverlayLoader) Load(path string) (Loadable, error) {
+	o.mu.RLock()
+	data, ok := o.overlay[path]
+	o.mu.RUnlock()
+	if ok {
+		return &nopCloser{strings.NewReader(data)}, nil
+	}
+	return o.next.Load(path)
+}
+
+// ReadDir lists the directory at path of the underlying Loader, if it is a
+// DirLoader, together with the overlaid files in it.
+func (o *OverlayLoader) ReadDir(path string) ([]string, error) {
+	seen := make(map[string]bool)
+	var names []string
+	var err error
+	if dl, ok := o.next.(DirLoader); ok {
+		names, err = dl.ReadDir(path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		for _, name := range names {
+			seen[name] = true
+		}
+	}
+	o.mu.RLock()
+	prefix := path + "/"
+	for p := range o.overlay {
+		if name := strings.TrimPrefix(p, prefix); name != p && !strings.Contains(name, "/") && !seen[name] {
+			names = append(names, name)
+		}
+	}
+	o.mu.RUnlock()
+	if len(names) == 0 {
+		if err == nil {
+			err = &NotFoundError{Path: path}
+		}
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,152 @@
+package interp
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func zipFS(t *testing.T, files map[string]string) *zip.Reader {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, data := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestFSLoader(t *testing.T) {
+	loader := &FSLoader{
+		Roots: []FSRoot{
+			{
+				Name: "map",
+				FS: fstest.MapFS{
+					"foo":     {Data: []byte("map foo")},
+					"dir/bar": {Data: []byte("map bar")},
+				},
+			},
+			{
+				Name: "zip",
+				FS: zipFS(t, map[string]string{
+					"foo": "zip foo",
+					"baz": "zip baz",
+				}),
+			},
+		},
+	}
+	testCases := []struct {
+		name string
+		path string
+		data string
+		err  string
+	}{
+		{name: "first_root", path: "foo", data: "map foo"},
+		{name: "nested", path: "dir/bar", data: "map bar"},
+		{name: "fallback", path: "baz", data: "zip baz"},
+		{name: "directory", path: "dir", err: "unknown import: dir (searched map, zip)"},
+		{name: "missing", path: "qux", err: "unknown import: qux (searched map, zip)"},
+		{name: "invalid", path: "../foo", err: "invalid import path: ../foo"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := loader.Load(tc.path)
+			if tc.err != "" {
+				if err == nil || err.Error() != tc.err {
+					t.Fatalf("expected %q but got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			defer r.Close()
+			var data []rune
+			for {
+				c, _, err := r.ReadRune()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				data = append(data, c)
+			}
+			if string(data) != tc.data {
+				t.Errorf("expected %q but got %q", tc.data, data)
+			}
+		})
+	}
+}
+
+func TestFSLoaderCheck(t *testing.T) {
+	e := NewExecutor(&FSLoader{
+		Roots: []FSRoot{{
+			Name: "lib",
+			FS: fstest.MapFS{
+				"test": {Data: []byte("import foo;\nfunc main() {\n  foo.lib(true);\n}\n")},
+				"foo":  {Data: []byte("export func lib(bool b) {}")},
+			},
+		}},
+	})
+	if err := e.Check("test"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestFileLoaderPackages(t *testing.T) {
+	dir, err := ioutil.TempDir("", "loader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"main.apl":   "import lib;\nimport util;\nfunc main() {\n  lib.a();\n  util.b();\n}\n",
+		"lib.apl":    "export func a() {}",
+		"util/b.apl": "export func b() {}",
+		"util/c.txt": "not apl",
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	loader := &FileLoader{SearchPaths: []string{dir}}
+	names, err := loader.ReadDir("util")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(names, ","); got != "b.apl,c.txt" {
+		t.Errorf("expected b.apl,c.txt, got %q", got)
+	}
+	if _, err := loader.Load("util"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected directory not to load, got %v", err)
+	}
+	if err := NewExecutor(loader).Check("main"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
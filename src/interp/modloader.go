@@ -0,0 +1,236 @@
+package interp
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"mod"
+)
+
+// Module is a module version on disk.
+type Module struct {
+	Path    string
+	Version string // Empty for the main module.
+	Dir     string
+}
+
+// ModLoader is a Loader that resolves import paths through the apl.mod
+// manifest of a main module. Import paths starting with the path of a
+// required module are loaded from that module's directory. All other import
+// paths are loaded relative to the main module's root, with the main
+// module's path as an optional prefix.
+//
+// Requirements are followed transitively. If a module is required at several
+// versions, the highest one is used. As in minimal version selection, only
+// the requirements of selected versions count: a module required only by a
+// version that was upgraded is dropped.
+//
+// Source files of dependencies are verified against the apl.sum lockfile of
+// the main module. Loading a file whose content hash differs from the locked
+// one fails. Hashes of files not locked yet are recorded, and written out by
+// WriteSum. Files of the main module are neither verified nor recorded.
+type ModLoader struct {
+	Main *Module
+	Deps []*Module // Selected dependencies, sorted by path.
+
+	sumPath string
+	mu      sync.Mutex // Guards sum.
+	sum     *mod.Sum
+}
+
+// NewModLoader reads the manifest and lockfile in the root directory of the
+// main module and resolves its dependencies.
+func NewModLoader(root string) (*ModLoader, error) {
+	f, err := readManifest(root)
+	if err != nil {
+		return nil, err
+	}
+	m := &ModLoader{
+		Main:    &Module{Path: f.Module, Dir: root},
+		sumPath: filepath.Join(root, mod.SumName),
+		sum:     mod.NewSum(),
+	}
+	nodes := make(map[string]*reqNode)
+	if err := m.require(f, root, nodes); err != nil {
+		return nil, err
+	}
+	for _, dep := range m.selectDeps(f, nodes) {
+		m.Deps = append(m.Deps, dep)
+	}
+	sort.Slice(m.Deps, func(i, j int) bool { return m.Deps[i].Path < m.Deps[j].Path })
+	data, err := ioutil.ReadFile(m.sumPath)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if m.sum, err = mod.ParseSum(m.sumPath, data); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func readManifest(dir string) (*mod.File, error) {
+	path := filepath.Join(dir, mod.ManifestName)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return mod.Parse(path, data)
+}
+
+// A reqNode is a required module version and its manifest.
+type reqNode struct {
+	module *Module
+	file   *mod.File
+}
+
+func reqKey(path, version string) string {
+	return path + "@" + version
+}
+
+// require reads the manifests of all module versions required by f, which
+// lives in dir, directly or indirectly, into nodes.
+func (m *ModLoader) require(f *mod.File, dir string, nodes map[string]*reqNode) error {
+	for _, req := range f.Require {
+		if req.Path == m.Main.Path {
+			continue
+		}
+		key := reqKey(req.Path, req.Version)
+		if _, ok := nodes[key]; ok {
+			continue
+		}
+		depDir := filepath.Join(dir, filepath.FromSlash(req.Dir))
+		dep, err := readManifest(depDir)
+		if err != nil {
+			return fmt.Errorf("%s %s: %s", req.Path, req.Version, err)
+		}
+		if dep.Module != req.Path {
+			return fmt.Errorf("%s %s: %s declares module %s", req.Path, req.Version, depDir, dep.Module)
+		}
+		if dep.Version != "" && dep.Version != req.Version {
+			return fmt.Errorf("%s %s: %s provides version %s", req.Path, req.Version, depDir, dep.Version)
+		}
+		nodes[key] = &reqNode{module: &Module{Path: req.Path, Version: req.Version, Dir: depDir}, file: dep}
+		if err := m.require(dep, depDir, nodes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// selectDeps selects the highest required version of each module, and
+// returns the selected modules reachable from f through the requirements of
+// selected versions only.
+func (m *ModLoader) selectDeps(f *mod.File, nodes map[string]*reqNode) map[string]*Module {
+	highest := make(map[string]string)
+	for _, n := range nodes {
+		if v, ok := highest[n.module.Path]; !ok || mod.Compare(v, n.module.Version) < 0 {
+			highest[n.module.Path] = n.module.Version
+		}
+	}
+	selected := make(map[string]*Module)
+	var walk func(f *mod.File)
+	walk = func(f *mod.File) {
+		for _, req := range f.Require {
+			if _, ok := selected[req.Path]; ok || req.Path == m.Main.Path {
+				continue
+			}
+			n := nodes[reqKey(req.Path, highest[req.Path])]
+			selected[req.Path] = n.module
+			walk(n.file)
+		}
+	}
+	walk(f)
+	return selected
+}
+
+// locate returns the module an import path belongs to and the slash-separated
+// path within the module.
+func (m *ModLoader) locate(path string) (*Module, string) {
+	var best *Module
+	for _, dep := range m.Deps {
+		if (path == dep.Path || strings.HasPrefix(path, dep.Path+"/")) && (best == nil || len(dep.Path) > len(best.Path)) {
+			best = dep
+		}
+	}
+	if best == nil {
+		if path != m.Main.Path && !strings.HasPrefix(path, m.Main.Path+"/") {
+			return m.Main, path
+		}
+		best = m.Main
+	}
+	return best, strings.TrimPrefix(strings.TrimPrefix(path, best.Path), "/")
+}
+
+// Load loads path from the module it belongs to. Files of dependencies are
+// verified against the lockfile.
+func (m *ModLoader) Load(path string) (Loadable, error) {
+	module, rel := m.locate(path)
+	if rel == "" {
+		return nil, &NotFoundError{Path: path}
+	}
+	file := filepath.Join(module.Dir, filepath.FromSlash(rel))
+	if info, err := os.Stat(file); os.IsNotExist(err) || (err == nil && info.IsDir()) {
+		return nil, &NotFoundError{Path: path, Searched: []string{module.Dir}}
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if module != m.Main {
+		if err := m.verify(module, rel, data); err != nil {
+			return nil, err
+		}
+	}
+	return &nopCloser{bytes.NewReader(data)}, nil
+}
+
+// verify checks data against the locked hash of a file of module, locking it
+// if it is not locked yet.
+func (m *ModLoader) verify(module *Module, rel string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	hash := mod.Hash(data)
+	locked, ok := m.sum.Lookup(module.Path, module.Version, rel)
+	if !ok {
+		m.sum.Add(module.Path, module.Version, rel, hash)
+		return nil
+	}
+	if locked != hash {
+		return fmt.Errorf("%s %s/%s: checksum mismatch: %s has %s, but loaded %s",
+			module.Path, module.Version, rel, m.sumPath, locked, hash)
+	}
+	return nil
+}
+
+// ReadDir lists the directory at path in the module it belongs to.
+func (m *ModLoader) ReadDir(path string) ([]string, error) {
+	module, rel := m.locate(path)
+	entries, err := ioutil.ReadDir(filepath.Join(module.Dir, filepath.FromSlash(rel)))
+	if err != nil {
+		return nil, &NotFoundError{Path: path, Searched: []string{module.Dir}}
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+// WriteSum writes the lockfile, including the hashes of all files loaded
+// from dependencies so far.
+func (m *ModLoader) WriteSum() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return ioutil.WriteFile(m.sumPath, m.sum.Format(), 0644)
+}
@@ -0,0 +1,155 @@
+package interp
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTree writes files, keyed by slash-separated path, below dir.
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	for name, data := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestModLoader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "modloader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeTree(t, dir, map[string]string{
+		"app/apl.mod": "module example.com/app\napl 0.1\nrequire example.com/lib v1.2.0 ../lib\n",
+		"app/main.apl": `
+import example.com/lib;
+import example.com/app/util;
+func main() {
+  lib.lib(true);
+  util.helper();
+}
+`,
+		"app/util.apl":  "export func helper() {}",
+		"lib/apl.mod":   "module example.com/lib\nversion v1.2.0\nrequire example.com/base v0.1.0 ../base\n",
+		"lib/lib.apl":   "import example.com/base;\nexport func lib(bool b) {\n  base.base();\n}\n",
+		"base/apl.mod":  "module example.com/base\nrequire example.com/app v0.0.1 ../app\n",
+		"base/base.apl": "export func base() {}",
+	})
+	root := filepath.Join(dir, "app")
+
+	loader, err := NewModLoader(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var deps []string
+	for _, dep := range loader.Deps {
+		deps = append(deps, dep.Path+"@"+dep.Version)
+	}
+	if got := strings.Join(deps, ","); got != "example.com/base@v0.1.0,example.com/lib@v1.2.0" {
+		t.Errorf("unexpected deps %q", got)
+	}
+	if err := NewExecutor(loader).Check("main.apl"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := loader.WriteSum(); err != nil {
+		t.Fatal(err)
+	}
+	sum, err := ioutil.ReadFile(filepath.Join(root, "apl.sum"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(sum)), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "example.com/base v0.1.0/base.apl sha256:") ||
+		!strings.HasPrefix(lines[1], "example.com/lib v1.2.0/lib.apl sha256:") {
+		t.Errorf("unexpected apl.sum:\n%s", sum)
+	}
+
+	// Unchanged sources pass verification.
+	loader, err = NewModLoader(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := NewExecutor(loader).Check("main.apl"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Changed sources of dependencies fail.
+	writeTree(t, dir, map[string]string{"lib/lib.apl": "func lib(bool b) {}"})
+	loader, err = NewModLoader(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = NewExecutor(loader).Check("main.apl")
+	if err == nil || !strings.HasPrefix(err.Error(), "example.com/lib v1.2.0/lib.apl: checksum mismatch: ") {
+		t.Errorf("expected checksum mismatch, got %v", err)
+	}
+}
+
+func TestModLoaderUpgrade(t *testing.T) {
+	dir, err := ioutil.TempDir("", "modloader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeTree(t, dir, map[string]string{
+		"app/apl.mod":   "module app\nrequire example.com/lib v1.0.0 ../lib1\nrequire example.com/other v1.0.0 ../other\n",
+		"other/apl.mod": "module example.com/other\nrequire example.com/lib v2.0.0 ../lib2\n",
+		"lib1/apl.mod":  "module example.com/lib\nrequire example.com/old v0.1.0 ../old\n",
+		"lib2/apl.mod":  "module example.com/lib\n",
+		"old/apl.mod":   "module example.com/old\n",
+	})
+
+	// The upgrade of lib drops old, which only lib v1.0.0 requires.
+	loader, err := NewModLoader(filepath.Join(dir, "app"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var deps []string
+	for _, dep := range loader.Deps {
+		deps = append(deps, dep.Path+"@"+dep.Version)
+	}
+	if got := strings.Join(deps, ","); got != "example.com/lib@v2.0.0,example.com/other@v1.0.0" {
+		t.Errorf("unexpected deps %q", got)
+	}
+}
+
+func TestModLoaderErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "modloader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeTree(t, dir, map[string]string{
+		"lib/apl.mod":       "module example.com/lib\nversion v1.3.0\n",
+		"other/apl.mod":     "module example.com/other\n",
+		"version/apl.mod":   "module app\nrequire example.com/lib v1.2.0 ../lib\n",
+		"path/apl.mod":      "module app\nrequire example.com/lib v1.2.0 ../other\n",
+		"missing/apl.mod":   "module app\nrequire example.com/lib v1.2.0 ../nowhere\n",
+		"malformed/apl.mod": "modul app\n",
+	})
+	testCases := []struct {
+		root string
+		err  string
+	}{
+		{root: "version", err: "example.com/lib v1.2.0: " + filepath.Join(dir, "lib") + " provides version v1.3.0"},
+		{root: "path", err: "example.com/lib v1.2.0: " + filepath.Join(dir, "other") + " declares module example.com/other"},
+		{root: "missing", err: "example.com/lib v1.2.0: open " + filepath.Join(dir, "nowhere", "apl.mod") + ": no such file or directory"},
+		{root: "malformed", err: filepath.Join(dir, "malformed", "apl.mod") + ":1: unknown directive: modul"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.root, func(t *testing.T) {
+			_, err := NewModLoader(filepath.Join(dir, tc.root))
+			if err == nil || err.Error() != tc.err {
+				t.Errorf("expected %q but got %v", tc.err, err)
+			}
+		})
+	}
+}
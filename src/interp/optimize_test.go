@@ -0,0 +1,123 @@
+package interp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestOptimize(t *testing.T) {
+	testCases := []struct {
+		name   string
+		input  string
+		limits Limits
+		want   string // Output, followed by the error and its stack trace.
+	}{
+		{
+			name: "folding",
+			input: `
+func main() {
+  println(2 * 3 + 1, 7 / 2 - 1, 1.5 * 2.0, "a" + "b", -(1 + 2), 1 < 2 == true);
+}
+`,
+			want: "7 2 3 ab -3 true\n",
+		},
+		{
+			name: "division_by_zero",
+			input: `
+func div() int {
+  return 1 + 1 / (2 - 2);
+}
+
+func main() {
+  println(div());
+}
+`,
+			want: "main.apl:3:16 division by zero\nmain.apl:7:11 in main\nmain.apl:3:16 in div\n",
+		},
+		{
+			name: "dead_branches",
+			input: `
+func f(bool b) int {
+  if 1 > 2 {
+    return 1;
+  } else if false {
+    return 2;
+  }
+  if b && true {
+    return 3;
+  }
+  return 4;
+}
+
+func main() {
+  if true || false {
+    println(f(true));
+  }
+  println(f(false));
+}
+`,
+			want: "3\n4\n",
+		},
+		{
+			name: "effects",
+			input: `
+func main() {
+  println(tick() && false, false && tick(), tick() || true, true || tick());
+  println(!!tick(), tick() && true, false || tick());
+}
+`,
+			want: "tick 1\ntick 2\nfalse false true true\ntick 3\ntick 4\ntick 5\ntrue false true\n",
+		},
+		{
+			name: "steps",
+			input: `
+func main() {
+  if 1 == 1 {
+    println(1);
+    if !false {
+      println(2);
+    }
+  }
+  println(3);
+}
+`,
+			limits: Limits{Steps: 2},
+			want:   "1\nmain.apl:5:5 step limit exceeded\nmain.apl:5:5 in main\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, optimize := range []bool{false, true} {
+				for _, eng := range []Engine{Tree, VM} {
+					e := NewExecutor(NewStringLoader(map[string]string{"main.apl": tc.input}))
+					e.SetOptimize(optimize)
+					e.SetEngine(eng)
+					e.SetLimits(tc.limits)
+					var out strings.Builder
+					e.SetOutput(&out)
+					ticks := 0
+					register(t, e, "tick", func() bool {
+						ticks++
+						fmt.Fprintf(&out, "tick %d\n", ticks)
+						return ticks%2 == 1
+					})
+					err := e.Run(context.Background(), "main.apl")
+					got := out.String()
+					if err != nil {
+						got += err.Error()
+						var re *RuntimeError
+						if errors.As(err, &re) {
+							got += "\n" + re.StackTrace()
+						}
+					}
+					if got != tc.want {
+						t.Errorf("optimize %v, engine %d: expected\n%s\ngot\n%s", optimize, eng, tc.want, got)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package interp
+
+import (
+	"ast"
+	"types"
+)
+
+// Package is a checked package.
+type Package struct {
+	Path  string
+	Files []*ast.File
+	Scope map[*ast.File]*types.Context // Contexts the files were checked in.
+}
+
+// Packages checks the package at an import path and returns it, followed by
+// the packages it imports in the order they are first imported. Returns the
+// error of Check if the program does not check.
+func (e *Executor) Packages(path string) ([]*Package, error) {
+	if err := e.Check(path); err != nil {
+		return nil, err
+	}
+	var pkgs []*Package
+	seen := make(map[string]bool)
+	var visit func(path string) error
+	visit = func(path string) error {
+		if seen[path] {
+			return nil
+		}
+		seen[path] = true
+		paths, err := e.Resolve(path)
+		if err != nil {
+			return err
+		}
+		p := &Package{Path: path, Scope: make(map[*ast.File]*types.Context)}
+		pkgs = append(pkgs, p)
+		for _, fp := range paths {
+			if f := e.File(fp); f != nil {
+				p.Files = append(p.Files, f)
+				p.Scope[f] = e.Scope(fp)
+			}
+		}
+		for _, f := range p.Files {
+			for _, imp := range f.Imports {
+				if err := visit(imp.Name); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if err := visit(path); err != nil {
+		return nil, err
+	}
+	return pkgs, nil
+}
@@ -0,0 +1,258 @@
+package interp
+
+import (
+	"context"
+	"fmt"
+
+	"ast"
+	"ast/expr"
+	"ast/optimize"
+	"ast/source"
+	"ast/statement"
+	"types"
+	"values"
+)
+
+// function is a checked func declaration along with the context of the file
+// declaring it, in which names called by the func are resolved.
+type function struct {
+	module string // Import path of the declaring package.
+	decl   *ast.FnDecl
+	typ    *types.Func
+	scope  *types.Context
+
+	optimized bool                // Whether the func was optimized, see SetOptimize.
+	tails     map[*expr.Call]bool // Calls in tail position, see tailCalls.
+}
+
+// Run checks the package at an import path and calls its main func, which
+// must take no params and return nothing. Execution stops with an error once
+// ctx is done or a limit set with SetLimits is exceeded.
+func (e *Executor) Run(ctx context.Context, path string) error {
+	if err := e.Check(path); err != nil {
+		return err
+	}
+	fn, ok := e.funcs[path]["main"]
+	if !ok {
+		return fmt.Errorf("main not declared in module %s", path)
+	}
+	if len(fn.decl.Args) > 0 || fn.decl.Return != nil {
+		return fn.decl.Errf("func main must take no params and return nothing")
+	}
+	_, err := e.exec(e.execution(ctx), fn, nil)
+	return finish(err)
+}
+
+// Call calls the func fn declared in the package at the import path module
+// with Go args, and returns its result as a Go value, or nil if the func does
+// not return anything. The package is checked on first use; later calls
+// reuse the checked program. Args are converted with values.FromGo and the
+// result with values.ToGo to values.GoType of the return type. As for Run,
+// the call stops with an error once ctx is done or a limit is exceeded.
+func (e *Executor) Call(ctx context.Context, module, fn string, args ...interface{}) (interface{}, error) {
+	if err := e.Check(module); err != nil {
+		return nil, err
+	}
+	f, ok := e.funcs[module][fn]
+	if !ok {
+		return nil, fmt.Errorf("%s not declared in module %s", fn, module)
+	}
+	if len(args) != len(f.typ.Args) {
+		return nil, fmt.Errorf("%s expects %d args, not %d", fn, len(f.typ.Args), len(args))
+	}
+	vals := make([]values.Value, len(args))
+	for i, arg := range args {
+		v, ok := valueOf(arg, f.typ.Args[i])
+		if !ok {
+			return nil, fmt.Errorf("%s arg #%d expects %v, not Go %T", fn, i+1, f.typ.Args[i], arg)
+		}
+		vals[i] = v
+	}
+	v, err := e.exec(e.execution(ctx), f, vals)
+	if err != nil || v == nil {
+		return nil, finish(err)
+	}
+	return values.ToGo(v, values.GoType(f.typ.Return))
+}
+
+// valueOf converts the Go value v to an apl value of type t. Reports false if
+// v does not convert to t.
+func valueOf(v interface{}, t types.Type) (values.Value, bool) {
+	x, err := values.FromGo(v)
+	if err != nil || !x.Type().Equals(t) {
+		return nil, false
+	}
+	return x, true
+}
+
+// execution returns the state of a new execution.
+func (e *Executor) execution(ctx context.Context) *execution {
+	return &execution{ctx: ctx, limits: e.limits}
+}
+
+// call calls a declared func with evaluated args. Tail calls made by the
+// func replace it, so that they take no further Go stack.
+func (e *Executor) call(x *execution, fn *function, args []values.Value) (values.Value, error) {
+	x.depth++
+	defer func() { x.depth-- }()
+	for {
+		f := &frame{
+			x:    x,
+			e:    e,
+			fn:   fn,
+			vars: make(map[string]values.Value, len(args)),
+		}
+		for i, arg := range fn.decl.Args {
+			f.vars[arg.Nam] = args[i]
+		}
+		v, ret, err := statement.ExecList(f, fn.decl.Statements)
+		if err != nil {
+			if _, ok := err.(*RuntimeError); !ok {
+				err = unwind(err, fn.decl.Nam, fn.decl)
+			}
+			return nil, err
+		}
+		if f.tail != nil {
+			fn, args = f.tail, f.args
+			continue
+		}
+		if !ret && fn.decl.Return != nil {
+			err := fn.decl.End.Errf("missing return at end of %s", fn.decl.Nam)
+			return nil, unwind(err, fn.decl.Nam, fn.decl.End)
+		}
+		return v, nil
+	}
+}
+
+// frame is the environment of a call to a declared func. It implements
+// expr.Env.
+type frame struct {
+	x    *execution
+	e    *Executor
+	fn   *function
+	vars map[string]values.Value
+
+	// A tail call made by the func, which call makes once it returns.
+	tail *function
+	args []values.Value
+}
+
+// Var returns the value of an arg.
+func (f *frame) Var(name string) (values.Value, error) {
+	v, ok := f.vars[name]
+	if !ok {
+		return nil, fmt.Errorf("undefined: %s", name)
+	}
+	return v, nil
+}
+
+// Call resolves name from the file declaring the func of the frame and calls
+// it. Errors returned by builtins are positioned at src. Errors of calls of
+// declared funcs record the frame in their stack. Tail calls of declared
+// funcs are left to call, returning a nil result.
+func (f *frame) Call(src source.Source, name string, args []values.Value) (values.Value, error) {
+	pkg, local, err := f.fn.scope.Resolve(name)
+	if err != nil {
+		return nil, src.Errf(err.Error())
+	}
+	if pkg != "" {
+		if call, ok := src.(*expr.Call); ok && f.fn.tails[call] {
+			f.tail, f.args = f.e.funcs[pkg][local], args
+			return nil, nil
+		}
+		if f.x.depth >= f.x.limits.CallDepth {
+			return nil, source.Wrap(src, ErrCallDepth)
+		}
+		v, err := f.e.call(f.x, f.e.funcs[pkg][local], args)
+		if err != nil {
+			return nil, unwind(err, f.fn.decl.Nam, src)
+		}
+		return v, nil
+	}
+	b, ok := f.e.builtins[local]
+	if !ok {
+		return nil, src.Errf("%s is not a func", name)
+	}
+	v, err := callBuiltin(f.e, b, args)
+	if err != nil {
+		if _, ok := err.(*source.Error); !ok {
+			err = source.Wrap(src, fmt.Errorf("%s: %w", name, err))
+		}
+		return nil, err
+	}
+	if err := f.x.alloc(src, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// callBuiltin calls a builtin, converting a panic into an error.
+func callBuiltin(e *Executor, b *Builtin, args []values.Value) (v values.Value, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			v, err = nil, fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return b.Fn(e, args)
+}
+
+// Step counts a statement against the limits of the execution.
+func (f *frame) Step(src source.Source) error {
+	return f.x.step(src)
+}
+
+// Alloc counts a computed value against the limits of the execution.
+func (f *frame) Alloc(src source.Source, v values.Value) error {
+	return f.x.alloc(src, v)
+}
+
+// declare indexes the func declarations of a checked file of the package at
+// an import path. A compiled program is dropped, as it lacks them.
+func (e *Executor) declare(path string, file *ast.File, scope *types.Context) {
+	e.prog = nil
+	fns, ok := e.funcs[path]
+	if !ok {
+		fns = make(map[string]*function)
+		e.funcs[path] = fns
+	}
+	for _, decl := range file.Decls {
+		if d, ok := decl.(*ast.FnDecl); ok {
+			typ, _ := scope.Get(d.Nam)
+			fn := &function{module: path, decl: d, typ: typ.(*types.Func), scope: scope}
+			if e.optimize {
+				fn.decl = optimize.Func(d)
+				fn.optimized = true
+			}
+			fn.tails = tailCalls(fn)
+			fns[d.Nam] = fn
+			e.decls = append(e.decls, fn)
+		}
+	}
+}
+
+// tailCalls returns the calls of declared funcs made by the return statements
+// of a func. They reuse the frame of the caller, so tail recursion runs in
+// constant space. Replaced callers neither count against Limits.CallDepth nor
+// show in stack traces.
+func tailCalls(fn *function) map[*expr.Call]bool {
+	tails := make(map[*expr.Call]bool)
+	var visit func(stmts []statement.Statement)
+	visit = func(stmts []statement.Statement) {
+		for _, stmt := range stmts {
+			switch s := stmt.(type) {
+			case *statement.Return:
+				if call, ok := s.Expr.(*expr.Call); ok {
+					if pkg, _, err := fn.scope.Resolve(call.Nam); err == nil && pkg != "" {
+						tails[call] = true
+					}
+				}
+			case *statement.Block:
+				visit(s.Statements)
+			case *statement.If:
+				visit([]statement.Statement{s.Then, s.Else})
+			}
+		}
+	}
+	visit(fn.decl.Statements)
+	return tails
+}
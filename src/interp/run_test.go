@@ -0,0 +1,240 @@
+package interp
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"types"
+	"values"
+)
+
+func TestRun(t *testing.T) {
+	testCases := []struct {
+		name   string
+		input  map[string]string
+		output string
+		err    string
+	}{
+		{
+			name: "print",
+			input: map[string]string{
+				"test": `
+func main() {
+  print("a", 1, true);
+  println();
+  println("b", 2, false);
+}
+`,
+			},
+			output: "a1true\nb 2 false\n",
+		},
+		{
+			name: "operators",
+			input: map[string]string{
+				"test": `
+func main() {
+  println(1 + 2 * 3, (1 + 2) * 3, 7 / 2, 7 % 2, -7 + 1);
+  println("a" + "b", "a" < "b", 1 == 1, 1 != 1, 2 <= 1);
+  println(true && !false, false || false, !(1 > 2));
+}
+`,
+			},
+			output: "7 9 3 1 -6\nab true true false false\ntrue false true\n",
+		},
+		{
+			name: "floats",
+			input: map[string]string{
+				"test": `
+func main() {
+  println(1.5 * 2.0, 1.0 / 4.0, -0.5 + 1.0, 2.5 > 2.0);
+}
+`,
+			},
+			output: "3 0.25 0.5 true\n",
+		},
+		{
+			name: "recursion",
+			input: map[string]string{
+				"test": `
+func fib(int n) int {
+  if n < 2 {
+    return n;
+  }
+  return fib(n - 1) + fib(n - 2);
+}
+func count(int n) {
+  if n == 0 {
+    println("done");
+  } else if n % 2 == 0 {
+    print(n);
+    count(n - 1);
+  } else {
+    count(n - 1);
+  }
+}
+func main() {
+  println(fib(10));
+  count(5);
+}
+`,
+			},
+			output: "55\n42done\n",
+		},
+		{
+			name: "short_circuit",
+			input: map[string]string{
+				"test": `
+func fail() bool {
+  return 1 / 0 == 0;
+}
+func main() {
+  println(false && fail(), true || fail());
+}
+`,
+			},
+			output: "false true\n",
+		},
+		{
+			name: "imports",
+			input: map[string]string{
+				"test": `
+import lib;
+import util { Twice };
+func main() {
+  println(lib.Greet("apl"), Twice(21));
+}
+`,
+				"lib": `
+func greeting() string {
+  return "hello ";
+}
+func Greet(string name) string {
+  return greeting() + name;
+}
+`,
+				"util": `func Twice(int x) int { return 2 * x; }`,
+			},
+			output: "hello apl 42\n",
+		},
+		{
+			name: "division_by_zero",
+			input: map[string]string{
+				"test": `
+func div(int x, int y) int {
+  return x / y;
+}
+func main() {
+  println(div(1, 0));
+}
+`,
+			},
+			err: "test:3:12 division by zero",
+		},
+		{
+			name: "missing_return",
+			input: map[string]string{
+				"test": `
+func f(int x) int {
+  if x > 0 {
+    return x;
+  }
+}
+func main() {
+  println(f(0));
+}
+`,
+			},
+			err: "test:6:1 missing return at end of f",
+		},
+		{
+			name: "main_params",
+			input: map[string]string{
+				"test": `func main(int x) {}`,
+			},
+			err: "test:1:1 func main must take no params and return nothing",
+		},
+		{
+			name: "no_main",
+			input: map[string]string{
+				"test": `func foo() {}`,
+			},
+			err: "main not declared in module test",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := NewExecutor(NewStringLoader(tc.input))
+			var out strings.Builder
+			e.SetOutput(&out)
+			err := e.Run(context.Background(), "test")
+			if tc.err == "" && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			} else if tc.err != "" && (err == nil || err.Error() != tc.err) {
+				t.Errorf("expected %q but got %v", tc.err, err)
+			}
+			if out.String() != tc.output {
+				t.Errorf("expected output %q but got %q", tc.output, out.String())
+			}
+		})
+	}
+}
+
+func TestRegister(t *testing.T) {
+	e := NewExecutor(NewStringLoader(map[string]string{
+		"test": `
+func main() {
+  println(double(21));
+  fail();
+}
+`,
+	}))
+	var out strings.Builder
+	e.SetOutput(&out)
+	err := e.Register(&Builtin{
+		Name: "double",
+		Type: &types.Func{Args: []types.Type{&types.Int{}}, Return: &types.Int{}},
+		Fn: func(e *Executor, args []values.Value) (values.Value, error) {
+			return &values.Int{V: 2 * args[0].(*values.Int).V}, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = e.Register(&Builtin{
+		Name: "fail",
+		Type: &types.Func{},
+		Fn: func(e *Executor, args []values.Value) (values.Value, error) {
+			return nil, errors.New("failed")
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Register(&Builtin{Name: "int", Type: &types.Func{}}); err == nil {
+		t.Errorf("expected error registering int")
+	}
+	err = e.Register(&Builtin{Name: "none", Type: &types.Func{Variadic: true}})
+	if err == nil || err.Error() != "variadic builtin none takes no args" {
+		t.Errorf("expected variadic error but got %v", err)
+	}
+	if err := e.Run(context.Background(), "test"); err == nil || err.Error() != "test:4:3 fail: failed" {
+		t.Errorf("expected fail error but got %v", err)
+	}
+	if out.String() != "42\n" {
+		t.Errorf("expected output %q but got %q", "42\n", out.String())
+	}
+}
+
+func TestRunE2E(t *testing.T) {
+	e := NewExecutor(&FileLoader{SearchPaths: []string{"../../e2e"}})
+	var out strings.Builder
+	e.SetOutput(&out)
+	if err := e.Run(context.Background(), "main"); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "not 1\n" {
+		t.Errorf("expected output %q but got %q", "not 1\n", out.String())
+	}
+}
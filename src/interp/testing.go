@@ -0,0 +1,29 @@
+package interp
+
+import "strings"
+
+// TestPrefix starts the names of test funcs.
+const TestPrefix = "test_"
+
+// Tests checks the package at an import path and returns the names of its
+// test funcs in order of declaration. Test funcs are the funcs whose names
+// start with TestPrefix. Like main, they must take no params and return
+// nothing. A test passes if calling it, see Call, returns without error;
+// the assert builtin fails it with a message positioned at the assert.
+func (e *Executor) Tests(path string) ([]string, error) {
+	if err := e.Check(path); err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, fn := range e.decls {
+		d := fn.decl
+		if fn.module != path || !strings.HasPrefix(d.Nam, TestPrefix) {
+			continue
+		}
+		if len(d.Args) > 0 || d.Return != nil {
+			return nil, d.Errf("test func %s must take no params and return nothing", d.Nam)
+		}
+		names = append(names, d.Nam)
+	}
+	return names, nil
+}
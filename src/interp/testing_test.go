@@ -0,0 +1,122 @@
+package interp
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestTests(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input map[string]string
+		tests []string
+		err   string
+	}{
+		{
+			name: "discover",
+			input: map[string]string{
+				"main": `import lib;
+
+func test_add() {
+  assert(1 + 1 == 2, "1 + 1");
+}
+
+func testing() {
+}
+
+func test_lib() {
+  lib.test_helper();
+}
+
+func main() {
+}
+`,
+				"lib": `export func test_helper() {
+}
+`,
+			},
+			tests: []string{"test_add", "test_lib"},
+		},
+		{
+			name: "none",
+			input: map[string]string{
+				"main": `func main() {
+}
+`,
+			},
+		},
+		{
+			name: "signature",
+			input: map[string]string{
+				"main": `func test_args(int x) {
+}
+`,
+			},
+			err: "main:1:1 test func test_args must take no params and return nothing",
+		},
+		{
+			name: "check_error",
+			input: map[string]string{
+				"main": `func test_fail() {
+  assert(1, "one");
+}
+`,
+			},
+			err: "main:2:3 assert param #1 expects type<bool>, not type<int>",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := NewExecutor(NewStringLoader(tc.input))
+			tests, err := e.Tests("main")
+			if tc.err != "" {
+				if err == nil || err.Error() != tc.err {
+					t.Fatalf("expected %q but got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(tests, tc.tests) {
+				t.Errorf("expected tests %q but got %q", tc.tests, tests)
+			}
+		})
+	}
+}
+
+func TestAssert(t *testing.T) {
+	src := map[string]string{"main": `func check(int x) {
+  assert(x > 0, "x is positive");
+}
+
+func test_pass() {
+  check(1);
+}
+
+func test_fail() {
+  check(1);
+  check(-1);
+}
+`}
+	for _, eng := range []Engine{Tree, VM} {
+		e := NewExecutor(NewStringLoader(src))
+		e.SetEngine(eng)
+		if _, err := e.Call(context.Background(), "main", "test_pass"); err != nil {
+			t.Errorf("engine %v: unexpected error: %v", eng, err)
+		}
+		_, err := e.Call(context.Background(), "main", "test_fail")
+		var re *RuntimeError
+		if !errors.As(err, &re) {
+			t.Fatalf("engine %v: expected runtime error but got %v", eng, err)
+		}
+		if want := "main:2:3 assert: x is positive"; err.Error() != want {
+			t.Errorf("engine %v: expected %q but got %q", eng, want, err)
+		}
+		if want := "main:11:3 in test_fail\nmain:2:3 in check\n"; re.StackTrace() != want {
+			t.Errorf("engine %v: expected trace\n%s\ngot\n%s", eng, want, re.StackTrace())
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package interp
+
+import (
+	"fmt"
+
+	"ast/expr"
+	"ast/source"
+	"bytecode"
+	"values"
+)
+
+// Engine selects how Run and Call execute apl code.
+type Engine int
+
+const (
+	// VM compiles the checked program to bytecode, see Compile, and runs it
+	// on a stack machine. It is the default.
+	VM Engine = iota
+	// Tree evaluates the checked AST directly.
+	Tree
+)
+
+// SetEngine sets the engine of executions started after the call. Both
+// engines give the same results, errors and stack traces, and count steps
+// and values alike.
+func (e *Executor) SetEngine(eng Engine) {
+	e.engine = eng
+}
+
+// exec calls a declared func with evaluated args, using the engine of the
+// Executor.
+func (e *Executor) exec(x *execution, fn *function, args []values.Value) (values.Value, error) {
+	if e.engine == Tree {
+		return e.call(x, fn, args)
+	}
+	p, err := e.program()
+	if err != nil {
+		return nil, err
+	}
+	return e.runVM(x, p, p.Funcs[p.index[fn]], args)
+}
+
+// vmFrame is the state of a call of a compiled func.
+type vmFrame struct {
+	fn    *bytecode.Func
+	pc    int // Index of the next instruction.
+	base  int // Index of the first local on the stack.
+	depth int // Call depth, counting the frames of inlined calls.
+}
+
+// runVM calls the compiled func fn with evaluated args and runs until it
+// returns.
+func (e *Executor) runVM(x *execution, p *program, fn *bytecode.Func, args []values.Value) (values.Value, error) {
+	stack := make([]values.Value, 0, 256)
+	stack = append(stack, args...)
+	stack = grow(stack, len(fn.Locals)-len(args))
+	frames := []vmFrame{{fn: fn, depth: 1}}
+	fr := &frames[0]
+	for {
+		f := fr.fn
+		pc := fr.pc
+		in := f.Code[pc]
+		fr.pc++
+		var err error
+		switch op := in.Op(); op {
+		case bytecode.Step:
+			if err = x.tick(); err != nil {
+				err = source.Wrap(&f.Lines[pc], err)
+			}
+		case bytecode.Const:
+			stack = append(stack, f.Consts[in.Arg()])
+		case bytecode.Load:
+			stack = append(stack, stack[fr.base+in.Arg()])
+		case bytecode.Store:
+			n := len(stack)
+			stack[fr.base+in.Arg()] = stack[n-1]
+			stack = stack[:n-1]
+		case bytecode.Pop:
+			stack = stack[:len(stack)-1]
+		case bytecode.Alloc:
+			err = x.alloc(&f.Lines[pc], stack[len(stack)-1])
+		case bytecode.Add, bytecode.Sub, bytecode.Mul, bytecode.Div, bytecode.Mod,
+			bytecode.Eq, bytecode.NotEq, bytecode.Less, bytecode.LessEq, bytecode.Greater, bytecode.GreaterEq:
+			n := len(stack)
+			var v values.Value
+			if v, err = binary(op, stack[n-2], stack[n-1]); err != nil {
+				err = f.Lines[pc].Errf("%v", err)
+				break
+			}
+			stack = append(stack[:n-2], v)
+			err = x.alloc(&f.Lines[pc], v)
+		case bytecode.Neg, bytecode.Not:
+			n := len(stack)
+			var v values.Value
+			if v, err = unary(op, stack[n-1]); err != nil {
+				err = f.Lines[pc].Errf("%v", err)
+				break
+			}
+			stack[n-1] = v
+			err = x.alloc(&f.Lines[pc], v)
+		case bytecode.Jump:
+			fr.pc = in.Arg()
+		case bytecode.JumpFalse:
+			n := len(stack)
+			if !stack[n-1].(*values.Bool).V {
+				fr.pc = in.Arg()
+			}
+			stack = stack[:n-1]
+		case bytecode.JumpFalseOrPop, bytecode.JumpTrueOrPop:
+			n := len(stack)
+			if stack[n-1].(*values.Bool).V == (op == bytecode.JumpTrueOrPop) {
+				fr.pc = in.Arg()
+			} else {
+				stack = stack[:n-1]
+			}
+		case bytecode.Call:
+			site := f.Calls[in.Arg()]
+			depth := fr.depth
+			if inl := f.Inl[pc]; inl >= 0 {
+				depth += f.Inlined[inl].Depth
+			}
+			if depth >= x.limits.CallDepth {
+				err = source.Wrap(&f.Lines[pc], ErrCallDepth)
+				break
+			}
+			callee := p.Funcs[site.Target]
+			frames = append(frames, vmFrame{fn: callee, base: len(stack) - site.Args, depth: depth + 1})
+			fr = &frames[len(frames)-1]
+			stack = grow(stack, len(callee.Locals)-site.Args)
+		case bytecode.Enter:
+			if fr.depth+f.Inlined[in.Arg()].Depth > x.limits.CallDepth {
+				err = source.Wrap(&f.Lines[pc], ErrCallDepth)
+			}
+		case bytecode.TailCall:
+			site := f.Calls[in.Arg()]
+			callee := p.Funcs[site.Target]
+			n := len(stack) - site.Args
+			copy(stack[fr.base:], stack[n:])
+			stack = grow(stack[:fr.base+site.Args], len(callee.Locals)-site.Args)
+			fr.fn = callee
+			fr.pc = 0
+		case bytecode.CallBuiltin:
+			site := f.Calls[in.Arg()]
+			n := len(stack) - site.Args
+			args := make([]values.Value, site.Args)
+			copy(args, stack[n:])
+			stack = stack[:n]
+			var v values.Value
+			if v, err = callBuiltin(e, p.builtins[site.Target], args); err != nil {
+				if _, ok := err.(*source.Error); !ok {
+					err = source.Wrap(&f.Lines[pc], fmt.Errorf("%s: %w", site.Name, err))
+				}
+				break
+			}
+			stack = append(stack, v)
+			err = x.alloc(&f.Lines[pc], v)
+		case bytecode.Return, bytecode.ReturnNil:
+			var v values.Value
+			if op == bytecode.Return {
+				v = stack[len(stack)-1]
+			}
+			stack = stack[:fr.base]
+			frames = frames[:len(frames)-1]
+			if len(frames) == 0 {
+				return v, nil
+			}
+			fr = &frames[len(frames)-1]
+			stack = append(stack, v)
+		case bytecode.MissingReturn:
+			err = f.Lines[pc].Errf("missing return at end of %s", f.Name)
+		default:
+			err = f.Lines[pc].Errf("unknown instruction %v", in)
+		}
+		if err != nil {
+			return nil, unwindFrames(err, frames)
+		}
+	}
+}
+
+// grow appends n nil values to the stack.
+func grow(stack []values.Value, n int) []values.Value {
+	for ; n > 0; n-- {
+		stack = append(stack, nil)
+	}
+	return stack
+}
+
+// binary applies a binary operator op. Operations on ints are done in place,
+// the rest as by expr.ApplyBinary.
+func binary(op bytecode.Op, x, y values.Value) (values.Value, error) {
+	if x, ok := x.(*values.Int); ok {
+		y := y.(*values.Int)
+		switch op {
+		case bytecode.Add:
+			return &values.Int{V: x.V + y.V}, nil
+		case bytecode.Sub:
+			return &values.Int{V: x.V - y.V}, nil
+		case bytecode.Mul:
+			return &values.Int{V: x.V * y.V}, nil
+		case bytecode.Eq:
+			return &values.Bool{V: x.V == y.V}, nil
+		case bytecode.NotEq:
+			return &values.Bool{V: x.V != y.V}, nil
+		case bytecode.Less:
+			return &values.Bool{V: x.V < y.V}, nil
+		case bytecode.LessEq:
+			return &values.Bool{V: x.V <= y.V}, nil
+		case bytecode.Greater:
+			return &values.Bool{V: x.V > y.V}, nil
+		case bytecode.GreaterEq:
+			return &values.Bool{V: x.V >= y.V}, nil
+		}
+	}
+	return expr.ApplyBinary(op.Operator(), x, y)
+}
+
+// unary applies a unary operator op as by expr.ApplyUnary.
+func unary(op bytecode.Op, x values.Value) (values.Value, error) {
+	return expr.ApplyUnary(op.Operator(), x)
+}
+
+// unwindFrames records the frames of the VM in the stack of an error raised
+// in the innermost one, as the tree evaluator does while returning. Frames
+// of inlined calls are recorded as if the calls had not been inlined.
+func unwindFrames(err error, frames []vmFrame) error {
+	// The frames as the tree evaluator has them, innermost first: the func
+	// and the position of the error or call in it, and its declaration.
+	type frame struct {
+		fn        string
+		src, decl source.Source
+	}
+	var stack []frame
+	for i := len(frames) - 1; i >= 0; i-- {
+		fr := frames[i]
+		pc := fr.pc - 1
+		var src source.Source = &fr.fn.Lines[pc]
+		for inl := fr.fn.Inl[pc]; inl >= 0; inl = fr.fn.Inlined[inl].Parent {
+			call := &fr.fn.Inlined[inl]
+			stack = append(stack, frame{call.Func, src, &call.Src})
+			src = &call.Site
+		}
+		stack = append(stack, frame{fr.fn.Name, src, &fr.fn.Src})
+	}
+	for i, f := range stack {
+		if i > 0 {
+			err = unwind(err, f.fn, f.src)
+		} else if _, ok := err.(*RuntimeError); !ok {
+			err = unwind(err, f.fn, f.decl)
+		}
+	}
+	return err
+}
@@ -0,0 +1,501 @@
+package interp
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"bytecode"
+)
+
+func TestEngines(t *testing.T) {
+	testCases := []struct {
+		name   string
+		input  map[string]string
+		limits Limits
+		out    string // Output of either engine.
+		err    string // Error of either engine, followed by its stack trace.
+	}{
+		{
+			name: "operators",
+			input: map[string]string{
+				"main.apl": `
+func main() {
+  println(1 + 2 * 3, (1 + 2) * 3, 7 / 2, 7 % 2, -7 + 1, 1 == 1, 2 != 2);
+  println("a" + "b", "a" < "b", "b" >= "a", 1.5 * 2.0, 1.0 / 0.0, -0.5);
+  println(true && !false, false || false, true || false, false && true);
+}
+`,
+			},
+			out: "7 9 3 1 -6 true false\nab true true 3 +Inf -0.5\ntrue false true false\n",
+		},
+		{
+			name: "calls",
+			input: map[string]string{
+				"main.apl": `import lib;
+
+func fib(int n) int {
+  if n < 2 {
+    return n;
+  } else if n == 2 {
+    return 1;
+  }
+  return fib(n - 1) + fib(n - 2);
+}
+
+func hello(string who) {
+  println("hello", who);
+  return;
+}
+
+func main() {
+  hello("world");
+  println(fib(15), lib.Twice(fib(5)));
+}
+`,
+				"lib.apl": `func Twice(int x) int {
+  return x + x;
+}
+`,
+			},
+			out: "hello world\n610 10\n",
+		},
+		{
+			name: "division_by_zero",
+			input: map[string]string{
+				"main.apl": `import lib;
+
+func main() {
+  println(lib.Div(1, 0));
+}
+`,
+				"lib.apl": `func Div(int x, int y) int {
+  if y == 0 || x / y > 0 {
+    return x % y;
+  }
+  return 0;
+}
+`,
+			},
+			err: "lib.apl:3:14 division by zero\nmain.apl:4:11 in main\nlib.apl:3:14 in Div\n",
+		},
+		{
+			name: "missing_return",
+			input: map[string]string{
+				"main.apl": `
+func f(bool b) int {
+  if b {
+    return 1;
+  }
+}
+
+func main() {
+  println(f(true), f(false));
+}
+`,
+			},
+			err: "main.apl:6:1 missing return at end of f",
+		},
+		{
+			name: "host_error",
+			input: map[string]string{
+				"main.apl": `
+func main() {
+  println(len(split("a b", " ")));
+  fail();
+}
+`,
+			},
+			out: "2\n",
+			err: "main.apl:4:3 fail: failed\nmain.apl:4:3 in main\n",
+		},
+		{
+			name: "call_depth",
+			input: map[string]string{
+				"main.apl": `
+func count(int n) int {
+  if n == 0 {
+    return 0;
+  }
+  return 1 + count(n - 1);
+}
+
+func main() {
+  println(count(10));
+}
+`,
+			},
+			limits: Limits{CallDepth: 10},
+			err: "main.apl:6:14 call depth limit exceeded\nmain.apl:10:11 in main\n" +
+				strings.Repeat("main.apl:6:14 in count\n", 9),
+		},
+		{
+			name: "steps",
+			input: map[string]string{
+				"main.apl": `
+func count(int n) int {
+  if n == 0 {
+    return 0;
+  }
+  return 1 + count(n - 1);
+}
+
+func main() {
+  println(count(10));
+  println(count(100));
+}
+`,
+			},
+			limits: Limits{Steps: 100},
+			out:    "10\n",
+			err: "main.apl:3:3 step limit exceeded\nmain.apl:11:11 in main\n" +
+				strings.Repeat("main.apl:6:14 in count\n", 38) + "main.apl:3:3 in count\n",
+		},
+		{
+			name: "values",
+			input: map[string]string{
+				"main.apl": `
+func main() {
+  println(1 + 2, true && true, false || false);
+  println(len(split(repeat("a ", 100), " ")));
+}
+`,
+			},
+			limits: Limits{Values: 60},
+			out:    "3 true false\n",
+			err:    "main.apl:4:15 value limit exceeded\nmain.apl:4:15 in main\n",
+		},
+		{
+			name: "tail_calls",
+			input: map[string]string{
+				"main.apl": `import lib;
+
+func count(int n, int acc) int {
+  if n == 0 {
+    return acc;
+  }
+  return count(n - 1, acc + 1);
+}
+
+func even(int n) bool {
+  if n < 2 {
+    return n == 0;
+  }
+  return even(n - 2);
+}
+
+func odd(int n) bool {
+  if n == 0 {
+    return false;
+  }
+  return even(n - 1);
+}
+
+func main() {
+  println(count(5, 0), even(7), odd(7));
+  println(count(50, 0));
+  println(lib.Down(3));
+}
+`,
+				"lib.apl": `func Down(int n) int {
+  if n == 0 {
+    return 10 / n;
+  }
+  return Down(n - 1);
+}
+`,
+			},
+			limits: Limits{CallDepth: 20},
+			out:    "5 false true\n50\n",
+			err:    "lib.apl:3:15 division by zero\nmain.apl:27:11 in main\nlib.apl:3:15 in Down\n",
+		},
+		{
+			name: "inlining",
+			input: map[string]string{
+				"main.apl": `import lib;
+
+func twice(int x) int {
+  return x + x;
+}
+
+func quad(int x) int {
+  return twice(twice(x));
+}
+
+func safe(int x, int y) int {
+  return lib.Div(x, y) + 0;
+}
+
+func deep(int n) int {
+  if n == 0 {
+    return quad(1);
+  }
+  return quad(deep(n - 1));
+}
+
+func main() {
+  println(twice(2), quad(3), safe(7, 2));
+  println(deep(3));
+  println(deep(8));
+}
+`,
+				"lib.apl": `func twice(int x) int {
+  return x * 2;
+}
+
+func Div(int x, int y) int {
+  return x / y + twice(y);
+}
+`,
+			},
+			limits: Limits{CallDepth: 10},
+			out:    "4 12 7\n256\n",
+			err: "main.apl:8:16 call depth limit exceeded\nmain.apl:25:11 in main\n" +
+				strings.Repeat("main.apl:19:15 in deep\n", 8) + "main.apl:8:16 in quad\n",
+		},
+		{
+			name: "inlined_error",
+			input: map[string]string{
+				"main.apl": `import lib;
+
+func half(int x) int {
+  return lib.Div(x, 2) + lib.Div(x, 0);
+}
+
+func main() {
+  println(half(4));
+}
+`,
+				"lib.apl": `func Div(int x, int y) int {
+  return x / y;
+}
+`,
+			},
+			err: "lib.apl:2:12 division by zero\nmain.apl:8:11 in main\nmain.apl:4:26 in half\nlib.apl:2:12 in Div\n",
+		},
+	}
+	for _, tc := range testCases {
+		for _, optimize := range []bool{false, true} {
+			name := tc.name
+			if optimize {
+				name += "_optimized"
+			}
+			t.Run(name, func(t *testing.T) {
+				for _, eng := range []Engine{Tree, VM} {
+					e := NewExecutor(NewStringLoader(tc.input))
+					e.SetEngine(eng)
+					e.SetOptimize(optimize)
+					e.SetLimits(tc.limits)
+					var out strings.Builder
+					e.SetOutput(&out)
+					register(t, e, "fail", func() error { return errors.New("failed") })
+					register(t, e, "len", func(l []string) int { return len(l) })
+					register(t, e, "split", strings.Split)
+					register(t, e, "repeat", strings.Repeat)
+					err := e.Run(context.Background(), "main.apl")
+					var msg string
+					if err != nil {
+						msg = err.Error()
+						var re *RuntimeError
+						if errors.As(err, &re) {
+							msg += "\n" + re.StackTrace()
+						}
+					}
+					if out.String() != tc.out {
+						t.Errorf("engine %v: expected output\n%s\ngot\n%s", eng, tc.out, out.String())
+					}
+					if msg != tc.err {
+						t.Errorf("engine %v: expected error\n%s\ngot\n%s", eng, tc.err, msg)
+					}
+				}
+			})
+		}
+	}
+}
+
+func register(t *testing.T, e *Executor, name string, fn interface{}) {
+	t.Helper()
+	if err := e.RegisterFunc(name, fn); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCompile(t *testing.T) {
+	e := NewExecutor(NewStringLoader(map[string]string{
+		"test": `
+func abs(int x) int {
+  if x < 0 && true {
+    return -x;
+  }
+  println(x);
+  return x;
+}
+`,
+	}))
+	p, err := e.Compile("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	if err := bytecode.Disassemble(&b, p); err != nil {
+		t.Fatal(err)
+	}
+	want := `func test.abs (1 args, returns) test:2:1
+  locals: x
+  consts: 0=0, 1=true
+  0000  3:3     step
+  0001  3:6     load 0                ; x
+  0002  3:10    const 0               ; 0
+  0003  3:8     less
+  0004  3:12    jumpfalseorpop 7
+  0005  3:15    const 1               ; true
+  0006  3:12    alloc
+  0007  3:3     jumpfalse 12
+  0008  4:5     step
+  0009  4:13    load 0                ; x
+  0010  4:12    neg
+  0011  4:5     return
+  0012  6:3     step
+  0013  6:11    load 0                ; x
+  0014  6:3     callbuiltin 0         ; println/1
+  0015  6:3     pop
+  0016  7:3     step
+  0017  7:10    load 0                ; x
+  0018  7:3     return
+  0019  8:1     missingreturn
+`
+	if got := b.String(); got != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	src := map[string]string{"main.apl": `
+func sum(int n, int acc) int {
+  if n == 0 {
+    return acc;
+  }
+  return sum(n - 1, acc + n);
+}
+`}
+	for _, eng := range []Engine{Tree, VM} {
+		for _, optimize := range []bool{false, true} {
+			e := NewExecutor(NewStringLoader(src))
+			e.SetEngine(eng)
+			e.SetOptimize(optimize)
+			e.SetLimits(Limits{CallDepth: 100})
+			v, err := e.Call(context.Background(), "main.apl", "sum", 100000, 0)
+			if err != nil {
+				t.Errorf("engine %v, optimize %v: %v", eng, optimize, err)
+			} else if v != 5000050000 {
+				t.Errorf("engine %v, optimize %v: expected 5000050000, got %v", eng, optimize, v)
+			}
+		}
+	}
+}
+
+func TestCompileOptimized(t *testing.T) {
+	e := NewExecutor(NewStringLoader(map[string]string{
+		"test": `
+func sq(int x) int {
+  return x * x;
+}
+
+func sum(int n, int acc) int {
+  if n == 0 {
+    return acc;
+  }
+  return sum(n - 1, acc + sq(n));
+}
+`,
+	}))
+	e.SetOptimize(true)
+	p, err := e.Compile("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	if err := bytecode.Disassemble(&b, p); err != nil {
+		t.Fatal(err)
+	}
+	want := `func test.sq (1 args, returns) test:2:1
+  locals: x
+  0000  3:3     step
+  0001  3:10    load 0                ; x
+  0002  3:14    load 0                ; x
+  0003  3:12    mul
+  0004  3:3     return
+  0005  4:1     missingreturn
+
+func test.sum (2 args, returns) test:6:1
+  locals: n, acc, sq.x
+  consts: 0=0, 1=1
+  inlined: 0=test.sq at 10:27
+  0000  7:3     step
+  0001  7:6     load 0                ; n
+  0002  7:11    const 0               ; 0
+  0003  7:8     eq
+  0004  7:3     jumpfalse 8
+  0005  8:5     step
+  0006  8:12    load 1                ; acc
+  0007  8:5     return
+  0008  10:3    step
+  0009  10:14   load 0                ; n
+  0010  10:18   const 1               ; 1
+  0011  10:16   sub
+  0012  10:21   load 1                ; acc
+  0013  10:30   load 0                ; n
+  0014  10:27   enter 0               ; test.sq
+  0015  10:27   store 2               ; sq.x
+  0016  3:3@0   step
+  0017  3:10@0  load 2                ; sq.x
+  0018  3:14@0  load 2                ; sq.x
+  0019  3:12@0  mul
+  0020  10:25   add
+  0021  10:10   tailcall 0            ; test.sum/2
+  0022  11:1    missingreturn
+`
+	if got := b.String(); got != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, got)
+	}
+}
+
+const benchSrc = `
+func fib(int n) int {
+  if n < 2 {
+    return n;
+  }
+  return fib(n - 1) + fib(n - 2);
+}
+
+func sum(int n, int acc) int {
+  if n == 0 {
+    return acc;
+  }
+  return sum(n - 1, acc + n % 7 * 2 - 1);
+}
+`
+
+func benchmarkEngine(b *testing.B, eng Engine, fn string, arg int) {
+	e := NewExecutor(NewStringLoader(map[string]string{"bench": benchSrc}))
+	e.SetEngine(eng)
+	args := []interface{}{arg}
+	if fn == "sum" {
+		args = append(args, 0)
+	}
+	if _, err := e.Call(context.Background(), "bench", fn, args...); err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := e.Call(context.Background(), "bench", fn, args...); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFibTree(b *testing.B) { benchmarkEngine(b, Tree, "fib", 20) }
+func BenchmarkFibVM(b *testing.B)   { benchmarkEngine(b, VM, "fib", 20) }
+func BenchmarkSumTree(b *testing.B) { benchmarkEngine(b, Tree, "sum", 5000) }
+func BenchmarkSumVM(b *testing.B)   { benchmarkEngine(b, VM, "sum", 5000) }
@@ -0,0 +1,130 @@
+package interp
+
+import (
+	"crypto/sha256"
+	"errors"
+	"io/fs"
+	"sort"
+)
+
+// Update drops the cached files at the given paths, so that the next Check
+// reloads them, and discards the check results of the packages made of them,
+// of all packages importing those, transitively, and of all packages that
+// failed to check. Unlike Invalidate, the parsed files and declarations of
+// all other packages are kept, so the next Check only re-checks the
+// discarded packages, parsing nothing but the given files. Import paths of
+// the discarded packages are resolved again.
+func (e *Executor) Update(paths ...string) {
+	pkgOf := make(map[string][]string)
+	e.mu.Lock()
+	for _, path := range paths {
+		delete(e.sources, path)
+		delete(e.files, path)
+	}
+	for pkg, files := range e.pkgs {
+		for _, file := range files {
+			pkgOf[file] = append(pkgOf[file], pkg)
+		}
+	}
+	e.mu.Unlock()
+	importers := make(map[string][]string)
+	for pkg, imports := range e.imports {
+		for _, imp := range imports {
+			importers[imp] = append(importers[imp], pkg)
+		}
+	}
+	var drop func(pkg string)
+	drop = func(pkg string) {
+		if !e.checked[pkg] {
+			return
+		}
+		e.uncheck(pkg)
+		for _, importer := range importers[pkg] {
+			drop(importer)
+		}
+	}
+	for _, path := range paths {
+		for _, pkg := range pkgOf[path] {
+			drop(pkg)
+		}
+	}
+	for pkg := range e.failed {
+		drop(pkg)
+	}
+}
+
+// uncheck discards the check results of a checked import path.
+func (e *Executor) uncheck(pkg string) {
+	e.mu.Lock()
+	for _, file := range e.pkgs[pkg] {
+		delete(e.scopes, file)
+	}
+	delete(e.pkgs, pkg)
+	delete(e.keys, pkg)
+	e.mu.Unlock()
+	e.tc.Package(pkg).Remove()
+	delete(e.checked, pkg)
+	delete(e.failed, pkg)
+	delete(e.imports, pkg)
+	delete(e.funcs, pkg)
+	decls := e.decls[:0]
+	for _, fn := range e.decls {
+		if fn.module != pkg {
+			decls = append(decls, fn)
+		}
+	}
+	e.decls = decls
+	e.prog = nil
+}
+
+// Watcher re-checks a package as the files loaded for it change. It polls
+// the Loader for the content of the files rather than relying on file system
+// notifications, so it works with any Loader.
+type Watcher struct {
+	e      *Executor
+	path   string
+	hashes map[string][sha256.Size]byte // Hashes of the checked sources by file path.
+	err    error                        // Result of the last check.
+}
+
+// NewWatcher returns a Watcher of the package at an import path, checked by
+// e.
+func NewWatcher(e *Executor, path string) *Watcher {
+	return &Watcher{e: e, path: path}
+}
+
+// Check checks the package and records the content of all loaded files.
+func (w *Watcher) Check() error {
+	w.err = w.e.Check(w.path)
+	w.hashes = make(map[string][sha256.Size]byte)
+	w.e.mu.Lock()
+	for path, src := range w.e.sources {
+		w.hashes[path] = sha256.Sum256(src)
+	}
+	w.e.mu.Unlock()
+	return w.err
+}
+
+// Poll loads the files loaded by the last check again and, if any of them
+// changed, updates the Executor with them, see Update, and checks the package
+// again. A package that failed to load a file is checked again even if
+// nothing changed, as the file may have been created since. Poll returns the
+// sorted paths of the changed files and the result of the last check.
+func (w *Watcher) Poll() ([]string, error) {
+	if w.hashes == nil {
+		return nil, w.Check()
+	}
+	var changed []string
+	for path, hash := range w.hashes {
+		src, err := w.e.read(path)
+		if err != nil || sha256.Sum256(src) != hash {
+			changed = append(changed, path)
+		}
+	}
+	sort.Strings(changed)
+	if len(changed) > 0 || errors.Is(w.err, fs.ErrNotExist) {
+		w.e.Update(changed...)
+		w.Check()
+	}
+	return changed, w.err
+}
@@ -0,0 +1,117 @@
+package interp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWatcher(t *testing.T) {
+	src := map[string]string{
+		"main.apl": `import lib;
+import util;
+
+func main() {
+  lib.Lib(util.Util());
+}
+`,
+		"lib/a.apl": `import base;
+
+func Lib(int x) {
+  base.Base(x);
+}
+`,
+		"lib/b.apl": `func helper() {}`,
+		"util.apl":  `func Util() int { return 1; }`,
+		"base.apl":  `func Base(int x) {}`,
+	}
+	e := NewExecutor(NewStringLoader(src))
+	w := NewWatcher(e, "main.apl")
+	if err := w.Check(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	testCases := []struct {
+		name     string
+		change   func()
+		changed  string
+		err      string
+		parsed   int
+		rechecks string // Files checked again.
+	}{
+		{
+			name: "unchanged",
+		},
+		{
+			name: "changed_leaf",
+			change: func() {
+				src["base.apl"] = `func Base(bool b) {}`
+			},
+			changed:  "base.apl",
+			err:      "lib/a.apl:4:3 base.Base param #1 expects type<bool>, not type<int>",
+			parsed:   1,
+			rechecks: "base.apl,lib/a.apl",
+		},
+		{
+			name: "fixed_importer",
+			change: func() {
+				src["lib/a.apl"] = `import base;
+
+func Lib(int x) {
+  base.Base(x == 1);
+}
+`
+			},
+			changed:  "lib/a.apl",
+			parsed:   1,
+			rechecks: "lib/a.apl,lib/b.apl,main.apl",
+		},
+		{
+			name: "deleted",
+			change: func() {
+				delete(src, "util.apl")
+			},
+			changed: "util.apl",
+			err:     "unknown import: util",
+		},
+		{
+			name: "created",
+			change: func() {
+				src["util.apl"] = `func Util() int { return 2; }`
+			},
+			parsed:   1,
+			rechecks: "main.apl,util.apl",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			scopes := make(map[string]interface{})
+			for _, path := range e.Paths() {
+				scopes[path] = e.Scope(path)
+			}
+			parsed := e.parsed
+			if tc.change != nil {
+				tc.change()
+			}
+			changed, err := w.Poll()
+			if got := strings.Join(changed, ","); got != tc.changed {
+				t.Errorf("expected changed %q but got %q", tc.changed, got)
+			}
+			if tc.err == "" && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			} else if tc.err != "" && (err == nil || err.Error() != tc.err) {
+				t.Errorf("expected %q but got %v", tc.err, err)
+			}
+			if got := e.parsed - parsed; got != tc.parsed {
+				t.Errorf("expected %d files parsed but got %d", tc.parsed, got)
+			}
+			var rechecks []string
+			for _, path := range e.Paths() {
+				if scope := e.Scope(path); scope != nil && scope != scopes[path] {
+					rechecks = append(rechecks, path)
+				}
+			}
+			if got := strings.Join(rechecks, ","); got != tc.rechecks {
+				t.Errorf("expected rechecks %q but got %q", tc.rechecks, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package lsp
+
+// The subset of the Language Server Protocol used by the server. Field names
+// follow the specification.
+
+// Position is a zero-based line and character offset in a document.
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// Range is a span between two positions in a document.
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// Location is a range inside a document.
+type Location struct {
+	URI   string `json:"uri"`
+	Range Range  `json:"range"`
+}
+
+// Diagnostic severities.
+const (
+	SeverityError = 1
+)
+
+// Diagnostic is a problem reported for a document.
+type Diagnostic struct {
+	Range    Range  `json:"range"`
+	Severity int    `json:"severity"`
+	Source   string `json:"source"`
+	Message  string `json:"message"`
+}
+
+// PublishDiagnosticsParams is sent with textDocument/publishDiagnostics.
+type PublishDiagnosticsParams struct {
+	URI         string       `json:"uri"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// TextDocumentItem is an opened document.
+type TextDocumentItem struct {
+	URI     string `json:"uri"`
+	Version int    `json:"version"`
+	Text    string `json:"text"`
+}
+
+// TextDocumentIdentifier names a document.
+type TextDocumentIdentifier struct {
+	URI string `json:"uri"`
+}
+
+// DidOpenTextDocumentParams is sent with textDocument/didOpen.
+type DidOpenTextDocumentParams struct {
+	TextDocument TextDocumentItem `json:"textDocument"`
+}
+
+// TextDocumentContentChangeEvent is a change to a document. Only full
+// document sync is supported, so Text is the whole new content.
+type TextDocumentContentChangeEvent struct {
+	Text string `json:"text"`
+}
+
+// DidChangeTextDocumentParams is sent with textDocument/didChange.
+type DidChangeTextDocumentParams struct {
+	TextDocument   TextDocumentIdentifier           `json:"textDocument"`
+	ContentChanges []TextDocumentContentChangeEvent `json:"contentChanges"`
+}
+
+// DidCloseTextDocumentParams is sent with textDocument/didClose.
+type DidCloseTextDocumentParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+// TextDocumentPositionParams identifies a position in a document. It is the
+// params of definition, hover and completion requests.
+type TextDocumentPositionParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Position     Position               `json:"position"`
+}
+
+// DocumentSymbolParams is sent with textDocument/documentSymbol.
+type DocumentSymbolParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+// MarkupContent is formatted text shown to the user.
+type MarkupContent struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+// Hover is the result of textDocument/hover.
+type Hover struct {
+	Contents MarkupContent `json:"contents"`
+	Range    *Range        `json:"range,omitempty"`
+}
+
+// Completion item kinds.
+const (
+	CompletionKindFunction = 3
+	CompletionKindClass    = 7
+)
+
+// CompletionItem is a single completion proposal.
+type CompletionItem struct {
+	Label  string `json:"label"`
+	Kind   int    `json:"kind"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Symbol kinds.
+const (
+	SymbolKindFunction = 12
+)
+
+// DocumentSymbol is a declaration in a document.
+type DocumentSymbol struct {
+	Name           string `json:"name"`
+	Detail         string `json:"detail,omitempty"`
+	Kind           int    `json:"kind"`
+	Range          Range  `json:"range"`
+	SelectionRange Range  `json:"selectionRange"`
+}
+
+// InitializeResult is the result of the initialize request.
+type InitializeResult struct {
+	Capabilities ServerCapabilities `json:"capabilities"`
+}
+
+// Text document sync kinds.
+const (
+	SyncFull = 1
+)
+
+// ServerCapabilities advertises the features of the server.
+type ServerCapabilities struct {
+	TextDocumentSync       int      `json:"textDocumentSync"`
+	DefinitionProvider     bool     `json:"definitionProvider"`
+	HoverProvider          bool     `json:"hoverProvider"`
+	CompletionProvider     struct{} `json:"completionProvider"`
+	DocumentSymbolProvider bool     `json:"documentSymbolProvider"`
+}
@@ -0,0 +1,460 @@
+// Package lsp implements a Language Server Protocol server for apl. It speaks
+// JSON-RPC 2.0 over a pair of streams, usually stdin and stdout of the
+// "apl lsp" command.
+package lsp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"sort"
+
+	"ast"
+	"ast/expr"
+	"ast/source"
+	"ast/statement"
+	"interp"
+	"parser"
+	"types"
+)
+
+// Server is a language server for a single client. Documents opened by the
+// client are checked on open and on every change, and diagnostics are
+// published for them. Imports not open in the client are read from disk,
+// relative to the directory of the importing document.
+type Server struct {
+	conn     *conn
+	docs     map[string]*document             // By URI.
+	overlays map[string]*interp.OverlayLoader // By directory.
+}
+
+// document is a file opened by the client.
+type document struct {
+	uri  string
+	path string // Absolute file path.
+	text string
+	exec *interp.Executor
+}
+
+// dir returns the directory imports are resolved against.
+func (d *document) dir() string {
+	return filepath.Dir(d.path)
+}
+
+// name returns the import path of the document within dir.
+func (d *document) name() string {
+	return filepath.Base(d.path)
+}
+
+// scope returns the context names in the document are resolved in. If the
+// document could not be checked, only builtins are available.
+func (d *document) scope() *types.Context {
+	if scope := d.exec.Scope(d.name()); scope != nil {
+		return scope
+	}
+	return d.exec.Types()
+}
+
+// NewServer returns a new Server reading requests from in and writing
+// responses and notifications to out.
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		conn:     newConn(in, out),
+		docs:     make(map[string]*document),
+		overlays: make(map[string]*interp.OverlayLoader),
+	}
+}
+
+// Run serves requests until the client sends exit or in is closed.
+func (s *Server) Run() error {
+	for {
+		body, err := s.conn.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var msg message
+		if err := json.Unmarshal(body, &msg); err != nil {
+			err := s.conn.write(&message{
+				ID:    new(json.RawMessage),
+				Error: &rpcError{Code: codeParseError, Message: err.Error()},
+			})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if msg.Method == "exit" {
+			return nil
+		}
+		result, err := s.handle(msg.Method, msg.Params)
+		if msg.ID == nil {
+			// Notifications have no response, not even on error.
+			continue
+		}
+		resp := &message{ID: msg.ID}
+		if err != nil {
+			rerr, ok := err.(*rpcError)
+			if !ok {
+				rerr = &rpcError{Code: codeInternalError, Message: err.Error()}
+			}
+			resp.Error = rerr
+		} else if resp.Result, err = json.Marshal(result); err != nil {
+			return err
+		}
+		if err := s.conn.write(resp); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) notify(method string, params interface{}) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return s.conn.write(&message{Method: method, Params: data})
+}
+
+// handle dispatches a request or notification and returns its result.
+func (s *Server) handle(method string, params json.RawMessage) (interface{}, error) {
+	switch method {
+	case "initialize":
+		var res InitializeResult
+		res.Capabilities.TextDocumentSync = SyncFull
+		res.Capabilities.DefinitionProvider = true
+		res.Capabilities.HoverProvider = true
+		res.Capabilities.DocumentSymbolProvider = true
+		return res, nil
+	case "initialized", "shutdown":
+		return nil, nil
+	case "textDocument/didOpen":
+		var p DidOpenTextDocumentParams
+		if err := unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		path, err := uriToPath(p.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		doc := &document{uri: p.TextDocument.URI, path: path, text: p.TextDocument.Text}
+		doc.exec = interp.NewExecutor(s.overlay(doc.dir()))
+		s.docs[doc.uri] = doc
+		s.overlay(doc.dir()).Set(doc.name(), doc.text)
+		return nil, s.recheck(doc)
+	case "textDocument/didChange":
+		var p DidChangeTextDocumentParams
+		if err := unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		doc, err := s.doc(p.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		for _, change := range p.ContentChanges {
+			doc.text = change.Text
+		}
+		s.overlay(doc.dir()).Set(doc.name(), doc.text)
+		return nil, s.recheck(doc)
+	case "textDocument/didClose":
+		var p DidCloseTextDocumentParams
+		if err := unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		doc, err := s.doc(p.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		delete(s.docs, doc.uri)
+		s.overlay(doc.dir()).Delete(doc.name())
+		err = s.notify("textDocument/publishDiagnostics", &PublishDiagnosticsParams{
+			URI:         doc.uri,
+			Diagnostics: []Diagnostic{},
+		})
+		if err != nil {
+			return nil, err
+		}
+		return nil, s.recheck(doc)
+	case "textDocument/definition":
+		var p TextDocumentPositionParams
+		if err := unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		return s.definition(p)
+	case "textDocument/hover":
+		var p TextDocumentPositionParams
+		if err := unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		return s.hover(p)
+	case "textDocument/completion":
+		var p TextDocumentPositionParams
+		if err := unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		return s.completion(p)
+	case "textDocument/documentSymbol":
+		var p DocumentSymbolParams
+		if err := unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		return s.documentSymbol(p)
+	default:
+		return nil, &rpcError{Code: codeMethodNotFound, Message: "method not found: " + method}
+	}
+}
+
+func unmarshal(params json.RawMessage, v interface{}) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &rpcError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+func (s *Server) doc(uri string) (*document, error) {
+	doc, ok := s.docs[uri]
+	if !ok {
+		return nil, &rpcError{Code: codeInvalidParams, Message: "document not open: " + uri}
+	}
+	return doc, nil
+}
+
+// overlay returns the loader for documents in dir. Open documents are served
+// from memory, everything else from disk.
+func (s *Server) overlay(dir string) *interp.OverlayLoader {
+	o, ok := s.overlays[dir]
+	if !ok {
+		o = interp.NewOverlayLoader(&interp.FileLoader{SearchPaths: []string{dir}})
+		s.overlays[dir] = o
+	}
+	return o
+}
+
+// recheck updates changed in every open document that may import it and
+// checks those documents again, in URI order. Only packages depending on
+// changed are checked again.
+func (s *Server) recheck(changed *document) error {
+	var uris []string
+	for uri, doc := range s.docs {
+		if doc.dir() == changed.dir() {
+			uris = append(uris, uri)
+		}
+	}
+	sort.Strings(uris)
+	for _, uri := range uris {
+		doc := s.docs[uri]
+		doc.exec.Update(changed.name())
+		if err := s.check(doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// check checks doc and publishes its diagnostics.
+func (s *Server) check(doc *document) error {
+	diags := []Diagnostic{}
+	if err := doc.exec.Check(doc.name()); err != nil {
+		diags = append(diags, diagnostic(doc, err))
+	}
+	return s.notify("textDocument/publishDiagnostics", &PublishDiagnosticsParams{
+		URI:         doc.uri,
+		Diagnostics: diags,
+	})
+}
+
+// diagnostic converts a check error to a Diagnostic. Errors without a
+// position, or positioned in another file, are reported at the start of doc.
+func diagnostic(doc *document, err error) Diagnostic {
+	d := Diagnostic{Severity: SeverityError, Source: "apl", Message: err.Error()}
+	var serr *source.Error
+	var perr *parser.Error
+	switch {
+	case errors.As(err, &serr) && serr.Src.File() == doc.name():
+		d.Range = pointRange(serr.Src.Line(), serr.Src.LinePos(), 1)
+		d.Message = serr.Msg
+	case errors.As(err, &perr) && perr.Tok.File == doc.name():
+		d.Range = pointRange(perr.Tok.Line, perr.Tok.LinePos, len(perr.Tok.Lit))
+	}
+	return d
+}
+
+func pointRange(line, char, length int) Range {
+	return Range{
+		Start: Position{Line: line, Character: char},
+		End:   Position{Line: line, Character: char + length},
+	}
+}
+
+// covers reports whether pos lies on the name of length n starting at s.
+func covers(s source.Source, n int, pos Position) bool {
+	return s.Line() == pos.Line && s.LinePos() <= pos.Character && pos.Character <= s.LinePos()+n
+}
+
+// nameAt returns the name of the function called or declared at pos in doc,
+// or "" if there is none.
+func nameAt(doc *document, pos Position) string {
+	file := doc.exec.File(doc.name())
+	if file == nil {
+		return ""
+	}
+	var name string
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *statement.FnCall:
+			if covers(n, len(n.Nam), pos) {
+				name = n.Nam
+			}
+		case *expr.Call:
+			if covers(n, len(n.Nam), pos) {
+				name = n.Nam
+			}
+		case *ast.FnDecl:
+			if covers(n, len("func ")+len(n.Nam), pos) {
+				name = n.Nam
+			}
+		}
+		return name == ""
+	})
+	return name
+}
+
+// declRange returns the range of the "func name" part of a declaration.
+func declRange(d *ast.FnDecl) Range {
+	return pointRange(d.Line(), d.LinePos(), len("func ")+len(d.Nam))
+}
+
+func (s *Server) definition(p TextDocumentPositionParams) (interface{}, error) {
+	doc, err := s.doc(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	name := nameAt(doc, p.Position)
+	if name == "" {
+		return nil, nil
+	}
+	pkg, local, err := doc.scope().Resolve(name)
+	if err != nil || pkg == "" {
+		return nil, nil
+	}
+	paths, err := doc.exec.Resolve(pkg)
+	if err != nil {
+		return nil, nil
+	}
+	for _, path := range paths {
+		file := doc.exec.File(path)
+		if file == nil {
+			continue
+		}
+		for _, decl := range file.Decls {
+			if fn, ok := decl.(*ast.FnDecl); ok && fn.Nam == local {
+				return &Location{
+					URI:   pathToURI(filepath.Join(doc.dir(), path)),
+					Range: declRange(fn),
+				}, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+func (s *Server) hover(p TextDocumentPositionParams) (interface{}, error) {
+	doc, err := s.doc(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	name := nameAt(doc, p.Position)
+	if name == "" {
+		return nil, nil
+	}
+	typ, err := doc.scope().Get(name)
+	if err != nil {
+		return nil, nil
+	}
+	fn, ok := typ.(*types.Func)
+	if !ok {
+		return nil, nil
+	}
+	return &Hover{
+		Contents: MarkupContent{
+			Kind:  "markdown",
+			Value: fmt.Sprintf("```apl\n%s\n```", fn.Signature(name)),
+		},
+	}, nil
+}
+
+func (s *Server) completion(p TextDocumentPositionParams) (interface{}, error) {
+	doc, err := s.doc(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	items := []CompletionItem{}
+	tc := doc.scope()
+	for _, name := range tc.Names() {
+		typ, err := tc.Get(name)
+		if err != nil {
+			return nil, err
+		}
+		item := CompletionItem{Label: name, Kind: CompletionKindClass}
+		if fn, ok := typ.(*types.Func); ok {
+			item.Kind = CompletionKindFunction
+			item.Detail = fn.Signature(name)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (s *Server) documentSymbol(p DocumentSymbolParams) (interface{}, error) {
+	doc, err := s.doc(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	symbols := []DocumentSymbol{}
+	file := doc.exec.File(doc.name())
+	if file == nil {
+		return symbols, nil
+	}
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FnDecl)
+		if !ok {
+			continue
+		}
+		sym := DocumentSymbol{
+			Name:           fn.Nam,
+			Kind:           SymbolKindFunction,
+			Range:          declRange(fn),
+			SelectionRange: declRange(fn),
+		}
+		if fn.End != nil {
+			sym.Range.End = Position{Line: fn.End.Line(), Character: fn.End.LinePos() + 1}
+		}
+		if typ, err := doc.scope().Get(fn.Nam); err == nil {
+			if ft, ok := typ.(*types.Func); ok {
+				sym.Detail = ft.Signature(fn.Nam)
+			}
+		}
+		symbols = append(symbols, sym)
+	}
+	return symbols, nil
+}
+
+func uriToPath(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", &rpcError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	if u.Scheme != "file" {
+		return "", &rpcError{Code: codeInvalidParams, Message: "unsupported uri: " + uri}
+	}
+	return filepath.FromSlash(u.Path), nil
+}
+
+func pathToURI(path string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
@@ -0,0 +1,267 @@
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// client is an in-process LSP client connected to a Server.
+type client struct {
+	t      *testing.T
+	conn   *conn
+	msgs   chan *message
+	nextID int
+	done   chan error
+}
+
+func newClient(t *testing.T) *client {
+	clientIn, serverOut := io.Pipe()
+	serverIn, clientOut := io.Pipe()
+	c := &client{
+		t:    t,
+		conn: newConn(clientIn, clientOut),
+		msgs: make(chan *message, 100),
+		done: make(chan error, 1),
+	}
+	go func() {
+		c.done <- NewServer(serverIn, serverOut).Run()
+		serverOut.Close()
+	}()
+	go func() {
+		defer close(c.msgs)
+		for {
+			body, err := c.conn.read()
+			if err != nil {
+				return
+			}
+			var msg message
+			if err := json.Unmarshal(body, &msg); err != nil {
+				t.Error(err)
+				return
+			}
+			c.msgs <- &msg
+		}
+	}()
+	return c
+}
+
+func (c *client) send(msg *message, params interface{}) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	msg.Params = data
+	if err := c.conn.write(msg); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *client) notify(method string, params interface{}) {
+	c.send(&message{Method: method}, params)
+}
+
+// call sends a request and decodes the response into result.
+func (c *client) call(method string, params, result interface{}) {
+	c.nextID++
+	id := json.RawMessage(fmt.Sprint(c.nextID))
+	c.send(&message{ID: &id, Method: method}, params)
+	for msg := range c.msgs {
+		if msg.ID == nil {
+			continue
+		}
+		if msg.Error != nil {
+			c.t.Fatalf("%s: %s", method, msg.Error)
+		}
+		if err := json.Unmarshal(msg.Result, result); err != nil {
+			c.t.Fatal(err)
+		}
+		return
+	}
+	c.t.Fatalf("%s: connection closed", method)
+}
+
+// diagnostics waits for the next published diagnostics.
+func (c *client) diagnostics() PublishDiagnosticsParams {
+	for msg := range c.msgs {
+		if msg.Method != "textDocument/publishDiagnostics" {
+			continue
+		}
+		var p PublishDiagnosticsParams
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			c.t.Fatal(err)
+		}
+		return p
+	}
+	c.t.Fatal("connection closed")
+	return PublishDiagnosticsParams{}
+}
+
+func (c *client) close() {
+	c.notify("exit", nil)
+	if err := <-c.done; err != nil {
+		c.t.Error(err)
+	}
+}
+
+const mainSrc = `import lib;
+
+func main(int x) {
+  lib.lib(true);
+  main(1);
+}
+`
+
+func TestServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lsp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	libPath := filepath.Join(dir, "lib.apl")
+	if err := ioutil.WriteFile(libPath, []byte("export func lib(bool b) {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	uri := pathToURI(filepath.Join(dir, "main.apl"))
+	doc := TextDocumentIdentifier{URI: uri}
+
+	c := newClient(t)
+	defer c.close()
+
+	var init InitializeResult
+	c.call("initialize", struct{}{}, &init)
+	if !init.Capabilities.DefinitionProvider || init.Capabilities.TextDocumentSync != SyncFull {
+		t.Errorf("unexpected capabilities %+v", init.Capabilities)
+	}
+	c.notify("initialized", struct{}{})
+
+	c.notify("textDocument/didOpen", &DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: uri, Version: 1, Text: mainSrc},
+	})
+	if diags := c.diagnostics(); diags.URI != uri || len(diags.Diagnostics) != 0 {
+		t.Errorf("expected no diagnostics for %s, got %+v", uri, diags)
+	}
+
+	t.Run("definition", func(t *testing.T) {
+		var loc Location
+		c.call("textDocument/definition", &TextDocumentPositionParams{
+			TextDocument: doc,
+			Position:     Position{Line: 3, Character: 3},
+		}, &loc)
+		expected := Location{URI: pathToURI(libPath), Range: pointRange(0, 7, len("func lib"))}
+		if loc != expected {
+			t.Errorf("expected %+v, got %+v", expected, loc)
+		}
+	})
+
+	t.Run("hover", func(t *testing.T) {
+		var hover Hover
+		c.call("textDocument/hover", &TextDocumentPositionParams{
+			TextDocument: doc,
+			Position:     Position{Line: 4, Character: 2},
+		}, &hover)
+		if expected := "```apl\nfunc main(int)\n```"; hover.Contents.Value != expected {
+			t.Errorf("expected %q, got %q", expected, hover.Contents.Value)
+		}
+	})
+
+	t.Run("completion", func(t *testing.T) {
+		var items []CompletionItem
+		c.call("textDocument/completion", &TextDocumentPositionParams{
+			TextDocument: doc,
+			Position:     Position{Line: 4, Character: 2},
+		}, &items)
+		expected := []CompletionItem{
+			{Label: "assert", Kind: CompletionKindFunction, Detail: "func assert(bool, string)"},
+			{Label: "bool", Kind: CompletionKindClass},
+			{Label: "float", Kind: CompletionKindClass},
+			{Label: "int", Kind: CompletionKindClass},
+			{Label: "lib.lib", Kind: CompletionKindFunction, Detail: "func lib.lib(bool)"},
+			{Label: "main", Kind: CompletionKindFunction, Detail: "func main(int)"},
+			{Label: "print", Kind: CompletionKindFunction, Detail: "func print(any...)"},
+			{Label: "println", Kind: CompletionKindFunction, Detail: "func println(any...)"},
+			{Label: "string", Kind: CompletionKindClass},
+		}
+		if !reflect.DeepEqual(items, expected) {
+			t.Errorf("expected %+v, got %+v", expected, items)
+		}
+	})
+
+	t.Run("document_symbol", func(t *testing.T) {
+		var symbols []DocumentSymbol
+		c.call("textDocument/documentSymbol", &DocumentSymbolParams{TextDocument: doc}, &symbols)
+		expected := []DocumentSymbol{{
+			Name:           "main",
+			Detail:         "func main(int)",
+			Kind:           SymbolKindFunction,
+			Range:          Range{Start: Position{2, 0}, End: Position{5, 1}},
+			SelectionRange: pointRange(2, 0, len("func main")),
+		}}
+		if !reflect.DeepEqual(symbols, expected) {
+			t.Errorf("expected %+v, got %+v", expected, symbols)
+		}
+	})
+
+	t.Run("diagnostics", func(t *testing.T) {
+		c.notify("textDocument/didChange", &DidChangeTextDocumentParams{
+			TextDocument: doc,
+			ContentChanges: []TextDocumentContentChangeEvent{
+				{Text: "import lib;\n\nfunc main(int x) {\n  lib.lib(1);\n}\n"},
+			},
+		})
+		diags := c.diagnostics()
+		expected := []Diagnostic{{
+			Range:    pointRange(3, 2, 1),
+			Severity: SeverityError,
+			Source:   "apl",
+			Message:  "lib.lib param #1 expects type<bool>, not type<int>",
+		}}
+		if !reflect.DeepEqual(diags.Diagnostics, expected) {
+			t.Errorf("expected %+v, got %+v", expected, diags.Diagnostics)
+		}
+	})
+
+	t.Run("parse_error", func(t *testing.T) {
+		c.notify("textDocument/didChange", &DidChangeTextDocumentParams{
+			TextDocument:   doc,
+			ContentChanges: []TextDocumentContentChangeEvent{{Text: "import ;"}},
+		})
+		diags := c.diagnostics()
+		expected := []Diagnostic{{
+			Range:    pointRange(0, 7, 1),
+			Severity: SeverityError,
+			Source:   "apl",
+			Message:  "error at pos 7 (;): expected TokenText, got TokenSemicolon",
+		}}
+		if !reflect.DeepEqual(diags.Diagnostics, expected) {
+			t.Errorf("expected %+v, got %+v", expected, diags.Diagnostics)
+		}
+	})
+
+	t.Run("unsupported_syntax", func(t *testing.T) {
+		testCases := []struct {
+			text string
+			msg  string
+		}{
+			{"func main() {\n  x = 1;\n}\n", "error at pos 18 (=): assignments are not supported"},
+			{"func main() {\n  x;\n}\n", "error at pos 17 (;): expected call of x"},
+			{"func main() {\n  lib.x;\n}\n", "error at pos 21 (;): expected call of lib.x"},
+			{"type t;\n", "error at pos 0 (type): type declarations are not supported"},
+		}
+		for _, tc := range testCases {
+			c.notify("textDocument/didChange", &DidChangeTextDocumentParams{
+				TextDocument:   doc,
+				ContentChanges: []TextDocumentContentChangeEvent{{Text: tc.text}},
+			})
+			diags := c.diagnostics()
+			if len(diags.Diagnostics) != 1 || diags.Diagnostics[0].Message != tc.msg {
+				t.Errorf("%q: expected diagnostic %q, got %+v", tc.text, tc.msg, diags.Diagnostics)
+			}
+		}
+	})
+}
@@ -0,0 +1,238 @@
+// Package mod parses apl.mod module manifests and apl.sum lockfiles.
+//
+// A manifest declares the module path, the language version and the modules
+// it depends on. Each dependency names the module path, a version and the
+// local directory holding that module, relative to the manifest:
+//
+//	// The app.
+//	module example.com/app
+//	apl 0.1
+//
+//	require example.com/lib v1.2.0 ../lib
+//	require (
+//		example.com/util v0.3.1 ../util
+//	)
+//
+// A dependency's own manifest may state the version it provides with a
+// "version" line, which must then match the required version.
+package mod
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ManifestName is the file name of module manifests.
+	ManifestName = "apl.mod"
+	// SumName is the file name of lockfiles.
+	SumName = "apl.sum"
+	// LangVersion is the newest language version supported.
+	LangVersion = "0.1"
+)
+
+// File is a parsed manifest.
+type File struct {
+	Module  string     // Module path.
+	Version string     // Version this module provides, if stated.
+	Lang    string     // Language version, from the "apl" line.
+	Require []*Require // Dependencies, in order of appearance.
+}
+
+// Require is a dependency on another module.
+type Require struct {
+	Path    string // Module path.
+	Version string // Semantic version, e.g. "v1.2.0".
+	Dir     string // Slash-separated directory, relative to the manifest.
+	Line    int    // Line of the requirement in the manifest (1-indexed).
+}
+
+// Parse parses the manifest data. The name is used in errors.
+func Parse(name string, data []byte) (*File, error) {
+	f := &File{}
+	inBlock := false
+	for i, line := range strings.Split(string(data), "\n") {
+		lineno := i + 1
+		errf := func(format string, args ...interface{}) error {
+			return fmt.Errorf("%s:%d: %s", name, lineno, fmt.Sprintf(format, args...))
+		}
+		if j := strings.Index(line, "//"); j >= 0 {
+			line = line[:j]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if inBlock {
+			if len(fields) == 1 && fields[0] == ")" {
+				inBlock = false
+				continue
+			}
+			req, err := parseRequire(fields, lineno)
+			if err != nil {
+				return nil, errf("%s", err)
+			}
+			f.Require = append(f.Require, req)
+			continue
+		}
+		switch fields[0] {
+		case "module":
+			if len(fields) != 2 {
+				return nil, errf("usage: module path")
+			}
+			if f.Module != "" {
+				return nil, errf("repeated module statement")
+			}
+			if err := CheckPath(fields[1]); err != nil {
+				return nil, errf("%s", err)
+			}
+			f.Module = fields[1]
+		case "version":
+			if len(fields) != 2 {
+				return nil, errf("usage: version vX.Y.Z")
+			}
+			if !ValidVersion(fields[1]) {
+				return nil, errf("invalid version %q", fields[1])
+			}
+			f.Version = fields[1]
+		case "apl":
+			if len(fields) != 2 {
+				return nil, errf("usage: apl X.Y")
+			}
+			if _, _, ok := parseLang(fields[1]); !ok {
+				return nil, errf("invalid language version %q", fields[1])
+			}
+			if CompareLang(fields[1], LangVersion) > 0 {
+				return nil, errf("module requires apl %s, but only %s is supported", fields[1], LangVersion)
+			}
+			f.Lang = fields[1]
+		case "require":
+			if len(fields) == 2 && fields[1] == "(" {
+				inBlock = true
+				continue
+			}
+			req, err := parseRequire(fields[1:], lineno)
+			if err != nil {
+				return nil, errf("%s", err)
+			}
+			f.Require = append(f.Require, req)
+		default:
+			return nil, errf("unknown directive: %s", fields[0])
+		}
+	}
+	if inBlock {
+		return nil, fmt.Errorf("%s: unterminated require block", name)
+	}
+	if f.Module == "" {
+		return nil, fmt.Errorf("%s: missing module statement", name)
+	}
+	seen := make(map[string]bool)
+	for _, req := range f.Require {
+		if seen[req.Path] {
+			return nil, fmt.Errorf("%s:%d: %s required twice", name, req.Line, req.Path)
+		}
+		seen[req.Path] = true
+	}
+	return f, nil
+}
+
+func parseRequire(fields []string, line int) (*Require, error) {
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("usage: require path vX.Y.Z dir")
+	}
+	if err := CheckPath(fields[0]); err != nil {
+		return nil, err
+	}
+	if !ValidVersion(fields[1]) {
+		return nil, fmt.Errorf("invalid version %q", fields[1])
+	}
+	return &Require{Path: fields[0], Version: fields[1], Dir: fields[2], Line: line}, nil
+}
+
+// CheckPath returns an error if path is not a valid module path: a non-empty
+// slash-separated path without empty, "." or ".." elements.
+func CheckPath(path string) error {
+	if path == "" {
+		return fmt.Errorf("empty module path")
+	}
+	for _, elem := range strings.Split(path, "/") {
+		if elem == "" || elem == "." || elem == ".." {
+			return fmt.Errorf("invalid module path %q", path)
+		}
+	}
+	return nil
+}
+
+// ValidVersion reports whether v is a semantic version of the form vX.Y.Z.
+func ValidVersion(v string) bool {
+	_, ok := parseVersion(v)
+	return ok
+}
+
+func parseVersion(v string) ([3]int, bool) {
+	var parts [3]int
+	if !strings.HasPrefix(v, "v") {
+		return parts, false
+	}
+	fields := strings.Split(v[1:], ".")
+	if len(fields) != 3 {
+		return parts, false
+	}
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 || (len(field) > 1 && field[0] == '0') {
+			return parts, false
+		}
+		parts[i] = n
+	}
+	return parts, true
+}
+
+// Compare returns -1, 0 or 1 if the version v is less than, equal to or
+// greater than w. Both must be valid versions.
+func Compare(v, w string) int {
+	pv, _ := parseVersion(v)
+	pw, _ := parseVersion(w)
+	for i := range pv {
+		if pv[i] != pw[i] {
+			if pv[i] < pw[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func parseLang(v string) (int, int, bool) {
+	fields := strings.Split(v, ".")
+	if len(fields) != 2 {
+		return 0, 0, false
+	}
+	major, err1 := strconv.Atoi(fields[0])
+	minor, err2 := strconv.Atoi(fields[1])
+	if err1 != nil || err2 != nil || major < 0 || minor < 0 {
+		return 0, 0, false
+	}
+	return major, minor, true
+}
+
+// CompareLang compares two language versions of the form X.Y like Compare.
+func CompareLang(v, w string) int {
+	vmaj, vmin, _ := parseLang(v)
+	wmaj, wmin, _ := parseLang(w)
+	switch {
+	case vmaj != wmaj:
+		if vmaj < wmaj {
+			return -1
+		}
+		return 1
+	case vmin != wmin:
+		if vmin < wmin {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
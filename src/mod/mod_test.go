@@ -0,0 +1,135 @@
+package mod
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		name   string
+		input  string
+		output *File
+		err    string
+	}{
+		{
+			name: "normal",
+			input: `// The app.
+module example.com/app
+apl 0.1
+
+require example.com/lib v1.2.0 ../lib
+require (
+	example.com/util v0.3.10 ../util // Trailing comment.
+)
+`,
+			output: &File{
+				Module: "example.com/app",
+				Lang:   "0.1",
+				Require: []*Require{
+					{Path: "example.com/lib", Version: "v1.2.0", Dir: "../lib", Line: 5},
+					{Path: "example.com/util", Version: "v0.3.10", Dir: "../util", Line: 7},
+				},
+			},
+		},
+		{
+			name:   "version",
+			input:  "module lib\nversion v1.0.0\n",
+			output: &File{Module: "lib", Version: "v1.0.0"},
+		},
+		{
+			name:  "missing_module",
+			input: "apl 0.1\n",
+			err:   "apl.mod: missing module statement",
+		},
+		{
+			name:  "newer_language",
+			input: "module app\napl 0.2\n",
+			err:   "apl.mod:2: module requires apl 0.2, but only 0.1 is supported",
+		},
+		{
+			name:  "invalid_version",
+			input: "module app\nrequire lib 1.2.0 ../lib\n",
+			err:   `apl.mod:2: invalid version "1.2.0"`,
+		},
+		{
+			name:  "invalid_path",
+			input: "module app/../x\n",
+			err:   `apl.mod:1: invalid module path "app/../x"`,
+		},
+		{
+			name:  "duplicate_require",
+			input: "module app\nrequire lib v1.0.0 a\nrequire lib v1.1.0 b\n",
+			err:   "apl.mod:3: lib required twice",
+		},
+		{
+			name:  "unterminated_block",
+			input: "module app\nrequire (\nlib v1.0.0 a\n",
+			err:   "apl.mod: unterminated require block",
+		},
+		{
+			name:  "unknown_directive",
+			input: "module app\nreplace lib\n",
+			err:   "apl.mod:2: unknown directive: replace",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := Parse("apl.mod", []byte(tc.input))
+			if tc.err != "" {
+				if err == nil || err.Error() != tc.err {
+					t.Fatalf("expected %q but got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(f, tc.output) {
+				t.Errorf("expected %+v, got %+v", tc.output, f)
+			}
+		})
+	}
+}
+
+func TestCompare(t *testing.T) {
+	testCases := []struct {
+		v, w string
+		cmp  int
+	}{
+		{"v1.2.0", "v1.2.0", 0},
+		{"v1.2.0", "v1.10.0", -1},
+		{"v2.0.0", "v1.99.99", 1},
+		{"v0.0.1", "v0.0.2", -1},
+	}
+	for _, tc := range testCases {
+		if cmp := Compare(tc.v, tc.w); cmp != tc.cmp {
+			t.Errorf("Compare(%s, %s) = %d, expected %d", tc.v, tc.w, cmp, tc.cmp)
+		}
+	}
+	for _, v := range []string{"1.0.0", "v1.0", "v01.0.0", "v1.0.0-pre"} {
+		if ValidVersion(v) {
+			t.Errorf("expected %q to be invalid", v)
+		}
+	}
+}
+
+func TestSum(t *testing.T) {
+	s := NewSum()
+	s.Add("lib", "v1.0.0", "b.apl", Hash([]byte("b")))
+	s.Add("lib", "v1.0.0", "a.apl", Hash([]byte("a")))
+	data := s.Format()
+	parsed, err := ParseSum("apl.sum", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(parsed.Format()) != string(data) {
+		t.Errorf("expected round trip of\n%s\ngot\n%s", data, parsed.Format())
+	}
+	if h, ok := parsed.Lookup("lib", "v1.0.0", "a.apl"); !ok || h != Hash([]byte("a")) {
+		t.Errorf("unexpected lookup result %q, %t", h, ok)
+	}
+	if _, err := ParseSum("apl.sum", []byte("lib v1.0.0/a.apl md5:00\n")); err == nil {
+		t.Error("expected malformed line error")
+	}
+}
@@ -0,0 +1,78 @@
+package mod
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Sum is the contents of a lockfile: the content hashes of the source files
+// of dependencies. Each line holds the module path, the version and file
+// path joined by a slash, and the hash:
+//
+//	example.com/lib v1.2.0/lib.apl sha256:8f43...
+type Sum struct {
+	hashes map[string]string
+}
+
+// NewSum returns an empty Sum.
+func NewSum() *Sum {
+	return &Sum{hashes: make(map[string]string)}
+}
+
+func sumKey(module, version, file string) string {
+	return module + " " + version + "/" + file
+}
+
+// Hash returns the hash of data in the format used by lockfiles.
+func Hash(data []byte) string {
+	h := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(h[:])
+}
+
+// ParseSum parses lockfile data. The name is used in errors.
+func ParseSum(name string, data []byte) (*Sum, error) {
+	s := NewSum()
+	for i, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 || !strings.HasPrefix(fields[2], "sha256:") {
+			return nil, fmt.Errorf("%s:%d: malformed line", name, i+1)
+		}
+		key := fields[0] + " " + fields[1]
+		if _, ok := s.hashes[key]; ok {
+			return nil, fmt.Errorf("%s:%d: repeated entry for %s", name, i+1, key)
+		}
+		s.hashes[key] = fields[2]
+	}
+	return s, nil
+}
+
+// Lookup returns the locked hash of a file of a module version, if any.
+func (s *Sum) Lookup(module, version, file string) (string, bool) {
+	h, ok := s.hashes[sumKey(module, version, file)]
+	return h, ok
+}
+
+// Add locks the hash of a file of a module version.
+func (s *Sum) Add(module, version, file, hash string) {
+	s.hashes[sumKey(module, version, file)] = hash
+}
+
+// Format returns the lockfile data, sorted by module, version and file.
+func (s *Sum) Format() []byte {
+	var keys []string
+	for key := range s.hashes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, key := range keys {
+		fmt.Fprintf(&b, "%s %s\n", key, s.hashes[key])
+	}
+	return []byte(b.String())
+}
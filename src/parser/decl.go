@@ -19,23 +19,34 @@ func (p *P) parseDecls() ([]ast.Decl, error) {
 }
 
 func (p *P) parseDecl() (ast.Decl, error) {
-	_, tok, err := p.consume(TokenFunc, TokenTyp)
+	_, tok, err := p.consume(TokenFunc, TokenTyp, TokenExport)
 	if err != nil {
 		return nil, err
 	}
 	switch tok.Typ {
+	case TokenExport:
+		_, _, err := p.consume(TokenFunc)
+		if err != nil {
+			return nil, err
+		}
+		p.tokens.unread()
+		fn, err := p.parseFnDecl()
+		if err != nil {
+			return nil, err
+		}
+		fn.Export = true
+		return fn, nil
 	case TokenFunc:
 		p.tokens.unread()
 		return p.parseFnDecl()
 	case TokenTyp:
-		p.tokens.unread()
-		panic("type decl not yet implemented")
+		return nil, p.errf(tok, "type declarations are not supported")
 	default:
 		return nil, p.errf(tok, "unexpected keyword")
 	}
 }
 
-func (p *P) parseFnDecl() (ast.Decl, error) {
+func (p *P) parseFnDecl() (*ast.FnDecl, error) {
 	_, tok, err := p.consume(TokenFunc)
 	if err != nil {
 		return nil, err
@@ -60,7 +71,7 @@ func (p *P) parseFnDecl() (ast.Decl, error) {
 	if err != nil {
 		return nil, err
 	}
-	_, _, err = p.consume(TokenBraceClose)
+	_, end, err := p.consume(TokenBraceClose)
 	if err != nil {
 		return nil, err
 	}
@@ -70,6 +81,7 @@ func (p *P) parseFnDecl() (ast.Decl, error) {
 		Args:       args,
 		Return:     returnType,
 		Statements: stmts,
+		End:        TokenSource{end},
 	}, nil
 }
 
@@ -4,16 +4,134 @@ import (
 	"ast/expr"
 )
 
-// TODO(adi): Real expr parsing needs work.
+// binaryOps maps the tokens of binary operators to the operators.
+var binaryOps = map[TokenType]string{
+	TokenOr:        "||",
+	TokenAnd:       "&&",
+	TokenEq:        "==",
+	TokenNotEq:     "!=",
+	TokenLess:      "<",
+	TokenLessEq:    "<=",
+	TokenGreater:   ">",
+	TokenGreaterEq: ">=",
+	TokenPlus:      "+",
+	TokenMinus:     "-",
+	TokenStar:      "*",
+	TokenSlash:     "/",
+	TokenPercent:   "%",
+}
+
+// parseExpr parses an expression. Returns a nil expression if the next token
+// is a comma, semicolon or closing parenthesis, i.e. if there is no
+// expression.
 func (p *P) parseExpr() (expr.Expr, error) {
 	tok, err := p.tokens.get()
 	if err != nil {
 		return nil, err
 	}
+	p.tokens.unread()
 	if tok.Typ == TokenComma || tok.Typ == TokenSemicolon || tok.Typ == TokenParensClose {
-		p.tokens.unread()
 		return nil, nil
 	}
+	return p.parseBinary(1)
+}
+
+// parseBinary parses an expression whose binary operators all have at least
+// the precedence prec.
+func (p *P) parseBinary(prec int) (expr.Expr, error) {
+	x, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok, err := p.tokens.get()
+		if err != nil {
+			return nil, err
+		}
+		op, ok := binaryOps[tok.Typ]
+		if !ok || expr.Precedence(op) < prec {
+			p.tokens.unread()
+			return x, nil
+		}
+		y, err := p.parseBinary(expr.Precedence(op) + 1)
+		if err != nil {
+			return nil, err
+		}
+		x = &expr.Binary{
+			Source: TokenSource{tok},
+			Op:     op,
+			X:      x,
+			Y:      y,
+		}
+	}
+}
+
+func (p *P) parseUnary() (expr.Expr, error) {
+	tok, err := p.tokens.get()
+	if err != nil {
+		return nil, err
+	}
+	if tok.Typ != TokenNot && tok.Typ != TokenMinus {
+		p.tokens.unread()
+		return p.parsePrimary()
+	}
+	x, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	return &expr.Unary{
+		Source: TokenSource{tok},
+		Op:     string(tok.Lit),
+		X:      x,
+	}, nil
+}
+
+// parsePrimary parses a constant value, a variable, a call or a
+// parenthesized expression.
+func (p *P) parsePrimary() (expr.Expr, error) {
+	tok, err := p.tokens.get()
+	if err != nil {
+		return nil, err
+	}
+	if tok.Typ == TokenParensOpen {
+		x, err := p.parseBinary(1)
+		if err != nil {
+			return nil, err
+		}
+		if _, _, err := p.consume(TokenParensClose); err != nil {
+			return nil, err
+		}
+		return x, nil
+	}
+	if tok.Typ == TokenText && !isLiteral(tok) {
+		name, err := p.parseName(tok)
+		if err != nil {
+			return nil, err
+		}
+		next, err := p.tokens.get()
+		if err != nil {
+			return nil, err
+		}
+		p.tokens.unread()
+		if next.Typ == TokenParensOpen {
+			params, err := p.parseParams()
+			if err != nil {
+				return nil, err
+			}
+			return &expr.Call{
+				Source: TokenSource{tok},
+				Nam:    name,
+				Params: params,
+			}, nil
+		}
+		if name != string(tok.Lit) {
+			return nil, p.errf(next, "expected call of %s", name)
+		}
+		return &expr.Ident{
+			Source: TokenSource{tok},
+			Nam:    name,
+		}, nil
+	}
 	value, err := p.toValue(tok)
 	if err != nil {
 		return nil, err
@@ -23,3 +141,52 @@ func (p *P) parseExpr() (expr.Expr, error) {
 		V:      value,
 	}, nil
 }
+
+// parseName parses a name starting with tok, which may be qualified by the
+// name of an import as in "lib.foo".
+func (p *P) parseName(tok Token) (string, error) {
+	name := string(tok.Lit)
+	next, err := p.tokens.get()
+	if err != nil {
+		return "", err
+	}
+	if next.Typ != TokenDot {
+		p.tokens.unread()
+		return name, nil
+	}
+	sel, _, err := p.consumeText()
+	if err != nil {
+		return "", err
+	}
+	return name + "." + sel, nil
+}
+
+// parseParams parses a parenthesized, comma-separated list of params.
+func (p *P) parseParams() ([]expr.Expr, error) {
+	if _, _, err := p.consume(TokenParensOpen); err != nil {
+		return nil, err
+	}
+	tok, err := p.tokens.get()
+	if err != nil {
+		return nil, err
+	}
+	if tok.Typ == TokenParensClose {
+		return nil, nil
+	}
+	p.tokens.unread()
+	var params []expr.Expr
+	for {
+		param, err := p.parseBinary(1)
+		if err != nil {
+			return nil, err
+		}
+		params = append(params, param)
+		_, tok, err := p.consume(TokenComma, TokenParensClose)
+		if err != nil {
+			return nil, err
+		}
+		if tok.Typ == TokenParensClose {
+			return params, nil
+		}
+	}
+}
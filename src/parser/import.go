@@ -27,16 +27,87 @@ func (p *P) parseImport() (*statement.Import, error) {
 		}
 		return nil, err
 	}
-	name, _, err := p.consumeText()
+	name, err := p.parseImportPath()
 	if err != nil {
 		return nil, err
 	}
+	imp := &statement.Import{
+		Source: TokenSource{tok},
+		Name:   name,
+	}
+	next, err := p.tokens.get()
+	if err != nil {
+		return nil, err
+	}
+	// "as" is not a keyword; it is only special after an import path.
+	if next.Typ == TokenText && string(next.Lit) == "as" {
+		imp.Alias, _, err = p.consumeText()
+		if err != nil {
+			return nil, err
+		}
+		next, err = p.tokens.get()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if next.Typ == TokenBraceOpen {
+		imp.Names, err = p.parseImportNames()
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		p.tokens.unread()
+	}
 	_, _, err = p.consume(TokenSemicolon)
 	if err != nil {
 		return nil, err
 	}
-	return &statement.Import{
-		Source: TokenSource{tok},
-		Name:   name,
-	}, nil
+	return imp, nil
+}
+
+// parseImportNames parses the comma-separated names selected by an import,
+// after the opening brace, up to and including the closing brace.
+func (p *P) parseImportNames() ([]*statement.ImportName, error) {
+	var names []*statement.ImportName
+	for {
+		name, tok, err := p.consumeText()
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, &statement.ImportName{
+			Source: TokenSource{tok},
+			Nam:    name,
+		})
+		_, tok, err = p.consume(TokenComma, TokenBraceClose)
+		if err != nil {
+			return nil, err
+		}
+		if tok.Typ == TokenBraceClose {
+			return names, nil
+		}
+	}
+}
+
+// parseImportPath parses a slash-separated import path such as net/http.
+// Elements may contain dots, as in example.com/lib.
+func (p *P) parseImportPath() (string, error) {
+	path, _, err := p.consumeText()
+	if err != nil {
+		return "", err
+	}
+	for {
+		tok, err := p.tokens.get()
+		if err != nil {
+			return "", err
+		}
+		if tok.Typ != TokenSlash && tok.Typ != TokenDot {
+			p.tokens.unread()
+			return path, nil
+		}
+		elem, _, err := p.consumeText()
+		if err != nil {
+			return "", err
+		}
+		path += string(tok.Lit) + elem
+	}
 }
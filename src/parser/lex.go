@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"strings"
 	"unicode"
 )
 
@@ -29,6 +30,25 @@ const (
 	TokenImport
 	TokenReturn
 	TokenText
+	TokenComment
+	TokenSlash
+	TokenDot
+	TokenExport
+	TokenPlus
+	TokenMinus
+	TokenStar
+	TokenPercent
+	TokenNot
+	TokenEq
+	TokenNotEq
+	TokenLess
+	TokenLessEq
+	TokenGreater
+	TokenGreaterEq
+	TokenAnd
+	TokenOr
+	// TokenEOF marks the end of the input.
+	TokenEOF
 )
 
 func (t TokenType) String() string {
@@ -48,6 +68,7 @@ func init() {
 		"type":   TokenTyp,
 		"import": TokenImport,
 		"return": TokenReturn,
+		"export": TokenExport,
 	}
 	tokens = map[TokenType]string{
 		TokenError:       "TokenError",
@@ -67,6 +88,24 @@ func init() {
 		TokenImport:      "TokenImport",
 		TokenReturn:      "TokenReturn",
 		TokenText:        "TokenText",
+		TokenComment:     "TokenComment",
+		TokenSlash:       "TokenSlash",
+		TokenDot:         "TokenDot",
+		TokenExport:      "TokenExport",
+		TokenPlus:        "TokenPlus",
+		TokenMinus:       "TokenMinus",
+		TokenStar:        "TokenStar",
+		TokenPercent:     "TokenPercent",
+		TokenNot:         "TokenNot",
+		TokenEq:          "TokenEq",
+		TokenNotEq:       "TokenNotEq",
+		TokenLess:        "TokenLess",
+		TokenLessEq:      "TokenLessEq",
+		TokenGreater:     "TokenGreater",
+		TokenGreaterEq:   "TokenGreaterEq",
+		TokenAnd:         "TokenAnd",
+		TokenOr:          "TokenOr",
+		TokenEOF:         "TokenEOF",
 	}
 }
 
@@ -97,6 +136,8 @@ type Lexer struct {
 	line        int
 	linePos     int
 	prevLinePos int
+	last        Token // The final TokenEOF or TokenError, once done.
+	done        bool
 }
 
 // NewLexer returns a new Lexer.
@@ -107,26 +148,22 @@ func NewLexer(fileName string, scanner io.RuneScanner) *Lexer {
 	}
 }
 
-// Tokens returns a stream of tokens. The channel is closed when the input byte
-// stream is fully consumed or if an error is encountered while lexing. If an
-// error occurs, the final token will be of type TokenError.
-func (l *Lexer) Tokens() <-chan Token {
-	tokens := make(chan Token)
-	go func() {
-		for {
-			token := l.nextIgnoreSpace()
-			if token.Err != nil {
-				if token.Err != io.EOF {
-					tokens <- token
-				}
-				close(tokens)
-				return
-			}
-			tokens <- token
-		}
-		close(tokens)
-	}()
-	return tokens
+// Next returns the next token. Once the input is fully consumed, it returns
+// a token of type TokenEOF positioned at the end of the input. If an error is
+// encountered while lexing, it returns a token of type TokenError. Either
+// token is final: all later calls return it again.
+func (l *Lexer) Next() Token {
+	if l.done {
+		return l.last
+	}
+	t := l.nextIgnoreSpace()
+	if t.Err == io.EOF {
+		t = Token{Typ: TokenEOF, File: l.fileName, Pos: l.pos, Line: l.line, LinePos: l.linePos}
+	}
+	if t.Typ == TokenEOF || t.Typ == TokenError {
+		l.last, l.done = t, true
+	}
+	return t
 }
 
 func (l *Lexer) read() (rune, error) {
@@ -211,9 +248,31 @@ func (l *Lexer) next() Token {
 	case ',':
 		return l.emitSymbol(r, TokenComma)
 	case '=':
-		return l.emitSymbol(r, TokenAssign)
+		return l.emitPair(r, '=', TokenAssign, TokenEq)
+	case '!':
+		return l.emitPair(r, '=', TokenNot, TokenNotEq)
+	case '<':
+		return l.emitPair(r, '=', TokenLess, TokenLessEq)
+	case '>':
+		return l.emitPair(r, '=', TokenGreater, TokenGreaterEq)
+	case '&':
+		return l.emitPair(r, '&', TokenError, TokenAnd)
+	case '|':
+		return l.emitPair(r, '|', TokenError, TokenOr)
+	case '+':
+		return l.emitSymbol(r, TokenPlus)
+	case '-':
+		return l.emitSymbol(r, TokenMinus)
+	case '*':
+		return l.emitSymbol(r, TokenStar)
+	case '%':
+		return l.emitSymbol(r, TokenPercent)
+	case '.':
+		return l.emitSymbol(r, TokenDot)
 	case '"':
 		return l.emitString()
+	case '/':
+		return l.emitSlashOrComment()
 	default:
 		err = l.unread()
 		if err != nil {
@@ -234,6 +293,28 @@ func (l *Lexer) emitSymbol(r rune, typ TokenType) Token {
 	return Token{Typ: typ, Lit: []rune{r}}
 }
 
+// emitPair emits the two-rune token typ if the rune r that has already been
+// read is followed by second, or the single-rune token single otherwise. If
+// single is TokenError, r may only appear as part of the pair.
+func (l *Lexer) emitPair(r, second rune, single, typ TokenType) Token {
+	next, err := l.read()
+	if err != nil && err != io.EOF {
+		return l.err(err)
+	}
+	if err == nil && next == second {
+		return Token{Typ: typ, Lit: []rune{r, second}}
+	}
+	if err == nil {
+		if err := l.unread(); err != nil {
+			return l.err(err)
+		}
+	}
+	if single == TokenError {
+		return l.err(fmt.Errorf("unexpected %q", r))
+	}
+	return l.emitSymbol(r, single)
+}
+
 func (l *Lexer) emitString() Token {
 	t := l.emitUntil(func(b rune) bool {
 		return b == '"'
@@ -245,13 +326,51 @@ func (l *Lexer) emitString() Token {
 	return t
 }
 
+// emitSlashOrComment emits a line comment if the slash that has already been
+// read is followed by another one, or a slash otherwise. The literal of a
+// comment includes the "//" but not the terminating newline.
+func (l *Lexer) emitSlashOrComment() Token {
+	r, err := l.read()
+	if err == io.EOF {
+		return l.emitSymbol('/', TokenSlash)
+	}
+	if err != nil {
+		return l.err(err)
+	}
+	if r != '/' {
+		if err := l.unread(); err != nil {
+			return l.err(err)
+		}
+		return l.emitSymbol('/', TokenSlash)
+	}
+	lit := []rune("//")
+	for {
+		r, err := l.read()
+		if err == io.EOF || r == '\n' {
+			break
+		}
+		if err != nil {
+			return l.err(err)
+		}
+		lit = append(lit, r)
+	}
+	return Token{Typ: TokenComment, Lit: []rune(strings.TrimRightFunc(string(lit), unicode.IsSpace))}
+}
+
+// emitAlphaNum emits a keyword or text. Text starting with a digit is a
+// number, which may contain a decimal point.
 func (l *Lexer) emitAlphaNum() Token {
+	first, number := true, false
 	t := l.emitUntil(func(b rune) bool {
-		isAlphaNum := ('0' <= b && b <= '9') ||
+		isDigit := '0' <= b && b <= '9'
+		if first {
+			first, number = false, isDigit
+		}
+		isAlphaNum := isDigit ||
 			('a' <= b && b <= 'z') ||
 			('A' <= b && b <= 'Z') ||
 			(b == '_')
-		return !isAlphaNum
+		return !isAlphaNum && !(number && b == '.')
 	})
 	if t.Err != nil {
 		return t
@@ -126,6 +126,39 @@ func main() {
 				},
 			},
 		},
+		{
+			name:  "comment",
+			input: "foo // bar  \n// baz",
+			output: []Token{
+				{Typ: TokenText, Lit: []rune("foo"), Pos: 0, Line: 0, LinePos: 0},
+				{Typ: TokenComment, Lit: []rune("// bar"), Pos: 4, Line: 0, LinePos: 4},
+				{Typ: TokenComment, Lit: []rune("// baz"), Pos: 13, Line: 1, LinePos: 0},
+			},
+		},
+		{
+			name:  "operators",
+			input: "x<=1 && !y == -2;",
+			output: []Token{
+				{Typ: TokenText, Lit: []rune("x"), Pos: 0, Line: 0, LinePos: 0},
+				{Typ: TokenLessEq, Lit: []rune("<="), Pos: 1, Line: 0, LinePos: 1},
+				{Typ: TokenText, Lit: []rune("1"), Pos: 3, Line: 0, LinePos: 3},
+				{Typ: TokenAnd, Lit: []rune("&&"), Pos: 5, Line: 0, LinePos: 5},
+				{Typ: TokenNot, Lit: []rune("!"), Pos: 8, Line: 0, LinePos: 8},
+				{Typ: TokenText, Lit: []rune("y"), Pos: 9, Line: 0, LinePos: 9},
+				{Typ: TokenEq, Lit: []rune("=="), Pos: 11, Line: 0, LinePos: 11},
+				{Typ: TokenMinus, Lit: []rune("-"), Pos: 14, Line: 0, LinePos: 14},
+				{Typ: TokenText, Lit: []rune("2"), Pos: 15, Line: 0, LinePos: 15},
+				{Typ: TokenSemicolon, Lit: []rune(";"), Pos: 16, Line: 0, LinePos: 16},
+			},
+		},
+		{
+			name:  "single_ampersand",
+			input: "a & b",
+			output: []Token{
+				{Typ: TokenText, Lit: []rune("a"), Pos: 0, Line: 0, LinePos: 0},
+				{Typ: TokenError, Pos: 2, Err: errors.New(`unexpected '&'`)},
+			},
+		},
 		{
 			name:  "unterminated_string",
 			input: "\"foo",
@@ -138,8 +171,15 @@ func main() {
 		t.Run(tc.name, func(t *testing.T) {
 			l := NewLexer("test.apl", strings.NewReader(tc.input))
 			var tokens []Token
-			for token := range l.Tokens() {
+			for {
+				token := l.Next()
+				if token.Typ == TokenEOF {
+					break
+				}
 				tokens = append(tokens, token)
+				if token.Typ == TokenError {
+					break
+				}
 			}
 			if len(tokens) != len(tc.output) {
 				t.Fatalf("expected %d tokens. got %d", len(tc.output), len(tokens))
@@ -157,3 +197,46 @@ func main() {
 		})
 	}
 }
+
+func TestLexerFinalToken(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input string
+		final Token
+	}{
+		{
+			name:  "eof",
+			input: "foo;\n",
+			final: Token{Typ: TokenEOF, Pos: 5, Line: 1, LinePos: 0},
+		},
+		{
+			name:  "error",
+			input: "foo & bar;",
+			final: Token{Typ: TokenError, Pos: 4, Err: errors.New(`unexpected '&'`)},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			l := NewLexer("test.apl", strings.NewReader(tc.input))
+			for i := 0; i < 10; i++ {
+				token := l.Next()
+				if token.Typ != TokenEOF && token.Typ != TokenError {
+					continue
+				}
+				if !tokensEqual(token, tc.final) {
+					t.Errorf("expected final %v, but got %v", tc.final, token)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkLexer(b *testing.B) {
+	src := benchSource(1000)
+	b.SetBytes(int64(len(src)))
+	for i := 0; i < b.N; i++ {
+		l := NewLexer("bench.apl", strings.NewReader(src))
+		for l.Next().Typ != TokenEOF {
+		}
+	}
+}
@@ -5,39 +5,81 @@ import (
 	"fmt"
 
 	"ast"
+	"ast/source"
 )
 
 var (
 	errEOF = errors.New("unexpected eof")
 )
 
+// maxUnread is the number of consumed tokens that can always be unread.
+const maxUnread = 16
+
+// gettoken reads tokens from a lexer for the parser, which may look any
+// number of tokens ahead and unread up to maxUnread tokens.
 type gettoken struct {
-	tokens <-chan Token
-	prev   Token
-	undo   bool
+	lexer    *Lexer
+	toks     []Token // Tokens read so far, without comments.
+	pos      int     // Index of the next token in toks.
+	comments []Token
 }
 
-func (gt *gettoken) get() (Token, error) {
-	if gt.undo {
-		gt.undo = false
-		return gt.prev, nil
+// peek returns the token n tokens after the next one, without consuming any.
+// Comments are not returned but collected separately in the order they
+// appear. At the end of the input, it returns errEOF.
+func (gt *gettoken) peek(n int) (Token, error) {
+	if gt.pos >= 4*maxUnread {
+		// Drop consumed tokens that can no longer be unread.
+		k := copy(gt.toks, gt.toks[gt.pos-maxUnread:])
+		gt.toks = gt.toks[:k]
+		gt.pos = maxUnread
+	}
+	for len(gt.toks) <= gt.pos+n && !gt.ended() {
+		tok := gt.lexer.Next()
+		if tok.Typ == TokenComment {
+			gt.comments = append(gt.comments, tok)
+			continue
+		}
+		gt.toks = append(gt.toks, tok)
 	}
-	ret, isOpen := <-gt.tokens
-	if !isOpen {
-		return ret, errEOF
+	i := gt.pos + n
+	if i >= len(gt.toks) {
+		// Past the end, the final token repeats.
+		i = len(gt.toks) - 1
 	}
-	if ret.Err != nil {
-		return ret, ret.Err
+	tok := gt.toks[i]
+	switch tok.Typ {
+	case TokenEOF:
+		return tok, errEOF
+	case TokenError:
+		return tok, tok.Err
 	}
-	gt.prev = ret
-	return ret, nil
+	return tok, nil
 }
 
+// ended reports whether the final token of the lexer has been read.
+func (gt *gettoken) ended() bool {
+	if len(gt.toks) == 0 {
+		return false
+	}
+	typ := gt.toks[len(gt.toks)-1].Typ
+	return typ == TokenEOF || typ == TokenError
+}
+
+// get consumes and returns the next token, as by peek(0).
+func (gt *gettoken) get() (Token, error) {
+	tok, err := gt.peek(0)
+	gt.pos++
+	return tok, err
+}
+
+// unread puts back the last token consumed by get that has not been put back
+// yet.
 func (gt *gettoken) unread() {
-	if gt.undo {
-		panic("cannot undo token stream twice")
+	if gt.pos == 0 {
+		panic(fmt.Sprintf("cannot unread more than %d tokens", maxUnread))
 	}
-	gt.undo = true
+	gt.pos--
 }
 
 // P is the parser that converts a token stream to the AST.
@@ -45,10 +87,10 @@ type P struct {
 	tokens *gettoken
 }
 
-// NewParser returns a new P.
-func NewParser(tokens <-chan Token) *P {
+// NewParser returns a new P reading tokens from l.
+func NewParser(l *Lexer) *P {
 	return &P{
-		tokens: &gettoken{tokens, Token{}, false},
+		tokens: &gettoken{lexer: l},
 	}
 }
 
@@ -68,15 +110,33 @@ func (p *P) Do() (*ast.File, error) {
 	if err != nil {
 		return nil, err
 	}
+	var comments []*ast.Comment
+	for _, c := range p.tokens.comments {
+		comments = append(comments, &ast.Comment{
+			Source: TokenSource{c},
+			Text:   string(c.Lit),
+		})
+	}
 	return &ast.File{
-		Source:  TokenSource{tok},
-		Imports: imports,
-		Decls:   decls,
+		Source:   TokenSource{tok},
+		Imports:  imports,
+		Decls:    decls,
+		Comments: comments,
 	}, nil
 }
 
+// Error is a parse error at a token.
+type Error struct {
+	Tok Token
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("error at pos %d (%s): %s", e.Tok.Pos, string(e.Tok.Lit), e.Msg)
+}
+
 func (p *P) errf(t Token, format string, args ...interface{}) error {
-	return fmt.Errorf("error at pos %d (%s): %s", t.Pos, string(t.Lit), fmt.Sprintf(format, args...))
+	return &Error{Tok: t, Msg: fmt.Sprintf(format, args...)}
 }
 
 func (p *P) consume(typs ...TokenType) (bool, Token, error) {
@@ -128,7 +188,8 @@ func (t TokenSource) File() string {
 	return t.Token.File
 }
 
-// Errf formats an error with the positional information prepended.
+// Errf formats an error with the positional information prepended. The
+// returned error is a *source.Error.
 func (t TokenSource) Errf(format string, args ...interface{}) error {
-	return fmt.Errorf("%s:%d:%d %s", t.File(), t.Line()+1, t.LinePos()+1, fmt.Sprintf(format, args...))
+	return source.Errorf(t, format, args...)
 }
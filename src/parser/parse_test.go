@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"fmt"
 	"strings"
 	"testing"
 
@@ -114,6 +115,142 @@ func hello(int i, string x) bool {
 			output: nil,
 			err:    "error at pos 7 (;): expected TokenText, got TokenSemicolon",
 		},
+		{
+			name:   "import_path_trailing_slash",
+			input:  "import net/;",
+			output: nil,
+			err:    "error at pos 11 (;): expected TokenText, got TokenSemicolon",
+		},
+		{
+			name:  "import_path",
+			input: "import example.com/net/http;\nfunc main() {}",
+			output: &ast.File{
+				Source: TokenSource{
+					Token{Line: 0, LinePos: 0, Pos: 0, File: "test.apl"},
+				},
+				Imports: []*statement.Import{
+					{
+						Name: "example.com/net/http",
+						Source: TokenSource{
+							Token{Line: 0, LinePos: 0, Pos: 0, File: "test.apl"},
+						},
+					},
+				},
+				Decls: []ast.Decl{
+					&ast.FnDecl{
+						Nam: "main",
+						Source: TokenSource{
+							Token{Line: 1, LinePos: 0, Pos: 29, File: "test.apl"},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:   "import_empty_names",
+			input:  "import math { };",
+			output: nil,
+			err:    "error at pos 14 (}): expected TokenText, got TokenBraceClose",
+		},
+		{
+			name:   "import_alias_missing",
+			input:  "import math as;",
+			output: nil,
+			err:    "error at pos 14 (;): expected TokenText, got TokenSemicolon",
+		},
+		{
+			name:  "expr_precedence",
+			input: "func f() { g(1 + 2 * 3, -x); }",
+			output: &ast.File{
+				Source: TokenSource{
+					Token{Line: 0, LinePos: 0, Pos: 0, File: "test.apl"},
+				},
+				Decls: []ast.Decl{
+					&ast.FnDecl{
+						Nam: "f",
+						Source: TokenSource{
+							Token{Line: 0, LinePos: 0, Pos: 0, File: "test.apl"},
+						},
+						Statements: []statement.Statement{
+							&statement.FnCall{
+								Nam: "g",
+								Source: TokenSource{
+									Token{Line: 0, LinePos: 11, Pos: 11, File: "test.apl"},
+								},
+								Params: []expr.Expr{
+									&expr.Binary{
+										Op: "+",
+										Source: TokenSource{
+											Token{Line: 0, LinePos: 15, Pos: 15, File: "test.apl"},
+										},
+										X: &expr.Value{
+											V: &values.Int{V: 1},
+											Source: TokenSource{
+												Token{Line: 0, LinePos: 13, Pos: 13, File: "test.apl"},
+											},
+										},
+										Y: &expr.Binary{
+											Op: "*",
+											Source: TokenSource{
+												Token{Line: 0, LinePos: 19, Pos: 19, File: "test.apl"},
+											},
+											X: &expr.Value{
+												V: &values.Int{V: 2},
+												Source: TokenSource{
+													Token{Line: 0, LinePos: 17, Pos: 17, File: "test.apl"},
+												},
+											},
+											Y: &expr.Value{
+												V: &values.Int{V: 3},
+												Source: TokenSource{
+													Token{Line: 0, LinePos: 21, Pos: 21, File: "test.apl"},
+												},
+											},
+										},
+									},
+									&expr.Unary{
+										Op: "-",
+										Source: TokenSource{
+											Token{Line: 0, LinePos: 24, Pos: 24, File: "test.apl"},
+										},
+										X: &expr.Ident{
+											Nam: "x",
+											Source: TokenSource{
+												Token{Line: 0, LinePos: 25, Pos: 25, File: "test.apl"},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:   "params_trailing_comma",
+			input:  "func f() { g(1,); }",
+			output: nil,
+			err:    "error at pos 15 ()): expected constant value",
+		},
+		{
+			name:   "malformed_float",
+			input:  "func f() { g(1.2.3); }",
+			output: nil,
+			err:    "error at pos 13 (1.2.3): invalid float constant: invalid syntax",
+		},
+		{
+			name:   "int_out_of_range",
+			input:  "func f() { g(99999999999999999999); }",
+			output: nil,
+			err:    "error at pos 13 (99999999999999999999): invalid int constant: value out of range",
+		},
+		{
+			name:   "else_without_block",
+			input:  "func f() { if true {} else g(); }",
+			output: nil,
+			err:    "error at pos 27 (g): did not expect TokenText",
+		},
 		{
 			name:   "import_missing_semicolon",
 			input:  "import foo import bar;",
@@ -124,7 +261,7 @@ func hello(int i, string x) bool {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			l := NewLexer("test.apl", strings.NewReader(tc.input))
-			p := NewParser(l.Tokens())
+			p := NewParser(l)
 			file, err := p.Do()
 			if err != nil {
 				if tc.err == "" {
@@ -141,3 +278,59 @@ func hello(int i, string x) bool {
 		})
 	}
 }
+
+func TestGettoken(t *testing.T) {
+	gt := &gettoken{lexer: NewLexer("test.apl", strings.NewReader("a // c\nb c;"))}
+	lit := func(tok Token, err error) string {
+		if err != nil {
+			return err.Error()
+		}
+		return string(tok.Lit)
+	}
+	var got []string
+	got = append(got, lit(gt.peek(2)), lit(gt.peek(3)), lit(gt.get()), lit(gt.get()))
+	gt.unread()
+	gt.unread()
+	got = append(got, lit(gt.get()), lit(gt.peek(0)), lit(gt.get()), lit(gt.get()), lit(gt.get()), lit(gt.get()))
+	gt.unread()
+	gt.unread()
+	got = append(got, lit(gt.get()))
+	want := "c,;,a,b,a,b,b,c,;,unexpected eof,;"
+	if strings.Join(got, ",") != want {
+		t.Errorf("expected %s, got %s", want, strings.Join(got, ","))
+	}
+	if len(gt.comments) != 1 || string(gt.comments[0].Lit) != "// c" {
+		t.Errorf("expected comment // c, got %v", gt.comments)
+	}
+}
+
+// benchSource returns a source file with n func declarations.
+func benchSource(n int) string {
+	var b strings.Builder
+	b.WriteString("import lib;\n\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, `// f%d computes things.
+func f%d(int x, string s) int {
+  if x <= 1 && !(s == "abc") {
+    lib.g(x, "text", 1.5);
+    return x * 2 + 1;
+  } else if x > 100 {
+    return f%d(x - 1, s) %% 7;
+  }
+  return -x;
+}
+
+`, i, i, i)
+	}
+	return b.String()
+}
+
+func BenchmarkParser(b *testing.B) {
+	src := benchSource(1000)
+	b.SetBytes(int64(len(src)))
+	for i := 0; i < b.N; i++ {
+		if _, err := NewParser(NewLexer("bench.apl", strings.NewReader(src))).Do(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -1,7 +1,6 @@
 package parser
 
 import (
-	"ast/expr"
 	"ast/statement"
 )
 
@@ -32,21 +31,29 @@ func (p *P) parseStatement() (statement.Statement, error) {
 		p.tokens.unread()
 		return p.parseReturnStmt()
 	}
+	if tok.Typ == TokenIf {
+		p.tokens.unread()
+		return p.parseIf()
+	}
 	if tok.Typ != TokenText {
 		return nil, p.errf(tok, "expected identifier")
 	}
+	name, err := p.parseName(tok)
+	if err != nil {
+		return nil, err
+	}
 	next, err := p.tokens.get()
 	if err != nil {
 		return nil, err
 	}
 	p.tokens.unread()
 	if next.Typ == TokenParensOpen {
-		return p.parseFnCall(string(tok.Lit), TokenSource{tok})
+		return p.parseFnCall(name, TokenSource{tok})
 	}
 	if next.Typ == TokenAssign {
-		panic("assignment not yet implemented")
+		return nil, p.errf(next, "assignments are not supported")
 	}
-	panic("parse error - did you try to declare a value?")
+	return nil, p.errf(next, "expected call of %s", name)
 }
 
 func (p *P) parseReturnStmt() (*statement.Return, error) {
@@ -69,22 +76,7 @@ func (p *P) parseReturnStmt() (*statement.Return, error) {
 }
 
 func (p *P) parseFnCall(name string, src TokenSource) (*statement.FnCall, error) {
-	_, _, err := p.consume(TokenParensOpen)
-	if err != nil {
-		return nil, err
-	}
-	var params []expr.Expr
-	for {
-		expr, err := p.parseExpr()
-		if err != nil {
-			return nil, err
-		}
-		if expr == nil {
-			break
-		}
-		params = append(params, expr)
-	}
-	_, _, err = p.consume(TokenParensClose)
+	params, err := p.parseParams()
 	if err != nil {
 		return nil, err
 	}
@@ -98,3 +90,65 @@ func (p *P) parseFnCall(name string, src TokenSource) (*statement.FnCall, error)
 		Params: params,
 	}, nil
 }
+
+func (p *P) parseIf() (*statement.If, error) {
+	_, tok, err := p.consume(TokenIf)
+	if err != nil {
+		return nil, err
+	}
+	cond, err := p.parseBinary(1)
+	if err != nil {
+		return nil, err
+	}
+	then, err := p.parseBlock()
+	if err != nil {
+		return nil, err
+	}
+	stmt := &statement.If{
+		Source: TokenSource{tok},
+		Cond:   cond,
+		Then:   then,
+	}
+	next, err := p.tokens.get()
+	if err != nil {
+		return nil, err
+	}
+	if next.Typ != TokenElse {
+		p.tokens.unread()
+		return stmt, nil
+	}
+	next, err = p.tokens.get()
+	if err != nil {
+		return nil, err
+	}
+	p.tokens.unread()
+	if next.Typ == TokenIf {
+		stmt.Else, err = p.parseIf()
+	} else {
+		stmt.Else, err = p.parseBlock()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+func (p *P) parseBlock() (*statement.Block, error) {
+	_, open, err := p.consume(TokenBraceOpen)
+	if err != nil {
+		return nil, err
+	}
+	stmts, err := p.parseStatements()
+	if err != nil {
+		return nil, err
+	}
+	_, end, err := p.consume(TokenBraceClose)
+	if err != nil {
+		return nil, err
+	}
+	return &statement.Block{
+		Source:     TokenSource{open},
+		Statements: stmts,
+		End:        TokenSource{end},
+	}, nil
+}
@@ -2,6 +2,7 @@ package parser
 
 import (
 	"strconv"
+	"strings"
 
 	"values"
 )
@@ -13,10 +14,17 @@ func (p *P) toValue(tok Token) (values.Value, error) {
 	if tok.Typ != TokenText {
 		return nil, p.errf(tok, "expected constant value")
 	}
+	if '0' <= tok.Lit[0] && tok.Lit[0] <= '9' && strings.ContainsRune(string(tok.Lit), '.') {
+		v, err := strconv.ParseFloat(string(tok.Lit), 64)
+		if err != nil {
+			return nil, p.errf(tok, "invalid float constant: %v", err.(*strconv.NumError).Err)
+		}
+		return &values.Float{V: v}, nil
+	}
 	if '0' <= tok.Lit[0] && tok.Lit[0] <= '9' {
 		v, err := strconv.Atoi(string(tok.Lit))
 		if err != nil {
-			return nil, err
+			return nil, p.errf(tok, "invalid int constant: %v", err.(*strconv.NumError).Err)
 		}
 		return &values.Int{V: v}, nil
 	}
@@ -28,3 +36,10 @@ func (p *P) toValue(tok Token) (values.Value, error) {
 	}
 	return nil, p.errf(tok, "could not convert to any value")
 }
+
+// isLiteral reports whether the text token tok is a constant value rather
+// than a name.
+func isLiteral(tok Token) bool {
+	lit := string(tok.Lit)
+	return lit == "true" || lit == "false" || len(lit) > 0 && '0' <= lit[0] && lit[0] <= '9'
+}
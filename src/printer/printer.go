@@ -0,0 +1,358 @@
+// Package printer implements printing of AST nodes as canonically formatted
+// apl source.
+package printer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"ast"
+	"ast/expr"
+	"ast/source"
+	"ast/statement"
+	"parser"
+	"values"
+)
+
+const indentStr = "    "
+
+// printer accumulates output lines. Comments are interleaved with the nodes
+// they precede, based on their source position.
+type printer struct {
+	lines    []string
+	indent   int
+	lastLine int // Source line of the last emitted line, or -1.
+	comments []*ast.Comment
+
+	// Line of the statement being printed, see start.
+	cur     strings.Builder
+	curLine int  // Source line of the last token written to cur.
+	broken  bool // Whether comments broke the statement across lines.
+}
+
+// Fprint "pretty-prints" the file f to w in canonical apl style: imports one
+// per line, one blank line between sections and declarations, and bodies
+// indented by four spaces. All comments in f are preserved.
+func Fprint(w io.Writer, f *ast.File) error {
+	p := &printer{lastLine: -1, comments: f.Comments}
+	p.file(f)
+	var buf bytes.Buffer
+	for _, line := range p.lines {
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// Source formats src in canonical apl style and returns the result. The name
+// is used for positional information in parse errors.
+func Source(name string, src []byte) ([]byte, error) {
+	l := parser.NewLexer(name, bytes.NewReader(src))
+	f, err := parser.NewParser(l).Do()
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := Fprint(&buf, f); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// emit writes a line of text at the current indentation. srcLine is the line
+// in the original source that produced it.
+func (p *printer) emit(srcLine int, text string) {
+	p.lines = append(p.lines, strings.Repeat(indentStr, p.indent)+text)
+	p.lastLine = srcLine
+}
+
+// blank writes an empty line, collapsing repeated blank lines.
+func (p *printer) blank() {
+	if len(p.lines) == 0 || p.lines[len(p.lines)-1] == "" {
+		return
+	}
+	p.lines = append(p.lines, "")
+	p.lastLine = -1
+}
+
+// flush emits all pending comments before pos. Comments on the same source
+// line as the last emitted line are appended to it.
+func (p *printer) flush(pos int) {
+	for len(p.comments) > 0 && p.comments[0].Pos() < pos {
+		c := p.comments[0]
+		p.comments = p.comments[1:]
+		if c.Line() == p.lastLine {
+			p.lines[len(p.lines)-1] += " " + c.Text
+			continue
+		}
+		p.emit(c.Line(), c.Text)
+	}
+}
+
+// flushTrailing emits pending comments before pos that trail the last
+// emitted line.
+func (p *printer) flushTrailing(pos int) {
+	for len(p.comments) > 0 && p.comments[0].Pos() < pos && p.comments[0].Line() == p.lastLine {
+		p.lines[len(p.lines)-1] += " " + p.comments[0].Text
+		p.comments = p.comments[1:]
+	}
+}
+
+// start begins the line of a statement at srcLine. Its text is written to
+// the line with write and token, and emitted with finish.
+func (p *printer) start(srcLine int) {
+	p.cur.Reset()
+	p.curLine = srcLine
+}
+
+// write appends text to the line of the statement.
+func (p *printer) write(text string) {
+	p.cur.WriteString(text)
+}
+
+// token appends the text of the token at s to the line of the statement.
+// Pending comments before s end the line first, as by flush, and the rest
+// of the statement continues on the next line, indented once more.
+func (p *printer) token(s source.Source, text string) {
+	if len(p.comments) > 0 && p.comments[0].Pos() < pos(s) {
+		p.endLine()
+		if !p.broken {
+			p.indent++
+			p.broken = true
+		}
+		p.flush(pos(s))
+	}
+	if l := line(s); l > p.curLine {
+		p.curLine = l
+	}
+	p.write(text)
+}
+
+// finish emits the last line of the statement.
+func (p *printer) finish() {
+	p.endLine()
+	if p.broken {
+		p.indent--
+		p.broken = false
+	}
+}
+
+func (p *printer) endLine() {
+	p.emit(p.curLine, strings.TrimRight(p.cur.String(), " "))
+	p.cur.Reset()
+}
+
+// pos returns the position of s, or -1 if s is unknown.
+func pos(s source.Source) int {
+	if s == nil {
+		return -1
+	}
+	return s.Pos()
+}
+
+// line returns the line of s, or -1 if s is unknown.
+func line(s source.Source) int {
+	if s == nil {
+		return -1
+	}
+	return s.Line()
+}
+
+func (p *printer) file(f *ast.File) {
+	for _, imp := range f.Imports {
+		p.flush(pos(imp))
+		p.emit(line(imp), importString(imp))
+	}
+	for i, decl := range f.Decls {
+		if i > 0 || len(f.Imports) > 0 {
+			p.flushTrailing(pos(decl))
+			p.blank()
+		}
+		p.flush(pos(decl))
+		p.decl(decl)
+	}
+	p.flush(math.MaxInt32)
+}
+
+func importString(imp *statement.Import) string {
+	s := "import " + imp.Name
+	if imp.Alias != "" {
+		s += " as " + imp.Alias
+	}
+	if len(imp.Names) > 0 {
+		var names []string
+		for _, name := range imp.Names {
+			names = append(names, name.Nam)
+		}
+		s += " { " + strings.Join(names, ", ") + " }"
+	}
+	return s + ";"
+}
+
+func (p *printer) decl(decl ast.Decl) {
+	switch d := decl.(type) {
+	case *ast.FnDecl:
+		p.fnDecl(d)
+	default:
+		panic(fmt.Sprintf("printer: unexpected decl type %T", d))
+	}
+}
+
+func (p *printer) fnDecl(f *ast.FnDecl) {
+	var args []string
+	for _, arg := range f.Args {
+		args = append(args, fmt.Sprintf("%s %s", arg.Typ, arg.Nam))
+	}
+	header := fmt.Sprintf("func %s(%s)", f.Nam, strings.Join(args, ", "))
+	if f.Export {
+		header = "export " + header
+	}
+	if f.Return != nil {
+		header += " " + f.Return.Typ
+	}
+	end := pos(f.End)
+	if len(f.Statements) == 0 && !p.hasComments(pos(f), end) {
+		p.emit(line(f.End), header+" {}")
+		return
+	}
+	p.emit(line(f), header+" {")
+	p.block(f.Statements, f.End)
+}
+
+// block emits the statements of a block, followed by its closing brace.
+func (p *printer) block(stmts []statement.Statement, end source.Source) {
+	p.indent++
+	for _, stmt := range stmts {
+		p.flush(pos(stmt))
+		p.stmt(stmt)
+	}
+	p.flush(pos(end))
+	p.indent--
+	p.emit(line(end), "}")
+}
+
+// hasComments reports whether any pending comment lies between start and
+// end.
+func (p *printer) hasComments(start, end int) bool {
+	for _, c := range p.comments {
+		if c.Pos() > start && c.Pos() < end {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *printer) stmt(stmt statement.Statement) {
+	switch s := stmt.(type) {
+	case *statement.FnCall:
+		p.start(line(s))
+		p.call(s.Nam, s.Params)
+		p.write(";")
+		p.finish()
+	case *statement.Return:
+		p.start(line(s))
+		p.write("return")
+		if s.Expr != nil {
+			p.write(" ")
+			p.expr(s.Expr, 0)
+		}
+		p.write(";")
+		p.finish()
+	case *statement.If:
+		p.ifStmt(s, "if")
+	default:
+		panic(fmt.Sprintf("printer: unexpected statement type %T", s))
+	}
+}
+
+// ifStmt emits an if statement, with its first line starting with prefix.
+// The closing brace of a branch followed by else shares a line with it,
+// unless comments lie between the two.
+func (p *printer) ifStmt(s *statement.If, prefix string) {
+	p.start(line(s))
+	p.write(prefix + " ")
+	p.expr(s.Cond, 0)
+	p.write(" {")
+	p.finish()
+	p.block(s.Then.Statements, s.Then.End)
+	if s.Else == nil {
+		return
+	}
+	if p.hasComments(pos(s.Then.End), pos(s.Else)) {
+		p.flush(pos(s.Else))
+		prefix = "else"
+	} else {
+		p.lines = p.lines[:len(p.lines)-1]
+		prefix = "} else"
+	}
+	switch e := s.Else.(type) {
+	case *statement.If:
+		p.ifStmt(e, prefix+" if")
+	case *statement.Block:
+		p.emit(line(e), prefix+" {")
+		p.block(e.Statements, e.End)
+	}
+}
+
+// call writes a call of name with params, whose name has been flushed.
+func (p *printer) call(name string, params []expr.Expr) {
+	p.write(name + "(")
+	for i, param := range params {
+		if i > 0 {
+			p.write(", ")
+		}
+		p.expr(param, 0)
+	}
+	p.write(")")
+}
+
+// expr writes e, parenthesized if e is a binary expression binding less
+// tightly than an operator of precedence prec.
+func (p *printer) expr(e expr.Expr, prec int) {
+	switch e := e.(type) {
+	case *expr.Value:
+		p.token(e, valueString(e.V))
+	case *expr.Ident:
+		p.token(e, e.Nam)
+	case *expr.Call:
+		p.token(e, "")
+		p.call(e.Nam, e.Params)
+	case *expr.Unary:
+		p.token(e, e.Op)
+		p.expr(e.X, math.MaxInt32)
+	case *expr.Binary:
+		opPrec := expr.Precedence(e.Op)
+		if opPrec < prec {
+			p.write("(")
+		}
+		p.expr(e.X, opPrec)
+		p.write(" ")
+		p.token(e, e.Op+" ")
+		p.expr(e.Y, opPrec+1)
+		if opPrec < prec {
+			p.write(")")
+		}
+	default:
+		panic(fmt.Sprintf("printer: unexpected expression type %T", e))
+	}
+}
+
+func valueString(v values.Value) string {
+	switch v := v.(type) {
+	case *values.String:
+		return `"` + v.V + `"`
+	case *values.Float:
+		s := strconv.FormatFloat(v.V, 'f', -1, 64)
+		if !strings.Contains(s, ".") {
+			s += ".0"
+		}
+		return s
+	default:
+		return v.String()
+	}
+}
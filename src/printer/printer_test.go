@@ -0,0 +1,207 @@
+package printer
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+
+	"ast"
+	"parser"
+)
+
+func parse(t *testing.T, src string) *ast.File {
+	l := parser.NewLexer("test.apl", strings.NewReader(src))
+	f, err := parser.NewParser(l).Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+var sourceRE = regexp.MustCompile(`@<[^>]*>`)
+
+// stripped returns the debug form of f without any positional information.
+func stripped(f *ast.File) string {
+	f.Comments = nil
+	return sourceRE.ReplaceAllString(f.String(), "")
+}
+
+func TestSource(t *testing.T) {
+	testCases := []struct {
+		name   string
+		input  string
+		output string
+	}{
+		{
+			name: "normal",
+			input: `
+import foo;   import bar;
+func hello(int i,string x)bool{
+  do(  1 );
+      return false;}
+export func main() {
+}
+`,
+			output: `import foo;
+import bar;
+
+func hello(int i, string x) bool {
+    do(1);
+    return false;
+}
+
+export func main() {}
+`,
+		},
+		{
+			name: "imports",
+			input: `import a/util   as u;  import math {sqrt,pow};
+import b/util as v {
+  Lib
+};
+func main() { u.Lib(1); sqrt(2); }
+`,
+			output: `import a/util as u;
+import math { sqrt, pow };
+import b/util as v { Lib };
+
+func main() {
+    u.Lib(1);
+    sqrt(2);
+}
+`,
+		},
+		{
+			name: "exprs",
+			input: `func f(int x) int {
+  if (x==1) {return x*(2+3);} else if !(x<0) && x>1 { g(-x, "a"+"b"); } else { return (x - 1) - 2; }
+  return x - (1 - 2);
+}
+`,
+			output: `func f(int x) int {
+    if x == 1 {
+        return x * (2 + 3);
+    } else if !(x < 0) && x > 1 {
+        g(-x, "a" + "b");
+    } else {
+        return x - 1 - 2;
+    }
+    return x - (1 - 2);
+}
+`,
+		},
+		{
+			name:   "floats",
+			input:  "func f() { g(2.0, 0.50, 1.25e0); }\n",
+			output: "func f() {\n    g(2.0, 0.5, 1.25);\n}\n",
+		},
+		{
+			name: "comments",
+			input: `// Package comment.
+import foo; // foo is needed.
+
+// hello says hello.
+func hello() { // Trailing the brace.
+  // Before the call.
+  print("hello world"); // After the call.
+  // Before the brace.
+}
+// Before main.
+func main() {
+  // Only a comment.
+} // After main.
+// At the end.
+`,
+			output: `// Package comment.
+import foo; // foo is needed.
+
+// hello says hello.
+func hello() { // Trailing the brace.
+    // Before the call.
+    print("hello world"); // After the call.
+    // Before the brace.
+}
+
+// Before main.
+func main() {
+    // Only a comment.
+} // After main.
+// At the end.
+`,
+		},
+		{
+			name: "comments_in_statements",
+			input: `func f(int x) int {
+  foo(1, // one
+    2);
+  g(1,
+    // Before two.
+    2); // After the call.
+  return x + // x
+    1;
+}
+`,
+			output: `func f(int x) int {
+    foo(1, // one
+        2);
+    g(1,
+        // Before two.
+        2); // After the call.
+    return x + // x
+        1;
+}
+`,
+		},
+		{
+			name: "comments_before_else",
+			input: `func f(int x) {
+  if x == 1 {
+    g(1);
+  } // trailing
+  else if x == 2 {
+    g(2);
+  }
+  // Before else.
+  else {
+    g(3);
+  }
+}
+`,
+			output: `func f(int x) {
+    if x == 1 {
+        g(1);
+    } // trailing
+    else if x == 2 {
+        g(2);
+    }
+    // Before else.
+    else {
+        g(3);
+    }
+}
+`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := Source("test.apl", []byte(tc.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(out) != tc.output {
+				t.Errorf("expected\n%s\ngot\n%s", tc.output, out)
+			}
+			again, err := Source("test.apl", out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(out, again) {
+				t.Errorf("output not idempotent. first\n%s\nsecond\n%s", out, again)
+			}
+			if s1, s2 := stripped(parse(t, tc.input)), stripped(parse(t, string(out))); s1 != s2 {
+				t.Errorf("re-parsed AST differs. expected\n%s\ngot\n%s", s1, s2)
+			}
+		})
+	}
+}
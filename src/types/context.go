@@ -2,11 +2,44 @@ package types
 
 import (
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
 )
 
-// Context is the type registry.
+// Context is the type registry. All packages of a program share one
+// registry, in which each package has its own namespace. Packages declare
+// and look up names through views of the registry, see Package and File.
+// The registry is safe for concurrent use, so that packages may be checked in
+// parallel. File and block views are not, and belong to the checker of their
+// file.
 type Context struct {
-	m map[string]Type
+	u   *universe
+	pkg string // Package of this view; empty for the root view.
+
+	// Set on file views only.
+	imports  map[string]string // Package paths by qualifier.
+	selected map[string]string // Package paths by selectively imported name.
+
+	// Set on block views only.
+	vars   map[string]Type // Variables declared in this block.
+	parent *Context        // Enclosing block, or nil for a func body.
+	ret    Type            // Return type of the enclosing func.
+}
+
+// universe holds the declarations of all packages.
+type universe struct {
+	mu       sync.RWMutex // Guards the maps and the decls in them.
+	builtins map[string]*decl
+	pkgs     map[string]map[string]*decl // Declarations by package path.
+}
+
+// decl is a registered type or func.
+type decl struct {
+	t        Type
+	exported bool
 }
 
 // NewContext returns a new type registry with builtin types filled.
@@ -17,29 +50,265 @@ func NewContext() *Context {
 		}
 	}
 	c := &Context{
-		m: make(map[string]Type),
+		u: &universe{
+			builtins: make(map[string]*decl),
+			pkgs:     make(map[string]map[string]*decl),
+		},
 	}
 	chk(c.Add("int", &Int{}))
 	chk(c.Add("bool", &Bool{}))
 	chk(c.Add("string", &String{}))
+	chk(c.Add("float", &Float{}))
 	return c
 }
 
+// Package returns a view of the registry for the package at an import path.
+// Types added through the view are declared by that package. Types added
+// through the root view returned by NewContext are builtins, visible
+// everywhere.
+func (c *Context) Package(path string) *Context {
+	return &Context{u: c.u, pkg: path}
+}
+
+// File returns a view for a single file of this view's package. Imports are
+// registered per file.
+func (c *Context) File() *Context {
+	return &Context{
+		u:        c.u,
+		pkg:      c.pkg,
+		imports:  make(map[string]string),
+		selected: make(map[string]string),
+	}
+}
+
+// Func returns a view for the body of a func declared in this file view,
+// returning values of type ret, or nothing if ret is nil.
+func (c *Context) Func(ret Type) *Context {
+	v := *c
+	v.vars = make(map[string]Type)
+	v.parent = nil
+	v.ret = ret
+	return &v
+}
+
+// Block returns a view for a block nested in this func body or block.
+// Variables declared in the block are not visible outside of it.
+func (c *Context) Block() *Context {
+	v := *c
+	v.vars = make(map[string]Type)
+	v.parent = c
+	return &v
+}
+
+// AddVar declares a variable in this block view. Returns an error if the
+// variable is already declared in the same block.
+func (c *Context) AddVar(name string, t Type) error {
+	if _, ok := c.vars[name]; ok {
+		return fmt.Errorf("%s redeclared in this block", name)
+	}
+	c.vars[name] = t
+	return nil
+}
+
+// Var returns the type of a variable visible from this block view.
+func (c *Context) Var(name string) (Type, error) {
+	for b := c; b != nil; b = b.parent {
+		if t, ok := b.vars[name]; ok {
+			return t, nil
+		}
+	}
+	return nil, fmt.Errorf("undefined: %s", name)
+}
+
+// Return returns the return type of the func enclosing this block view, or
+// nil if it does not return anything.
+func (c *Context) Return() Type {
+	return c.ret
+}
+
+// IsExported reports whether name starts with an upper-case letter. Such
+// names are visible to other packages without the export modifier.
+func IsExported(name string) bool {
+	r, _ := utf8.DecodeRuneInString(name)
+	return unicode.IsUpper(r)
+}
+
+// scope returns the declarations of this view's package, which are nil if
+// it has not declared anything yet. The caller must hold c.u.mu.
+func (c *Context) scope() map[string]*decl {
+	if c.pkg == "" {
+		return c.u.builtins
+	}
+	return c.u.pkgs[c.pkg]
+}
+
 // Add adds the given type to the registry. Returns an error if it conflicts
-// with an existing type.
+// with an existing type or import.
 func (c *Context) Add(name string, t Type) error {
-	if prev, ok := c.m[name]; ok {
-		return fmt.Errorf("type %s already declared as %v", name, prev)
+	c.u.mu.Lock()
+	defer c.u.mu.Unlock()
+	scope := c.scope()
+	if scope == nil {
+		scope = make(map[string]*decl)
+		c.u.pkgs[c.pkg] = scope
+	}
+	if prev, ok := scope[name]; ok {
+		return fmt.Errorf("type %s already declared as %v", name, prev.t)
+	}
+	if path, ok := c.imports[name]; ok {
+		return fmt.Errorf("%s already declared as import of %s", name, path)
+	}
+	if path, ok := c.selected[name]; ok {
+		return fmt.Errorf("%s already imported from %s", name, path)
 	}
-	c.m[name] = t
+	scope[name] = &decl{t: t, exported: c.pkg == "" || IsExported(name)}
 	return nil
 }
 
-// Get retrieves the type associated with the given name. If no type exists,
-// returns ErrTypeNotFound.
+// Remove drops all declarations of this view's package, so that it can be
+// checked again. File views importing the package must be discarded too.
+// Builtins cannot be removed.
+func (c *Context) Remove() {
+	c.u.mu.Lock()
+	defer c.u.mu.Unlock()
+	delete(c.u.pkgs, c.pkg)
+}
+
+// Export marks a type added through this view as visible to other packages.
+func (c *Context) Export(name string) error {
+	c.u.mu.Lock()
+	defer c.u.mu.Unlock()
+	d, ok := c.scope()[name]
+	if !ok {
+		return fmt.Errorf("cannot export %s: not declared in module %s", name, c.pkg)
+	}
+	d.exported = true
+	return nil
+}
+
+// Import makes the exported names of the package at path available through
+// the file view as qualifier.name. Returns an error if the qualifier
+// collides with a declaration or another import.
+func (c *Context) Import(path, qualifier string) error {
+	c.u.mu.RLock()
+	defer c.u.mu.RUnlock()
+	if _, ok := c.scope()[qualifier]; ok {
+		return fmt.Errorf("import %s as %s collides with declaration of %s", path, qualifier, qualifier)
+	}
+	if prev, ok := c.imports[qualifier]; ok {
+		return fmt.Errorf("import %s as %s collides with import of %s", path, qualifier, prev)
+	}
+	c.imports[qualifier] = path
+	return nil
+}
+
+// Select makes the exported name of the package at path available through
+// the file view unqualified. Returns an error if the package does not declare
+// name, or if name collides with a declaration or another import.
+func (c *Context) Select(path, name string) error {
+	c.u.mu.RLock()
+	defer c.u.mu.RUnlock()
+	if _, err := c.lookup(path, name); err != nil {
+		return err
+	}
+	if _, ok := c.scope()[name]; ok {
+		return fmt.Errorf("import of %s from %s collides with declaration of %s", name, path, name)
+	}
+	if prev, ok := c.selected[name]; ok {
+		return fmt.Errorf("import of %s from %s collides with import from %s", name, path, prev)
+	}
+	c.selected[name] = path
+	return nil
+}
+
+// lookup retrieves an exported name of the package at path. The caller must
+// hold c.u.mu.
+func (c *Context) lookup(path, name string) (*decl, error) {
+	d, ok := c.u.pkgs[path][name]
+	if !ok {
+		return nil, fmt.Errorf("%s not declared in module %s", name, path)
+	}
+	if path != c.pkg && !d.exported {
+		return nil, fmt.Errorf("cannot refer to unexported %s declared in module %s", name, path)
+	}
+	return d, nil
+}
+
+// Resolve returns the path of the package declaring the given name, as seen
+// from this view, and the name within that package. The path is empty for
+// builtins. A qualified name "q.name" refers to name in the package imported
+// as q. Unqualified names refer to declarations of this view's package,
+// selectively imported names and builtins, in that order.
+func (c *Context) Resolve(name string) (string, string, error) {
+	c.u.mu.RLock()
+	defer c.u.mu.RUnlock()
+	return c.resolve(name)
+}
+
+// resolve is Resolve with c.u.mu held.
+func (c *Context) resolve(name string) (string, string, error) {
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		qualifier, local := name[:i], name[i+1:]
+		path, ok := c.imports[qualifier]
+		if !ok {
+			return "", "", fmt.Errorf("unknown import: %s", qualifier)
+		}
+		if _, err := c.lookup(path, local); err != nil {
+			return "", "", err
+		}
+		return path, local, nil
+	}
+	if _, ok := c.scope()[name]; ok {
+		return c.pkg, name, nil
+	}
+	if path, ok := c.selected[name]; ok {
+		return path, name, nil
+	}
+	if _, ok := c.u.builtins[name]; ok {
+		return "", name, nil
+	}
+	return "", "", fmt.Errorf("unknown type or func: %s", name)
+}
+
+// Get retrieves the type associated with the given name, see Resolve. If no
+// type exists, or it is not visible from this view, returns an error.
 func (c *Context) Get(name string) (Type, error) {
-	if t, ok := c.m[name]; ok {
-		return t, nil
+	c.u.mu.RLock()
+	defer c.u.mu.RUnlock()
+	path, local, err := c.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		return c.u.builtins[local].t, nil
+	}
+	return c.u.pkgs[path][local].t, nil
+}
+
+// Names returns the sorted names of all types and funcs visible from this
+// view. Exported names of imported packages are qualified.
+func (c *Context) Names() []string {
+	c.u.mu.RLock()
+	defer c.u.mu.RUnlock()
+	var names []string
+	for name := range c.u.builtins {
+		names = append(names, name)
+	}
+	if c.pkg != "" {
+		for name := range c.scope() {
+			names = append(names, name)
+		}
+	}
+	for name := range c.selected {
+		names = append(names, name)
+	}
+	for qualifier, path := range c.imports {
+		for name, d := range c.u.pkgs[path] {
+			if d.exported {
+				names = append(names, qualifier+"."+name)
+			}
+		}
 	}
-	return nil, fmt.Errorf("unknown type or func: %s", name)
+	sort.Strings(names)
+	return names
 }
@@ -1,5 +1,9 @@
 package types
 
+import (
+	"strings"
+)
+
 // Type represents a type in the language.
 type Type interface {
 	Equals(Type) bool
@@ -47,10 +51,109 @@ func (s *String) String() string {
 	return "type<string>"
 }
 
+// Float is a floating-point type.
+type Float struct {
+}
+
+// Equals returns true if t is Float.
+func (f *Float) Equals(t Type) bool {
+	_, ok := t.(*Float)
+	return ok
+}
+
+func (f *Float) String() string {
+	return "type<float>"
+}
+
+// List is the type of a list of elements of one type.
+type List struct {
+	Elem Type
+}
+
+// Equals returns true if t is List with the same element type.
+func (l *List) Equals(t Type) bool {
+	l2, ok := t.(*List)
+	return ok && l.Elem.Equals(l2.Elem)
+}
+
+func (l *List) String() string {
+	return "type<" + spell(l) + ">"
+}
+
+// Map is the type of a map from keys of one type to elements of another.
+type Map struct {
+	Key  Type
+	Elem Type
+}
+
+// Equals returns true if t is Map with the same key and element types.
+func (m *Map) Equals(t Type) bool {
+	m2, ok := t.(*Map)
+	return ok && m.Key.Equals(m2.Key) && m.Elem.Equals(m2.Elem)
+}
+
+func (m *Map) String() string {
+	return "type<" + spell(m) + ">"
+}
+
+// Struct is the type of a record of named fields.
+type Struct struct {
+	Fields []*Field
+}
+
+// Field is a field of a Struct.
+type Field struct {
+	Name string
+	Type Type
+}
+
+// Equals returns true if t is Struct with the same field names and types in
+// the same order.
+func (s *Struct) Equals(t Type) bool {
+	s2, ok := t.(*Struct)
+	if !ok || len(s.Fields) != len(s2.Fields) {
+		return false
+	}
+	for i, f := range s.Fields {
+		if f.Name != s2.Fields[i].Name || !f.Type.Equals(s2.Fields[i].Type) {
+			return false
+		}
+	}
+	return true
+}
+
+// Field returns the index of the field with the given name, or -1.
+func (s *Struct) Field(name string) int {
+	for i, f := range s.Fields {
+		if f.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *Struct) String() string {
+	return "type<" + spell(s) + ">"
+}
+
+// Any is the type of builtin func args that accept values of every type.
+type Any struct {
+}
+
+// Equals always returns true.
+func (a *Any) Equals(t Type) bool {
+	return true
+}
+
+func (a *Any) String() string {
+	return "type<any>"
+}
+
 // Func is a function type.
 type Func struct {
-	Args   []Type
-	Return Type
+	Args     []Type
+	Return   Type // Nil if the func does not return anything.
+	Variadic bool // The last arg may be repeated zero or more times. Ignored without args.
 }
 
 // Equals returns true if t is Func with the same args and return type.
@@ -59,7 +162,7 @@ func (f *Func) Equals(t Type) bool {
 	if !ok {
 		return false
 	}
-	if len(f.Args) != len(f2.Args) {
+	if len(f.Args) != len(f2.Args) || f.Variadic != f2.Variadic {
 		return false
 	}
 	for i, arg := range f.Args {
@@ -67,9 +170,71 @@ func (f *Func) Equals(t Type) bool {
 			return false
 		}
 	}
+	if f.Return == nil || f2.Return == nil {
+		return f.Return == nil && f2.Return == nil
+	}
 	return f.Return.Equals(f2.Return)
 }
 
+// Arg returns the type of the i-th param of a call, or nil if the func does
+// not take that many params.
+func (f *Func) Arg(i int) Type {
+	if f.Variadic && len(f.Args) > 0 && i >= len(f.Args)-1 {
+		return f.Args[len(f.Args)-1]
+	}
+	if i >= len(f.Args) {
+		return nil
+	}
+	return f.Args[i]
+}
+
 func (f *Func) String() string {
 	return "type<func>"
 }
+
+// Signature returns the declaration of a func with this type and the given
+// name, e.g. "func foo(int, string) bool".
+func (f *Func) Signature(name string) string {
+	var args []string
+	for _, arg := range f.Args {
+		args = append(args, spell(arg))
+	}
+	if f.Variadic && len(args) > 0 {
+		args[len(args)-1] += "..."
+	}
+	sig := "func " + name + "(" + strings.Join(args, ", ") + ")"
+	if f.Return != nil {
+		sig += " " + spell(f.Return)
+	}
+	return sig
+}
+
+// spell returns how t is written in source.
+func spell(t Type) string {
+	switch t := t.(type) {
+	case *Int:
+		return "int"
+	case *Bool:
+		return "bool"
+	case *String:
+		return "string"
+	case *Float:
+		return "float"
+	case *List:
+		return "[]" + spell(t.Elem)
+	case *Map:
+		return "map[" + spell(t.Key) + "]" + spell(t.Elem)
+	case *Struct:
+		var fields []string
+		for _, f := range t.Fields {
+			fields = append(fields, f.Name+" "+spell(f.Type))
+		}
+		return "struct{" + strings.Join(fields, "; ") + "}"
+	case *Any:
+		return "any"
+	case *Func:
+		return strings.TrimPrefix(t.Signature(""), "func ")
+	default:
+		return "?"
+	}
+}
@@ -0,0 +1,309 @@
+package values
+
+import (
+	"fmt"
+	"reflect"
+	"unicode"
+	"unicode/utf8"
+
+	"types"
+)
+
+// TypeOf returns the apl type of Go values of type t. Go integers map to int,
+// floating-point numbers to float, bools to bool and strings to string.
+// Slices map to lists and maps to maps, whose keys must not be slices or
+// maps. Structs map to structs of their exported fields, named by their
+// "apl" tag or else by the Go field name; fields tagged `apl:"-"` are
+// skipped. Pointers map to the type they point to.
+func TypeOf(t reflect.Type) (types.Type, error) {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &types.Int{}, nil
+	case reflect.Float32, reflect.Float64:
+		return &types.Float{}, nil
+	case reflect.Bool:
+		return &types.Bool{}, nil
+	case reflect.String:
+		return &types.String{}, nil
+	case reflect.Ptr:
+		return TypeOf(t.Elem())
+	case reflect.Slice:
+		elem, err := TypeOf(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &types.List{Elem: elem}, nil
+	case reflect.Map:
+		switch t.Key().Kind() {
+		case reflect.Slice, reflect.Map:
+			return nil, fmt.Errorf("unsupported map key type %v", t.Key())
+		}
+		key, err := TypeOf(t.Key())
+		if err != nil {
+			return nil, err
+		}
+		elem, err := TypeOf(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &types.Map{Key: key, Elem: elem}, nil
+	case reflect.Struct:
+		s := &types.Struct{}
+		for _, f := range fields(t) {
+			typ, err := TypeOf(t.Field(f.index).Type)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %v", t.Field(f.index).Name, err)
+			}
+			s.Fields = append(s.Fields, &types.Field{Name: f.name, Type: typ})
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("unsupported type %v", t)
+}
+
+// field is a Go struct field mapped to an apl struct field.
+type field struct {
+	index int
+	name  string
+}
+
+// fields returns the fields of the Go struct type t that map to apl fields.
+func fields(t reflect.Type) []field {
+	var fs []field
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("apl"); ok {
+			if tag == "-" {
+				continue
+			}
+			name = tag
+		}
+		fs = append(fs, field{index: i, name: name})
+	}
+	return fs
+}
+
+// GoType returns the Go type that ToGo converts values of type t to when
+// asked for an interface{}. Structs are converted to struct types with the
+// field names capitalized and the original names in "apl" tags.
+func GoType(t types.Type) reflect.Type {
+	switch t := t.(type) {
+	case *types.Int:
+		return reflect.TypeOf(0)
+	case *types.Float:
+		return reflect.TypeOf(0.0)
+	case *types.Bool:
+		return reflect.TypeOf(false)
+	case *types.String:
+		return reflect.TypeOf("")
+	case *types.List:
+		return reflect.SliceOf(GoType(t.Elem))
+	case *types.Map:
+		return reflect.MapOf(GoType(t.Key), GoType(t.Elem))
+	case *types.Struct:
+		var fs []reflect.StructField
+		for _, f := range t.Fields {
+			r, n := utf8.DecodeRuneInString(f.Name)
+			fs = append(fs, reflect.StructField{
+				Name: string(unicode.ToUpper(r)) + f.Name[n:],
+				Type: GoType(f.Type),
+				Tag:  reflect.StructTag(fmt.Sprintf("apl:%q", f.Name)),
+			})
+		}
+		return reflect.StructOf(fs)
+	}
+	return reflect.TypeOf((*interface{})(nil)).Elem()
+}
+
+// FromGo converts the Go value x to an apl value of type TypeOf(x). A Value
+// is returned as is.
+func FromGo(x interface{}) (Value, error) {
+	if v, ok := x.(Value); ok {
+		return v, nil
+	}
+	if x == nil {
+		return nil, fmt.Errorf("cannot convert nil")
+	}
+	typ, err := TypeOf(reflect.TypeOf(x))
+	if err != nil {
+		return nil, err
+	}
+	return fromReflect(reflect.ValueOf(x), typ)
+}
+
+func fromReflect(v reflect.Value, typ types.Type) (Value, error) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, fmt.Errorf("cannot convert nil %v", v.Type())
+		}
+		return fromReflect(v.Elem(), typ)
+	}
+	switch typ := typ.(type) {
+	case *types.Int:
+		switch v.Kind() {
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			u := v.Uint()
+			if int(u) < 0 || uint64(int(u)) != u {
+				return nil, fmt.Errorf("%d overflows int", u)
+			}
+			return &Int{V: int(u)}, nil
+		}
+		return &Int{V: int(v.Int())}, nil
+	case *types.Float:
+		return &Float{V: v.Float()}, nil
+	case *types.Bool:
+		return &Bool{V: v.Bool()}, nil
+	case *types.String:
+		return &String{V: v.String()}, nil
+	case *types.List:
+		l := &List{Typ: typ, V: make([]Value, v.Len())}
+		for i := range l.V {
+			elem, err := fromReflect(v.Index(i), typ.Elem)
+			if err != nil {
+				return nil, err
+			}
+			l.V[i] = elem
+		}
+		return l, nil
+	case *types.Map:
+		var entries []*MapEntry
+		iter := v.MapRange()
+		for iter.Next() {
+			key, err := fromReflect(iter.Key(), typ.Key)
+			if err != nil {
+				return nil, err
+			}
+			elem, err := fromReflect(iter.Value(), typ.Elem)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, &MapEntry{Key: key, Elem: elem})
+		}
+		return NewMap(typ, entries), nil
+	case *types.Struct:
+		s := &Struct{Typ: typ}
+		for i, f := range fields(v.Type()) {
+			fv, err := fromReflect(v.Field(f.index), typ.Fields[i].Type)
+			if err != nil {
+				return nil, err
+			}
+			s.Fields = append(s.Fields, fv)
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("cannot convert %v to %v", v.Type(), typ)
+}
+
+// ToGo converts v to a Go value of type t. For an interface{} type t, v is
+// converted to GoType(v.Type()). Lists convert to slices and maps to maps.
+// Structs convert to structs whose fields are matched by name as for
+// TypeOf; every field of v must have a matching Go field. Ints are checked
+// for overflow.
+func ToGo(v Value, t reflect.Type) (interface{}, error) {
+	rv, err := toReflect(v, t)
+	if err != nil {
+		return nil, err
+	}
+	return rv.Interface(), nil
+}
+
+func toReflect(v Value, t reflect.Type) (reflect.Value, error) {
+	if t.Kind() == reflect.Interface && t.NumMethod() == 0 {
+		return toReflect(v, GoType(v.Type()))
+	}
+	if t.Kind() == reflect.Ptr {
+		elem, err := toReflect(v, t.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		p := reflect.New(t.Elem())
+		p.Elem().Set(elem)
+		return p, nil
+	}
+	r := reflect.New(t).Elem()
+	switch v := v.(type) {
+	case *Int:
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if r.OverflowInt(int64(v.V)) {
+				return r, fmt.Errorf("%d overflows Go %v", v.V, t)
+			}
+			r.SetInt(int64(v.V))
+			return r, nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if v.V < 0 || r.OverflowUint(uint64(v.V)) {
+				return r, fmt.Errorf("%d overflows Go %v", v.V, t)
+			}
+			r.SetUint(uint64(v.V))
+			return r, nil
+		}
+	case *Float:
+		if t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64 {
+			r.SetFloat(v.V)
+			return r, nil
+		}
+	case *Bool:
+		if t.Kind() == reflect.Bool {
+			r.SetBool(v.V)
+			return r, nil
+		}
+	case *String:
+		if t.Kind() == reflect.String {
+			r.SetString(v.V)
+			return r, nil
+		}
+	case *List:
+		if t.Kind() == reflect.Slice {
+			r.Set(reflect.MakeSlice(t, len(v.V), len(v.V)))
+			for i, elem := range v.V {
+				e, err := toReflect(elem, t.Elem())
+				if err != nil {
+					return r, err
+				}
+				r.Index(i).Set(e)
+			}
+			return r, nil
+		}
+	case *Map:
+		if t.Kind() == reflect.Map {
+			r.Set(reflect.MakeMapWithSize(t, len(v.Entries)))
+			for _, e := range v.Entries {
+				key, err := toReflect(e.Key, t.Key())
+				if err != nil {
+					return r, err
+				}
+				elem, err := toReflect(e.Elem, t.Elem())
+				if err != nil {
+					return r, err
+				}
+				r.SetMapIndex(key, elem)
+			}
+			return r, nil
+		}
+	case *Struct:
+		if t.Kind() == reflect.Struct {
+			byName := make(map[string]int)
+			for _, f := range fields(t) {
+				byName[f.name] = f.index
+			}
+			for i, f := range v.Typ.Fields {
+				index, ok := byName[f.Name]
+				if !ok {
+					return r, fmt.Errorf("cannot convert %v to Go %v: no field %s", v.Type(), t, f.Name)
+				}
+				fv, err := toReflect(v.Fields[i], t.Field(index).Type)
+				if err != nil {
+					return r, err
+				}
+				r.Field(index).Set(fv)
+			}
+			return r, nil
+		}
+	}
+	return r, fmt.Errorf("cannot convert %v to Go %v", v.Type(), t)
+}
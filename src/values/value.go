@@ -1,15 +1,62 @@
 package values
 
 import (
+	"encoding/binary"
 	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
 
 	"types"
 )
 
-// Value represents a value in the language.
+// Value represents a value in the language. Values of equal types can be
+// compared with Equal; equal values have equal hashes, so Hash can be used to
+// key Go maps by apl values.
 type Value interface {
 	Type() types.Type
 	String() string
+	Equal(Value) bool
+	Hash() uint64
+}
+
+// Kinds of values, which seed their hashes.
+const (
+	kindInt byte = iota + 1
+	kindBool
+	kindString
+	kindFloat
+	kindList
+	kindMap
+	kindStruct
+)
+
+// hasher computes FNV-1a hashes of a kind followed by words and strings.
+type hasher struct {
+	buf []byte
+}
+
+func newHasher(kind byte) *hasher {
+	return &hasher{buf: []byte{kind}}
+}
+
+func (h *hasher) word(w uint64) *hasher {
+	h.buf = binary.LittleEndian.AppendUint64(h.buf, w)
+	return h
+}
+
+func (h *hasher) str(s string) *hasher {
+	h.word(uint64(len(s)))
+	h.buf = append(h.buf, s...)
+	return h
+}
+
+func (h *hasher) sum() uint64 {
+	f := fnv.New64a()
+	f.Write(h.buf)
+	return f.Sum64()
 }
 
 // Int is an integer value.
@@ -26,6 +73,17 @@ func (i *Int) String() string {
 	return fmt.Sprintf("%d", i.V)
 }
 
+// Equal returns true if v is an Int with the same value.
+func (i *Int) Equal(v Value) bool {
+	i2, ok := v.(*Int)
+	return ok && i.V == i2.V
+}
+
+// Hash returns the hash of the value.
+func (i *Int) Hash() uint64 {
+	return newHasher(kindInt).word(uint64(i.V)).sum()
+}
+
 // Bool is a boolean value.
 type Bool struct {
 	V bool
@@ -40,6 +98,21 @@ func (b *Bool) String() string {
 	return fmt.Sprintf("%t", b.V)
 }
 
+// Equal returns true if v is a Bool with the same value.
+func (b *Bool) Equal(v Value) bool {
+	b2, ok := v.(*Bool)
+	return ok && b.V == b2.V
+}
+
+// Hash returns the hash of the value.
+func (b *Bool) Hash() uint64 {
+	var w uint64
+	if b.V {
+		w = 1
+	}
+	return newHasher(kindBool).word(w).sum()
+}
+
 // String is a string value.
 type String struct {
 	V string
@@ -53,3 +126,245 @@ func (s *String) Type() types.Type {
 func (s *String) String() string {
 	return s.V
 }
+
+// Equal returns true if v is a String with the same value.
+func (s *String) Equal(v Value) bool {
+	s2, ok := v.(*String)
+	return ok && s.V == s2.V
+}
+
+// Hash returns the hash of the value.
+func (s *String) Hash() uint64 {
+	return newHasher(kindString).str(s.V).sum()
+}
+
+// Float is a floating-point value.
+type Float struct {
+	V float64
+}
+
+// Type returns the Float type.
+func (f *Float) Type() types.Type {
+	return &types.Float{}
+}
+
+func (f *Float) String() string {
+	return strconv.FormatFloat(f.V, 'g', -1, 64)
+}
+
+// Equal returns true if v is a Float with the same value. As in Go, NaN is
+// not equal to itself.
+func (f *Float) Equal(v Value) bool {
+	f2, ok := v.(*Float)
+	return ok && f.V == f2.V
+}
+
+// Hash returns the hash of the value.
+func (f *Float) Hash() uint64 {
+	bits := math.Float64bits(f.V)
+	if f.V == 0 {
+		bits = 0 // -0 equals 0.
+	}
+	return newHasher(kindFloat).word(bits).sum()
+}
+
+// List is a list value.
+type List struct {
+	Typ *types.List
+	V   []Value
+}
+
+// Type returns the List type.
+func (l *List) Type() types.Type {
+	return l.Typ
+}
+
+func (l *List) String() string {
+	strs := make([]string, len(l.V))
+	for i, v := range l.V {
+		strs[i] = v.String()
+	}
+	return "[" + strings.Join(strs, " ") + "]"
+}
+
+// Equal returns true if v is a List of the same type with equal elements.
+func (l *List) Equal(v Value) bool {
+	l2, ok := v.(*List)
+	if !ok || !l.Typ.Equals(l2.Typ) || len(l.V) != len(l2.V) {
+		return false
+	}
+	for i, elem := range l.V {
+		if !elem.Equal(l2.V[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Hash returns the hash of the value.
+func (l *List) Hash() uint64 {
+	h := newHasher(kindList).word(uint64(len(l.V)))
+	for _, elem := range l.V {
+		h.word(elem.Hash())
+	}
+	return h.sum()
+}
+
+// Map is a map value. Its entries are kept sorted by key. Maps must be
+// created with NewMap, which indexes the entries, and not be modified after:
+// a Map literal has no index, so Get finds none of its entries.
+type Map struct {
+	Typ     *types.Map
+	Entries []*MapEntry
+	index   map[uint64][]int // Indexes of entries by hash of their key.
+}
+
+// MapEntry is an entry of a Map.
+type MapEntry struct {
+	Key, Elem Value
+}
+
+// NewMap returns a Map of type typ with the given entries, sorted by key. Of
+// entries with equal keys, the last one is kept.
+func NewMap(typ *types.Map, entries []*MapEntry) *Map {
+	m := &Map{Typ: typ, index: make(map[uint64][]int)}
+	for _, e := range entries {
+		if i, ok := m.find(e.Key); ok {
+			m.Entries[i] = e
+			continue
+		}
+		h := e.Key.Hash()
+		m.index[h] = append(m.index[h], len(m.Entries))
+		m.Entries = append(m.Entries, e)
+	}
+	sort.SliceStable(m.Entries, func(i, j int) bool {
+		return less(m.Entries[i].Key, m.Entries[j].Key)
+	})
+	m.reindex()
+	return m
+}
+
+// reindex rebuilds the index of the entries.
+func (m *Map) reindex() {
+	m.index = make(map[uint64][]int, len(m.Entries))
+	for i, e := range m.Entries {
+		h := e.Key.Hash()
+		m.index[h] = append(m.index[h], i)
+	}
+}
+
+// find returns the index of the entry with the given key.
+func (m *Map) find(key Value) (int, bool) {
+	for _, i := range m.index[key.Hash()] {
+		if m.Entries[i].Key.Equal(key) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// Get returns the element for key, and whether the map contains the key.
+func (m *Map) Get(key Value) (Value, bool) {
+	i, ok := m.find(key)
+	if !ok {
+		return nil, false
+	}
+	return m.Entries[i].Elem, true
+}
+
+// less orders keys of maps. Keys other than numbers, strings and bools are
+// ordered by their string form.
+func less(a, b Value) bool {
+	switch a := a.(type) {
+	case *Int:
+		return a.V < b.(*Int).V
+	case *Bool:
+		return !a.V && b.(*Bool).V
+	case *String:
+		return a.V < b.(*String).V
+	case *Float:
+		return a.V < b.(*Float).V
+	}
+	return a.String() < b.String()
+}
+
+// Type returns the Map type.
+func (m *Map) Type() types.Type {
+	return m.Typ
+}
+
+func (m *Map) String() string {
+	strs := make([]string, len(m.Entries))
+	for i, e := range m.Entries {
+		strs[i] = e.Key.String() + ":" + e.Elem.String()
+	}
+	return "map[" + strings.Join(strs, " ") + "]"
+}
+
+// Equal returns true if v is a Map of the same type with equal entries.
+func (m *Map) Equal(v Value) bool {
+	m2, ok := v.(*Map)
+	if !ok || !m.Typ.Equals(m2.Typ) || len(m.Entries) != len(m2.Entries) {
+		return false
+	}
+	for _, e := range m.Entries {
+		elem, ok := m2.Get(e.Key)
+		if !ok || !e.Elem.Equal(elem) {
+			return false
+		}
+	}
+	return true
+}
+
+// Hash returns the hash of the value.
+func (m *Map) Hash() uint64 {
+	// Entries are sorted, but keys ordered by their string form may still
+	// be in any order, so combine the entry hashes commutatively.
+	var sum uint64
+	for _, e := range m.Entries {
+		sum += newHasher(kindMap).word(e.Key.Hash()).word(e.Elem.Hash()).sum()
+	}
+	return newHasher(kindMap).word(uint64(len(m.Entries))).word(sum).sum()
+}
+
+// Struct is a struct value.
+type Struct struct {
+	Typ    *types.Struct
+	Fields []Value // In the order of the fields of Typ.
+}
+
+// Type returns the Struct type.
+func (s *Struct) Type() types.Type {
+	return s.Typ
+}
+
+func (s *Struct) String() string {
+	strs := make([]string, len(s.Fields))
+	for i, v := range s.Fields {
+		strs[i] = s.Typ.Fields[i].Name + ":" + v.String()
+	}
+	return "{" + strings.Join(strs, " ") + "}"
+}
+
+// Equal returns true if v is a Struct of the same type with equal fields.
+func (s *Struct) Equal(v Value) bool {
+	s2, ok := v.(*Struct)
+	if !ok || !s.Typ.Equals(s2.Typ) {
+		return false
+	}
+	for i, f := range s.Fields {
+		if !f.Equal(s2.Fields[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Hash returns the hash of the value.
+func (s *Struct) Hash() uint64 {
+	h := newHasher(kindStruct).word(uint64(len(s.Fields)))
+	for i, f := range s.Fields {
+		h.str(s.Typ.Fields[i].Name).word(f.Hash())
+	}
+	return h.sum()
+}
@@ -0,0 +1,192 @@
+package values
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"types"
+)
+
+type point struct {
+	X, Y   int
+	Label  string `apl:"label"`
+	Hidden bool   `apl:"-"`
+	hidden bool
+}
+
+func TestGoRoundTrip(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input interface{}
+		typ   string
+		str   string
+	}{
+		{"int", 42, "type<int>", "42"},
+		{"uint8", uint8(7), "type<int>", "7"},
+		{"float", 2.5, "type<float>", "2.5"},
+		{"float32", float32(0.5), "type<float>", "0.5"},
+		{"bool", true, "type<bool>", "true"},
+		{"string", "apl", "type<string>", "apl"},
+		{"list", []string{"a", "b"}, "type<[]string>", "[a b]"},
+		{"empty_list", []int{}, "type<[]int>", "[]"},
+		{"nested_list", [][]int{{1}, {2, 3}}, "type<[][]int>", "[[1] [2 3]]"},
+		{"map", map[string]int{"b": 2, "a": 1}, "type<map[string]int>", "map[a:1 b:2]"},
+		{"map_of_lists", map[int][]bool{1: {true}}, "type<map[int][]bool>", "map[1:[true]]"},
+		{"struct", point{X: 1, Y: 2, Label: "p"}, "type<struct{X int; Y int; label string}>", "{X:1 Y:2 label:p}"},
+		{"pointer", &point{X: 3, Label: "q"}, "type<struct{X int; Y int; label string}>", "{X:3 Y:0 label:q}"},
+		{"list_of_structs", []point{{X: 1}}, "type<[]struct{X int; Y int; label string}>", "[{X:1 Y:0 label:}]"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			v, err := FromGo(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s := v.Type().(interface{ String() string }).String(); s != tc.typ {
+				t.Errorf("expected type %s but got %s", tc.typ, s)
+			}
+			if v.String() != tc.str {
+				t.Errorf("expected %q but got %q", tc.str, v.String())
+			}
+			x, err := ToGo(v, reflect.TypeOf(tc.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(x, tc.input) {
+				t.Errorf("expected %#v but got %#v", tc.input, x)
+			}
+			again, err := FromGo(x)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !v.Equal(again) || v.Hash() != again.Hash() {
+				t.Errorf("round trip of %v not equal: %v", v, again)
+			}
+		})
+	}
+}
+
+func TestToGoInterface(t *testing.T) {
+	v, err := FromGo(map[string][]point{"a": {{X: 1, Label: "p"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	x, err := ToGo(v, reflect.TypeOf((*interface{})(nil)).Elem())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, ok := x.(map[string][]struct {
+		X     int    `apl:"X"`
+		Y     int    `apl:"Y"`
+		Label string `apl:"label"`
+	})
+	if !ok {
+		t.Fatalf("unexpected Go type %T", x)
+	}
+	if m["a"][0].X != 1 || m["a"][0].Label != "p" {
+		t.Errorf("unexpected value %#v", m)
+	}
+}
+
+func TestGoErrors(t *testing.T) {
+	if _, err := FromGo(make(chan int)); err == nil || err.Error() != "unsupported type chan int" {
+		t.Errorf("unexpected error %v", err)
+	}
+	if _, err := FromGo((*point)(nil)); err == nil || err.Error() != "cannot convert nil *values.point" {
+		t.Errorf("unexpected error %v", err)
+	}
+	if _, err := FromGo(uint64(math.MaxUint64)); err == nil || err.Error() != "18446744073709551615 overflows int" {
+		t.Errorf("unexpected error %v", err)
+	}
+	testCases := []struct {
+		v   Value
+		to  interface{}
+		err string
+	}{
+		{&Int{V: 300}, uint8(0), "300 overflows Go uint8"},
+		{&Int{V: -1}, uint(0), "-1 overflows Go uint"},
+		{&Int{V: 1}, "", "cannot convert type<int> to Go string"},
+		{&List{Typ: &types.List{Elem: &types.Int{}}, V: []Value{&String{V: "a"}}}, []int{}, "cannot convert type<string> to Go int"},
+		{&Struct{Typ: &types.Struct{Fields: []*types.Field{{Name: "Z", Type: &types.Int{}}}}, Fields: []Value{&Int{V: 1}}}, point{},
+			"cannot convert type<struct{Z int}> to Go values.point: no field Z"},
+	}
+	for _, tc := range testCases {
+		_, err := ToGo(tc.v, reflect.TypeOf(tc.to))
+		if err == nil || err.Error() != tc.err {
+			t.Errorf("ToGo(%v, %T): expected %q but got %v", tc.v, tc.to, tc.err, err)
+		}
+	}
+}
+
+func TestEqualHash(t *testing.T) {
+	mustGo := func(x interface{}) Value {
+		v, err := FromGo(x)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	testCases := []struct {
+		a, b  Value
+		equal bool
+	}{
+		{&Int{V: 1}, &Int{V: 1}, true},
+		{&Int{V: 1}, &Int{V: 2}, false},
+		{&Int{V: 1}, &Float{V: 1}, false},
+		{&Float{V: 0}, &Float{V: math.Copysign(0, -1)}, true},
+		{&Float{V: math.NaN()}, &Float{V: math.NaN()}, false},
+		{&String{V: "a"}, &String{V: "a"}, true},
+		{&String{V: "1"}, &Int{V: 1}, false},
+		{&Bool{V: true}, &Bool{V: false}, false},
+		{mustGo([]int{1, 2}), mustGo([]int{1, 2}), true},
+		{mustGo([]int{1, 2}), mustGo([]int{2, 1}), false},
+		{mustGo([]int{}), mustGo([]string{}), false},
+		{mustGo(map[string]int{"a": 1, "b": 2}), mustGo(map[string]int{"b": 2, "a": 1}), true},
+		{mustGo(map[string]int{"a": 1}), mustGo(map[string]int{"a": 2}), false},
+		{mustGo(point{X: 1}), mustGo(point{X: 1, Hidden: true}), true},
+		{mustGo(point{X: 1}), mustGo(point{X: 2}), false},
+	}
+	for _, tc := range testCases {
+		if got := tc.a.Equal(tc.b); got != tc.equal {
+			t.Errorf("%v.Equal(%v): expected %t", tc.a, tc.b, tc.equal)
+		}
+		if got := tc.b.Equal(tc.a); got != tc.equal {
+			t.Errorf("%v.Equal(%v): expected %t", tc.b, tc.a, tc.equal)
+		}
+		if tc.equal && tc.a.Hash() != tc.b.Hash() {
+			t.Errorf("equal values %v and %v have different hashes", tc.a, tc.b)
+		}
+	}
+}
+
+func TestMapKeys(t *testing.T) {
+	typ := &types.Map{Key: &types.List{Elem: &types.Int{}}, Elem: &types.String{}}
+	list := func(xs ...int) Value {
+		l := &List{Typ: typ.Key.(*types.List)}
+		for _, x := range xs {
+			l.V = append(l.V, &Int{V: x})
+		}
+		return l
+	}
+	m := NewMap(typ, []*MapEntry{
+		{Key: list(1, 2), Elem: &String{V: "a"}},
+		{Key: list(), Elem: &String{V: "b"}},
+		{Key: list(1, 2), Elem: &String{V: "c"}},
+	})
+	if len(m.Entries) != 2 {
+		t.Fatalf("expected 2 entries but got %v", m)
+	}
+	if v, ok := m.Get(list(1, 2)); !ok || v.String() != "c" {
+		t.Errorf("expected c but got %v", v)
+	}
+	if v, ok := m.Get(list()); !ok || v.String() != "b" {
+		t.Errorf("expected b but got %v", v)
+	}
+	if _, ok := m.Get(list(2, 1)); ok {
+		t.Errorf("unexpected entry for [2 1]")
+	}
+	if s := m.String(); s != "map[[1 2]:c []:b]" {
+		t.Errorf("unexpected string %s", s)
+	}
+}
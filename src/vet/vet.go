@@ -0,0 +1,352 @@
+// Package vet reports suspicious constructs in apl programs, such as funcs
+// that are never called. Unlike the errors of Executor.Check, its findings
+// do not keep a program from running.
+//
+// Vet runs a set of rules over a checked program. A diagnostic can be
+// suppressed with a line comment starting with "vet:ignore", optionally
+// followed by the names of the rules to suppress, separated by commas or
+// spaces. The comment applies to its own line and the line following it:
+//
+//	// vet:ignore unusedarg
+//	func f(int x) {
+package vet
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"ast"
+	"ast/expr"
+	"ast/source"
+	"ast/statement"
+	"interp"
+	"types"
+)
+
+// Rule is a check run by Vet.
+type Rule struct {
+	Name string
+	Doc  string // One-line description.
+	run  func(p *pass)
+}
+
+// Rules are all rules, sorted by name.
+var Rules = []*Rule{
+	{
+		Name: "shadow",
+		Doc:  "report args named like a func, type or import visible in their file",
+		run:  shadowedArgs,
+	},
+	{
+		Name: "unusedarg",
+		Doc:  "report func args that are never used",
+		run:  unusedArgs,
+	},
+	{
+		Name: "unusedfunc",
+		Doc:  "report funcs neither exported nor called from main, test or exported funcs",
+		run:  unusedFuncs,
+	},
+	{
+		Name: "unusedimport",
+		Doc:  "report imports whose names are never used",
+		run:  unusedImports,
+	},
+}
+
+// Lookup returns the rule with the given name, or nil if there is none.
+func Lookup(name string) *Rule {
+	for _, r := range Rules {
+		if r.Name == name {
+			return r
+		}
+	}
+	return nil
+}
+
+// Diagnostic is a finding of a rule.
+type Diagnostic struct {
+	Src  source.Source
+	Rule string // Name of the rule.
+	Msg  string
+}
+
+// String returns the diagnostic as file:line:col: message (rule).
+func (d *Diagnostic) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s (%s)", d.Src.File(), d.Src.Line()+1, d.Src.LinePos()+1, d.Msg, d.Rule)
+}
+
+// Vet checks the package at an import path and the packages it imports with
+// e, and runs the rules on all of their files. It returns the diagnostics
+// that are not suppressed, sorted by file and position. Main and test funcs,
+// see Executor.Tests, are only assumed to be called in the package at path.
+// Returns the error of Check if the program does not check.
+func Vet(e *interp.Executor, path string, rules []*Rule) ([]*Diagnostic, error) {
+	pkgs, err := e.Packages(path)
+	if err != nil {
+		return nil, err
+	}
+	p := &pass{pkgs: newPackages(pkgs)}
+	for _, r := range rules {
+		p.rule = r
+		r.run(p)
+	}
+	var diags []*Diagnostic
+	for _, d := range p.diags {
+		if !p.ignored(d) {
+			diags = append(diags, d)
+		}
+	}
+	sort.SliceStable(diags, func(i, j int) bool {
+		a, b := diags[i].Src, diags[j].Src
+		if a.File() != b.File() {
+			return a.File() < b.File()
+		}
+		return a.Pos() < b.Pos()
+	})
+	return diags, nil
+}
+
+// pass is the state of a run of Vet.
+type pass struct {
+	pkgs  []*pkg // Checked packages, the one vetted first.
+	rule  *Rule  // Rule being run.
+	diags []*Diagnostic
+}
+
+// pkg is a checked package.
+type pkg struct {
+	path  string
+	files []*file
+}
+
+// file is a checked file.
+type file struct {
+	*ast.File
+	pkg   *pkg
+	scope *types.Context // Context the file was checked in.
+}
+
+// newPackages wraps the checked packages for a pass.
+func newPackages(pkgs []*interp.Package) []*pkg {
+	var ps []*pkg
+	for _, ip := range pkgs {
+		p := &pkg{path: ip.Path}
+		for _, f := range ip.Files {
+			p.files = append(p.files, &file{File: f, pkg: p, scope: ip.Scope[f]})
+		}
+		ps = append(ps, p)
+	}
+	return ps
+}
+
+// files calls fn for each file of the checked packages.
+func (p *pass) files(fn func(f *file)) {
+	for _, pkg := range p.pkgs {
+		for _, f := range pkg.files {
+			fn(f)
+		}
+	}
+}
+
+// reportf records a diagnostic of the running rule at src.
+func (p *pass) reportf(src source.Source, format string, args ...interface{}) {
+	p.diags = append(p.diags, &Diagnostic{Src: src, Rule: p.rule.Name, Msg: fmt.Sprintf(format, args...)})
+}
+
+// ignored reports whether a vet:ignore comment suppresses d.
+func (p *pass) ignored(d *Diagnostic) bool {
+	for _, pkg := range p.pkgs {
+		for _, f := range pkg.files {
+			if f.File.File() != d.Src.File() {
+				continue
+			}
+			for _, c := range f.Comments {
+				if l := d.Src.Line() - c.Line(); (l == 0 || l == 1) && suppresses(c.Text, d.Rule) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// suppresses reports whether the text of a comment suppresses diagnostics of
+// a rule.
+func suppresses(comment, rule string) bool {
+	text := strings.TrimSpace(strings.TrimPrefix(comment, "//"))
+	rest := strings.TrimPrefix(text, "vet:ignore")
+	if rest == text || rest != "" && rest[0] != ' ' && rest[0] != '\t' {
+		return false
+	}
+	names := strings.FieldsFunc(rest, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+	if len(names) == 0 {
+		return true
+	}
+	for _, name := range names {
+		if name == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// calls calls fn with the position and name of each call in node.
+func calls(node ast.Node, fn func(src source.Source, name string)) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *expr.Call:
+			fn(n, n.Nam)
+		case *statement.FnCall:
+			fn(n, n.Nam)
+		}
+		return true
+	})
+}
+
+// funcs calls fn for each func declared in a file.
+func (f *file) funcs(fn func(d *ast.FnDecl)) {
+	for _, decl := range f.Decls {
+		if d, ok := decl.(*ast.FnDecl); ok {
+			fn(d)
+		}
+	}
+}
+
+// qualifiers returns the imports of a file by the qualifier they are
+// available as, if any.
+func (f *file) qualifiers() map[string]*statement.Import {
+	quals := make(map[string]*statement.Import)
+	for _, imp := range f.Imports {
+		if len(imp.Names) == 0 || imp.Alias != "" {
+			quals[imp.Qualifier()] = imp
+		}
+	}
+	return quals
+}
+
+func unusedImports(p *pass) {
+	p.files(func(f *file) {
+		used := make(map[string]bool) // Called names and qualifiers.
+		calls(f.File, func(_ source.Source, name string) {
+			used[name] = true
+			if i := strings.LastIndex(name, "."); i >= 0 {
+				used[name[:i]] = true
+			}
+		})
+		for _, imp := range f.Imports {
+			qualified := len(imp.Names) == 0 || imp.Alias != ""
+			var unused []*statement.ImportName
+			for _, name := range imp.Names {
+				if !used[name.Nam] {
+					unused = append(unused, name)
+				}
+			}
+			if (!qualified || !used[imp.Qualifier()]) && len(unused) == len(imp.Names) {
+				p.reportf(imp, "import %q not used", imp.Name)
+				continue
+			}
+			if qualified && len(imp.Names) > 0 && !used[imp.Qualifier()] {
+				p.reportf(imp, "alias %s of import %q not used", imp.Alias, imp.Name)
+			}
+			for _, name := range unused {
+				p.reportf(name, "%s imported from %q not used", name.Nam, imp.Name)
+			}
+		}
+	})
+}
+
+// fnKey identifies a declared func.
+type fnKey struct {
+	pkg, name string
+}
+
+func unusedFuncs(p *pass) {
+	decls := make(map[fnKey]*ast.FnDecl)
+	callees := make(map[fnKey][]fnKey)
+	var roots, order []fnKey
+	p.files(func(f *file) {
+		f.funcs(func(d *ast.FnDecl) {
+			k := fnKey{f.pkg.path, d.Nam}
+			decls[k] = d
+			order = append(order, k)
+			if d.Export || types.IsExported(d.Nam) || f.pkg == p.pkgs[0] && (d.Nam == "main" || strings.HasPrefix(d.Nam, interp.TestPrefix)) {
+				roots = append(roots, k)
+			}
+			calls(d, func(_ source.Source, name string) {
+				if path, local, err := f.scope.Resolve(name); err == nil && path != "" {
+					callees[k] = append(callees[k], fnKey{path, local})
+				}
+			})
+		})
+	})
+	reached := make(map[fnKey]bool)
+	for len(roots) > 0 {
+		k := roots[len(roots)-1]
+		roots = roots[:len(roots)-1]
+		if reached[k] {
+			continue
+		}
+		reached[k] = true
+		roots = append(roots, callees[k]...)
+	}
+	for _, k := range order {
+		if !reached[k] {
+			p.reportf(decls[k], "func %s is unused", k.name)
+		}
+	}
+}
+
+func unusedArgs(p *pass) {
+	p.files(func(f *file) {
+		f.funcs(func(d *ast.FnDecl) {
+			used := make(map[string]bool)
+			ast.Inspect(d, func(n ast.Node) bool {
+				if id, ok := n.(*expr.Ident); ok {
+					used[id.Nam] = true
+				}
+				return true
+			})
+			for _, arg := range d.Args {
+				if !used[arg.Nam] {
+					p.reportf(arg, "arg %s of %s is unused", arg.Nam, d.Nam)
+				}
+			}
+		})
+	})
+}
+
+func shadowedArgs(p *pass) {
+	p.files(func(f *file) {
+		quals := f.qualifiers()
+		f.funcs(func(d *ast.FnDecl) {
+			for _, arg := range d.Args {
+				if imp, ok := quals[arg.Nam]; ok {
+					p.reportf(arg, "arg %s shadows import %q", arg.Nam, imp.Name)
+					continue
+				}
+				path, _, err := f.scope.Resolve(arg.Nam)
+				if err != nil {
+					continue
+				}
+				kind := "type"
+				if typ, _ := f.scope.Get(arg.Nam); typ != nil {
+					if _, ok := typ.(*types.Func); ok {
+						kind = "func"
+					}
+				}
+				switch path {
+				case "":
+					p.reportf(arg, "arg %s shadows builtin %s %s", arg.Nam, kind, arg.Nam)
+				case f.pkg.path:
+					p.reportf(arg, "arg %s shadows %s %s", arg.Nam, kind, arg.Nam)
+				default:
+					p.reportf(arg, "arg %s shadows %s %s imported from %q", arg.Nam, kind, arg.Nam, path)
+				}
+			}
+		})
+	})
+}
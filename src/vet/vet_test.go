@@ -0,0 +1,264 @@
+package vet
+
+import (
+	"strings"
+	"testing"
+
+	"interp"
+)
+
+func TestVet(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input map[string]string
+		rules []string // All rules if empty.
+		want  []string
+	}{
+		{
+			name: "clean",
+			input: map[string]string{
+				"main.apl": `import lib;
+
+func main() {
+  println(lib.Twice(2));
+}
+`,
+				"lib.apl": `func Twice(int x) int {
+  return x + x;
+}
+`,
+			},
+		},
+		{
+			name: "unused_imports",
+			input: map[string]string{
+				"main.apl": `import lib;
+import util as u { Half };
+import util { Triple };
+import lib as l { Twice };
+
+func main() {
+  println(Half(4), Twice(2));
+}
+`,
+				"lib.apl": `func Twice(int x) int {
+  return x + x;
+}
+`,
+				"util.apl": `func Half(int x) int {
+  return x / 2;
+}
+
+func Triple(int x) int {
+  return x * 3;
+}
+`,
+			},
+			want: []string{
+				`main.apl:1:1: import "lib" not used (unusedimport)`,
+				`main.apl:2:1: alias u of import "util" not used (unusedimport)`,
+				`main.apl:3:1: import "util" not used (unusedimport)`,
+				`main.apl:4:1: alias l of import "lib" not used (unusedimport)`,
+			},
+		},
+		{
+			name: "unused_import_names",
+			input: map[string]string{
+				"main.apl": `import util { Half, Triple };
+
+func main() {
+  println(Triple(1));
+}
+`,
+				"util.apl": `func Half(int x) int {
+  return x / 2;
+}
+
+func Triple(int x) int {
+  return x * 3;
+}
+`,
+			},
+			want: []string{
+				`main.apl:1:15: Half imported from "util" not used (unusedimport)`,
+			},
+		},
+		{
+			name: "unused_funcs",
+			input: map[string]string{
+				"main.apl": `import lib;
+
+func b(int x) int {
+  if x > 0 {
+    return b(x - 1);
+  }
+  return lib.Twice(x);
+}
+
+func a() {
+  println(b(1));
+}
+
+func main() {
+  a();
+}
+
+func c(int n) {
+  if n > 0 {
+    c(n - 1);
+  }
+}
+
+func f() {
+}
+
+export func e() {
+  f();
+}
+`,
+				"lib.apl": `func half(int x) int {
+  return x / 2;
+}
+
+func Twice(int x) int {
+  return half(x) * 4;
+}
+
+func main() {
+}
+`,
+			},
+			rules: []string{"unusedfunc"},
+			want: []string{
+				`lib.apl:9:1: func main is unused (unusedfunc)`,
+				`main.apl:18:1: func c is unused (unusedfunc)`,
+			},
+		},
+		{
+			name: "unused_args",
+			input: map[string]string{
+				"main.apl": `func g(int x) int {
+  return x;
+}
+
+func f(int x, int y, int z) {
+  if y > 0 {
+    println(g(z));
+  }
+}
+
+func main() {
+  f(1, 2, 3);
+}
+`,
+			},
+			want: []string{
+				`main.apl:5:8: arg x of f is unused (unusedarg)`,
+			},
+		},
+		{
+			name: "shadowed_args",
+			input: map[string]string{
+				"main.apl": `import lib;
+import util { Half };
+
+func main() {
+}
+
+func f(int main, int lib, int Half, int println, int int, int x) int {
+  return main + lib + Half + println + int + x + lib.Twice(Half(x));
+}
+
+export func F() {
+  println(f(1, 2, 3, 4, 5, 6));
+}
+`,
+				"lib.apl": `func Twice(int x) int {
+  return x + x;
+}
+`,
+				"util.apl": `func Half(int x) int {
+  return x / 2;
+}
+`,
+			},
+			rules: []string{"shadow"},
+			want: []string{
+				`main.apl:7:8: arg main shadows func main (shadow)`,
+				`main.apl:7:18: arg lib shadows import "lib" (shadow)`,
+				`main.apl:7:27: arg Half shadows func Half imported from "util" (shadow)`,
+				`main.apl:7:37: arg println shadows builtin func println (shadow)`,
+				`main.apl:7:50: arg int shadows builtin type int (shadow)`,
+			},
+		},
+		{
+			name: "suppressed",
+			input: map[string]string{
+				"main.apl": `import lib; // vet:ignore
+
+// vet:ignore unusedarg, shadow
+func f(int x) {
+}
+
+func main() {
+  f(1);
+}
+
+// vet:ignore shadow
+func g(int println) {
+}
+
+//vet:ignore unusedfunc,unusedarg
+func h(int x) {
+}
+
+// vet:ignored
+func i() {
+}
+`,
+				"lib.apl": `func Twice(int x) int {
+  return x + x;
+}
+`,
+			},
+			want: []string{
+				`main.apl:12:1: func g is unused (unusedfunc)`,
+				`main.apl:12:8: arg println of g is unused (unusedarg)`,
+				`main.apl:20:1: func i is unused (unusedfunc)`,
+			},
+		},
+		{
+			name: "check_error",
+			input: map[string]string{
+				"main.apl": `func main() {
+  f();
+}
+`,
+			},
+			want: []string{"main.apl:2:3 unknown type or func: f"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rules := Rules
+			if len(tc.rules) > 0 {
+				rules = nil
+				for _, name := range tc.rules {
+					rules = append(rules, Lookup(name))
+				}
+			}
+			e := interp.NewExecutor(interp.NewStringLoader(tc.input))
+			diags, err := Vet(e, "main.apl", rules)
+			var got []string
+			if err != nil {
+				got = append(got, err.Error())
+			}
+			for _, d := range diags {
+				got = append(got, d.String())
+			}
+			if strings.Join(got, "\n") != strings.Join(tc.want, "\n") {
+				t.Errorf("expected\n%s\ngot\n%s", strings.Join(tc.want, "\n"), strings.Join(got, "\n"))
+			}
+		})
+	}
+}